@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+)
+
+var (
+	ErrInvalidAuthenticationStringFormat = errors.New("auth: invalid authentication string format")
+	ErrCleartextPasswordNotAllowed       = errors.New("auth: cleartext password not allowed without TLS")
+)
+
+// https://dev.mysql.com/doc/internals/en/secure-password-authentication.html
+type nativePasswordPlugin struct{}
+
+func (nativePasswordPlugin) Name() string {
+	return "mysql_native_password"
+}
+
+func (nativePasswordPlugin) InitialResponse(c *ClientContext) ([]byte, error) {
+	if len(c.Password) == 0 {
+		return nil, nil
+	}
+	return MySQLNativePassword.EncryptPassword(c.Password, c.AuthData)
+}
+
+func (nativePasswordPlugin) MoreData(c *ClientContext, data []byte) ([]byte, error) {
+	return nil, ErrUnsupportedAuthenticationMethod
+}
+
+func (nativePasswordPlugin) Authenticate(conn ServerConn, authRes, authData []byte) error {
+	as, err := conn.AuthenticationString()
+	if err != nil {
+		return err
+	}
+	// account without password
+	if len(as) == 0 {
+		if len(authRes) == 0 {
+			return nil
+		}
+		return ErrMismatch
+	}
+	if len(as) != 41 {
+		return ErrInvalidAuthenticationStringFormat
+	}
+	challengeData, err := hex.DecodeString(string(bytes.ToLower(as[1:])))
+	if err != nil {
+		return err
+	}
+	return MySQLNativePassword.ChallengeResponse(challengeData, authRes, authData)
+}
+
+func (nativePasswordPlugin) GenerateAuthenticationString(password []byte) ([]byte, error) {
+	return MySQLNativePassword.GenerateAuthenticationStringWithoutSalt(password)
+}
+
+// https://dev.mysql.com/doc/internals/en/sha256.html
+type sha256PasswordPlugin struct{}
+
+func (sha256PasswordPlugin) Name() string {
+	return "sha256_password"
+}
+
+func (sha256PasswordPlugin) InitialResponse(c *ClientContext) ([]byte, error) {
+	if len(c.Password) == 0 {
+		return []byte{0x00}, nil
+	}
+	if c.TLSed {
+		return append(c.Password, 0x00), nil
+	}
+	// request public key from server
+	return []byte{0x01}, nil
+}
+
+// MoreData receives public key from server and return password encrypted with it.
+func (sha256PasswordPlugin) MoreData(c *ClientContext, data []byte) ([]byte, error) {
+	return EncryptPasswordWithPublicKey(c.Password, c.AuthData, data)
+}
+
+func (sha256PasswordPlugin) Authenticate(conn ServerConn, authRes, authData []byte) error {
+	as, err := conn.AuthenticationString()
+	if err != nil {
+		return err
+	}
+
+	var password []byte
+	switch {
+	case conn.TLSed():
+		password = trimNul(authRes)
+	case len(authRes) == 1 && authRes[0] == 0x00:
+		password = nil
+	case len(authRes) == 1 && authRes[0] == 0x01:
+		if password, err = readPasswordEncryptedWithPublicKey(conn, authData); err != nil {
+			return err
+		}
+	default:
+		return ErrMismatch
+	}
+
+	return SHA256Password.ReAscertainPassword(as, password)
+}
+
+func (sha256PasswordPlugin) GenerateAuthenticationString(password []byte) ([]byte, error) {
+	return SHA256Password.GenerateAuthenticationStringWithoutSalt(password)
+}
+
+// https://dev.mysql.com/blog-archive/preparing-your-community-connector-for-mysql-8-part-2-sha256/
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_caching_sha2_authentication_exchanges.html
+type cachingSha2PasswordPlugin struct{}
+
+const (
+	cachingSha2RequestPublicKey  = 0x02
+	cachingSha2FastAuthSuccess   = 0x03
+	cachingSha2PerformFullAuth   = 0x04
+	cachingSha2PublicKeyResponse = '-'
+)
+
+func (cachingSha2PasswordPlugin) Name() string {
+	return "caching_sha2_password"
+}
+
+func (cachingSha2PasswordPlugin) InitialResponse(c *ClientContext) ([]byte, error) {
+	if len(c.Password) == 0 {
+		return nil, nil
+	}
+	return CachingSha2Password.EncryptPassword(c.Password, c.AuthData)
+}
+
+func (cachingSha2PasswordPlugin) MoreData(c *ClientContext, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrUnsupportedAuthenticationMethod
+	}
+
+	switch data[0] {
+	case cachingSha2FastAuthSuccess:
+		return nil, nil
+	case cachingSha2PerformFullAuth:
+		if c.SecureTransport {
+			return append(c.Password, 0x00), nil
+		}
+		return []byte{cachingSha2RequestPublicKey}, nil
+	case cachingSha2PublicKeyResponse:
+		return EncryptPasswordWithPublicKey(c.Password, c.AuthData, data)
+	default:
+		return nil, ErrUnsupportedAuthenticationMethod
+	}
+}
+
+func (cachingSha2PasswordPlugin) Authenticate(conn ServerConn, authRes, authData []byte) error {
+	// fast authentication
+	if challengeData := conn.Cache().Get(conn.Key()); challengeData != nil {
+		if err := CachingSha2Password.ChallengeResponse(challengeData, authRes, authData); err == nil {
+			return conn.WriteMoreData([]byte{cachingSha2FastAuthSuccess})
+		}
+	}
+
+	// full authentication
+	if err := conn.WriteMoreData([]byte{cachingSha2PerformFullAuth}); err != nil {
+		return err
+	}
+
+	data, err := conn.ReadPacket()
+	if err != nil {
+		return err
+	}
+
+	var password []byte
+	if conn.SecureTransport() {
+		password = trimNul(data)
+	} else {
+		if len(data) != 1 || data[0] != cachingSha2RequestPublicKey {
+			return ErrMismatch
+		}
+		if password, err = readPasswordEncryptedWithPublicKey(conn, authData); err != nil {
+			return err
+		}
+	}
+
+	as, err := conn.AuthenticationString()
+	if err != nil {
+		return err
+	}
+	if err := CachingSha2Password.ReAscertainPassword(as, password); err != nil {
+		return err
+	}
+
+	challengeData, err := CachingSha2Password.GenerateChallengeData(password)
+	if err != nil {
+		return err
+	}
+	conn.Cache().Put(conn.Key(), challengeData)
+	return nil
+}
+
+func (cachingSha2PasswordPlugin) GenerateAuthenticationString(password []byte) ([]byte, error) {
+	return CachingSha2Password.GenerateAuthenticationStringWithoutSalt(password)
+}
+
+func trimNul(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	if data[len(data)-1] == 0x00 {
+		return data[:len(data)-1]
+	}
+	return data
+}
+
+// https://dev.mysql.com/doc/refman/8.0/en/cleartext-pluggable-authentication.html
+type clearPasswordPlugin struct{}
+
+func (clearPasswordPlugin) Name() string {
+	return "mysql_clear_password"
+}
+
+func (clearPasswordPlugin) InitialResponse(c *ClientContext) ([]byte, error) {
+	if !c.TLSed && !c.AllowCleartextPasswords {
+		return nil, ErrCleartextPasswordNotAllowed
+	}
+	return append(c.Password, 0x00), nil
+}
+
+func (clearPasswordPlugin) MoreData(c *ClientContext, data []byte) ([]byte, error) {
+	return nil, ErrUnsupportedAuthenticationMethod
+}
+
+// Authenticate hands the cleartext password to verifier of server,
+// authentication_string is not used.
+func (clearPasswordPlugin) Authenticate(conn ServerConn, authRes, authData []byte) error {
+	return conn.VerifyPassword(trimNul(authRes))
+}
+
+// GenerateAuthenticationString return nothing, because password is verified by
+// external identity service.
+func (clearPasswordPlugin) GenerateAuthenticationString(password []byte) ([]byte, error) {
+	return nil, nil
+}
@@ -15,25 +15,23 @@ var (
 	ErrMismatch                        = errors.New("auth: validate mismatch")
 )
 
+// Method represents an authentication plugin in registry.
 type Method uint8
 
+// Built-in plugins, they are registered in this order.
 const (
 	MySQLNativePassword Method = iota
 	SHA256Password
 	CachingSha2Password
+	ClearPassword
 )
 
 func ParseAuthenticationPlugin(name string) (Method, error) {
-	switch name {
-	case MySQLNativePassword.String():
-		return MySQLNativePassword, nil
-	case SHA256Password.String():
-		return SHA256Password, nil
-	case CachingSha2Password.String():
-		return CachingSha2Password, nil
-	default:
+	m, ok := lookupPlugin(name)
+	if !ok {
 		return MySQLNativePassword, fmt.Errorf("unknown auth method: %v", name)
 	}
+	return m, nil
 }
 
 func (m Method) GenerateAuthenticationString(password, salt []byte) ([]byte, error) {
@@ -45,7 +43,7 @@ func (m Method) GenerateAuthenticationString(password, salt []byte) ([]byte, err
 	case CachingSha2Password:
 		return mysqlpassword.NewCachingSHA2().Encrypt(password, salt)
 	default:
-		return nil, ErrUnsupportedAuthenticationMethod
+		return m.generateAuthenticationString(password)
 	}
 }
 
@@ -53,6 +51,10 @@ func (m Method) GenerateAuthenticationStringWithoutSalt(password []byte) ([]byte
 	var salt []byte
 	switch m {
 	case MySQLNativePassword:
+		// authentication_string of account without password is empty
+		if len(password) == 0 {
+			return nil, nil
+		}
 		salt = nil
 	case SHA256Password:
 		salt = Bytes(20)
@@ -67,6 +69,8 @@ func (m Method) GenerateAuthenticationStringWithoutSalt(password []byte) ([]byte
 		copy(salt[3:], val)
 		copy(salt[3+3:], "$")
 		copy(salt[3+3+1:], Bytes(20))
+	default:
+		return m.generateAuthenticationString(password)
 	}
 
 	return m.GenerateAuthenticationString(password, salt)
@@ -86,6 +90,9 @@ func (m Method) GenerateAuthenticationStringWithoutSalt(password []byte) ([]byte
 func (m Method) ChallengeResponse(challengeData, authRes, salt []byte) error {
 	switch m {
 	case MySQLNativePassword:
+		if len(authRes) != sha1.Size {
+			return ErrMismatch
+		}
 		h := sha1.New()
 
 		h.Write(salt)
@@ -106,6 +113,9 @@ func (m Method) ChallengeResponse(challengeData, authRes, salt []byte) error {
 		return nil
 
 	case CachingSha2Password:
+		if len(authRes) != sha256.Size {
+			return ErrMismatch
+		}
 		h := sha256.New()
 
 		h.Write(challengeData)
@@ -232,14 +242,21 @@ func (m Method) GenerateChallengeData(password []byte) ([]byte, error) {
 }
 
 func (m Method) String() string {
-	switch m {
-	case MySQLNativePassword:
-		return "mysql_native_password"
-	case SHA256Password:
-		return "sha256_password"
-	case CachingSha2Password:
-		return "caching_sha2_password"
-	default:
-		return ErrUnsupportedAuthenticationMethod.Error()
+	p, err := m.Plugin()
+	if err != nil {
+		return err.Error()
+	}
+	return p.Name()
+}
+
+func (m Method) generateAuthenticationString(password []byte) ([]byte, error) {
+	p, err := m.Plugin()
+	if err != nil {
+		return nil, err
+	}
+	g, ok := p.(AuthenticationStringGenerator)
+	if !ok {
+		return nil, ErrUnsupportedAuthenticationMethod
 	}
+	return g.GenerateAuthenticationString(password)
 }
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrPluginExisted = errors.New("auth: plugin existed")
+	ErrTooManyPlugin = errors.New("auth: too many plugins")
+)
+
+// Plugin is an authentication plugin, it contains both client side and server side.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_authentication_methods.html
+type Plugin interface {
+	// Name return plugin name sent over the wire, e.g. mysql_native_password.
+	Name() string
+
+	ClientPlugin
+	ServerPlugin
+}
+
+// ClientPlugin is client side of authentication plugin.
+type ClientPlugin interface {
+	// InitialResponse return auth response sent in HandshakeResponse or AuthSwitchResponse packet.
+	InitialResponse(c *ClientContext) ([]byte, error)
+
+	// MoreData handles plugin data of AuthMoreData packet sent by server,
+	// and return data to send back. Nothing is sent if returned data is nil.
+	MoreData(c *ClientContext, data []byte) ([]byte, error)
+}
+
+// ClientContext is the state of client side authentication.
+type ClientContext struct {
+	Password []byte
+	// AuthData is salt sent by server in Handshake or AuthSwitchRequest packet.
+	AuthData []byte
+	TLSed    bool
+	// SecureTransport is true if connection uses TLS or Unix socket.
+	SecureTransport bool
+
+	// AllowCleartextPasswords allows sending password in cleartext without TLS.
+	AllowCleartextPasswords bool
+}
+
+// ServerPlugin is server side of authentication plugin.
+type ServerPlugin interface {
+	// Authenticate verifies authRes from HandshakeResponse or AuthSwitchResponse packet,
+	// authData is the salt sent to client. It may exchange more packets with client by conn.
+	//
+	// Authenticate return ErrMismatch if validation does not match.
+	Authenticate(conn ServerConn, authRes, authData []byte) error
+}
+
+// ServerConn is the connection being authenticated, it is implemented by server.
+type ServerConn interface {
+	// Key return unique key of the matched account.
+	Key() string
+
+	TLSed() bool
+	// SecureTransport return true if connection uses TLS or Unix socket.
+	SecureTransport() bool
+
+	// ReadPacket read payload of next packet sent by client.
+	ReadPacket() ([]byte, error)
+
+	// WriteMoreData write AuthMoreData packet with plugin data.
+	WriteMoreData(data []byte) error
+
+	// AuthenticationString return authentication_string of the matched account.
+	AuthenticationString() ([]byte, error)
+
+	// RSAKeyPair return private key and public key in PEM format used by the plugin.
+	RSAKeyPair() (*rsa.PrivateKey, []byte)
+
+	// Cache return cache shared by all connections of server.
+	Cache() Cache
+
+	// VerifyPassword verifies cleartext password with external identity service, such as LDAP or PAM.
+	// It should return ErrMismatch if password does not match.
+	VerifyPassword(password []byte) error
+}
+
+// Cache stores authentication data between connections, such as
+// caching_sha2_password challenge data.
+type Cache interface {
+	Put(key string, val []byte)
+
+	Get(key string) []byte
+}
+
+// AuthenticationStringGenerator is implemented by plugins that
+// store password digest in authentication_string.
+type AuthenticationStringGenerator interface {
+	GenerateAuthenticationString(password []byte) ([]byte, error)
+}
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   []Plugin
+)
+
+func init() {
+	mustRegister(nativePasswordPlugin{})
+	mustRegister(sha256PasswordPlugin{})
+	mustRegister(cachingSha2PasswordPlugin{})
+	mustRegister(clearPasswordPlugin{})
+}
+
+// Register adds a plugin to registry which is consulted by both client and server,
+// and return the Method that represents it.
+func Register(p Plugin) (Method, error) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	for _, registered := range plugins {
+		if registered.Name() == p.Name() {
+			return 0, ErrPluginExisted
+		}
+	}
+	if len(plugins) > 0xff {
+		return 0, ErrTooManyPlugin
+	}
+	plugins = append(plugins, p)
+	return Method(len(plugins) - 1), nil
+}
+
+func mustRegister(p Plugin) Method {
+	m, err := Register(p)
+	if err != nil {
+		panic(fmt.Sprintf("auth: register plugin %s failed: %v", p.Name(), err))
+	}
+	return m
+}
+
+// Plugin return the registered plugin represented by m.
+func (m Method) Plugin() (Plugin, error) {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+
+	if int(m) >= len(plugins) {
+		return nil, ErrUnsupportedAuthenticationMethod
+	}
+	return plugins[m], nil
+}
+
+func lookupPlugin(name string) (Method, bool) {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+
+	for i, p := range plugins {
+		if p.Name() == name {
+			return Method(i), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"testing"
+)
+
+type fakeServerConn struct {
+	as    []byte
+	cache map[string][]byte
+}
+
+func (c *fakeServerConn) Key() string                           { return "root@%" }
+func (c *fakeServerConn) TLSed() bool                           { return false }
+func (c *fakeServerConn) SecureTransport() bool                 { return false }
+func (c *fakeServerConn) ReadPacket() ([]byte, error)           { return nil, nil }
+func (c *fakeServerConn) WriteMoreData(data []byte) error       { return nil }
+func (c *fakeServerConn) AuthenticationString() ([]byte, error) { return c.as, nil }
+func (c *fakeServerConn) RSAKeyPair() (*rsa.PrivateKey, []byte) { return nil, nil }
+func (c *fakeServerConn) Cache() Cache                          { return c }
+func (c *fakeServerConn) Put(key string, val []byte)            { c.cache[key] = val }
+func (c *fakeServerConn) Get(key string) []byte                 { return c.cache[key] }
+func (c *fakeServerConn) VerifyPassword(password []byte) error  { return nil }
+
+type testPlugin struct{ nativePasswordPlugin }
+
+func (testPlugin) Name() string { return "test_plugin" }
+
+func TestRegister(t *testing.T) {
+	m, err := Register(testPlugin{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.String() != "test_plugin" {
+		t.Fatalf("String() = %s", m.String())
+	}
+	parsed, err := ParseAuthenticationPlugin("test_plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != m {
+		t.Fatalf("ParseAuthenticationPlugin() = %d, want %d", parsed, m)
+	}
+
+	if _, err := Register(testPlugin{}); err != ErrPluginExisted {
+		t.Fatalf("Register() error = %v, want %v", err, ErrPluginExisted)
+	}
+}
+
+func TestBuiltinChallengeResponse(t *testing.T) {
+	for _, m := range []Method{MySQLNativePassword, CachingSha2Password} {
+		t.Run(m.String(), func(t *testing.T) {
+			p, err := m.Plugin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			password, salt := []byte("123456"), Bytes(20)
+			challengeData, err := m.GenerateChallengeData(password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			as, err := m.GenerateAuthenticationStringWithoutSalt(password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn := &fakeServerConn{as: as, cache: map[string][]byte{"root@%": challengeData}}
+
+			authRes, err := p.InitialResponse(&ClientContext{Password: password, AuthData: salt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Authenticate(conn, authRes, salt); err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+
+			authRes, _ = p.InitialResponse(&ClientContext{Password: []byte("wrong"), AuthData: salt})
+			if m == MySQLNativePassword {
+				if err := p.Authenticate(conn, authRes, salt); err != ErrMismatch {
+					t.Fatalf("Authenticate() error = %v, want %v", err, ErrMismatch)
+				}
+			}
+		})
+	}
+}
+
+func TestClearPassword(t *testing.T) {
+	p, err := ClearPassword.Plugin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.InitialResponse(&ClientContext{Password: []byte("123456")}); err != ErrCleartextPasswordNotAllowed {
+		t.Fatalf("InitialResponse() error = %v, want %v", err, ErrCleartextPasswordNotAllowed)
+	}
+
+	for _, c := range []*ClientContext{
+		{Password: []byte("123456"), TLSed: true},
+		{Password: []byte("123456"), AllowCleartextPasswords: true},
+	} {
+		authRes, err := p.InitialResponse(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(authRes) != "123456\x00" {
+			t.Fatalf("InitialResponse() = %q", authRes)
+		}
+	}
+}
+
+func TestNativePasswordWithoutPassword(t *testing.T) {
+	as, err := MySQLNativePassword.GenerateAuthenticationStringWithoutSalt(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 0 {
+		t.Fatalf("authentication string of empty password = %q, want empty", as)
+	}
+
+	p, err := MySQLNativePassword.Plugin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &fakeServerConn{as: as}
+	salt := Bytes(20)
+	authRes, err := p.InitialResponse(&ClientContext{AuthData: salt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Authenticate(conn, authRes, salt); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	authRes, err = p.InitialResponse(&ClientContext{Password: []byte("123456"), AuthData: salt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Authenticate(conn, authRes, salt); err != ErrMismatch {
+		t.Fatalf("Authenticate() error = %v, want %v", err, ErrMismatch)
+	}
+}
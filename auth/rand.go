@@ -2,6 +2,7 @@ package auth
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -12,9 +13,16 @@ const (
 	letterIdxMax  = 63 / letterIdxBits   // # of letter indices fitting in 63 bits
 )
 
-var src = rand.NewSource(time.Now().UnixNano())
+var (
+	// src isn't safe for concurrent use
+	srcMu sync.Mutex
+	src   = rand.NewSource(time.Now().UnixNano())
+)
 
 func Bytes(n int) []byte {
+	srcMu.Lock()
+	defer srcMu.Unlock()
+
 	b := make([]byte, n)
 	// A src.Int63() generates 63 random bits, enough for letterIdxMax characters!
 	for i, cache, remain := n-1, src.Int63(), letterIdxMax; i >= 0; {
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrPrivateKeyNotFound = errors.New("auth: private key not found")
+	ErrPublicKeyNotFound  = errors.New("auth: public key not found")
+)
+
+// EncryptPasswordWithPublicKey XOR password with seed and encrypt it using
+// RSA public key in PEM format sent by server.
+func EncryptPasswordWithPublicKey(password, seed, pubBytes []byte) ([]byte, error) {
+	block, rest := pem.Decode(pubBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no pem data found, data: %s", rest)
+	}
+	pkix, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := pkix.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not rsa public key")
+	}
+
+	plain := make([]byte, len(password)+1)
+	copy(plain, password)
+	for i := range plain {
+		j := i % len(seed)
+		plain[i] ^= seed[j]
+	}
+
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, plain, nil)
+}
+
+// DecryptPasswordWithPrivateKey is the reverse of EncryptPasswordWithPublicKey.
+func DecryptPasswordWithPrivateKey(privateKey *rsa.PrivateKey, data, seed []byte) ([]byte, error) {
+	if privateKey == nil {
+		return nil, ErrPrivateKeyNotFound
+	}
+	plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, privateKey, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range plain {
+		j := i % len(seed)
+		plain[i] ^= seed[j]
+	}
+	if len(plain) == 0 {
+		return nil, ErrMismatch
+	}
+
+	return plain[:len(plain)-1], nil
+}
+
+// readPasswordEncryptedWithPublicKey send public key to client and
+// read password encrypted with it.
+func readPasswordEncryptedWithPublicKey(conn ServerConn, seed []byte) ([]byte, error) {
+	privateKey, publicKeyBytes := conn.RSAKeyPair()
+	if len(publicKeyBytes) == 0 {
+		return nil, ErrPublicKeyNotFound
+	}
+	if err := conn.WriteMoreData(publicKeyBytes); err != nil {
+		return nil, err
+	}
+
+	data, err := conn.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	return DecryptPasswordWithPrivateKey(privateKey, data, seed)
+}
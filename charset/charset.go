@@ -4,6 +4,15 @@ const (
 	UTF8    = "utf8"
 	UTF8MB4 = "utf8mb4"
 	Binary  = "binary"
+	ASCII   = "ascii"
+	Latin1  = "latin1"
+	GBK     = "gbk"
+	GB18030 = "gb18030"
+	Big5    = "big5"
+	SJIS    = "sjis"
+	UCS2    = "ucs2"
+	UTF16   = "utf16"
+	UTF16LE = "utf16le"
 )
 
 type Charset struct {
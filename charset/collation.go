@@ -5,8 +5,49 @@ package charset
 
 const (
 	UTF8GeneralCi    = "utf8_general_ci"
+	UTF8Bin          = "utf8_bin"
+	UTF8UnicodeCi    = "utf8_unicode_ci"
 	UTF8MB4GeneralCi = "utf8mb4_general_ci"
+	UTF8MB4Bin       = "utf8mb4_bin"
+	UTF8MB4UnicodeCi = "utf8mb4_unicode_ci"
 	UTF8MB40900AiCi  = "utf8mb4_0900_ai_ci"
+	UTF8MB40900Bin   = "utf8mb4_0900_bin"
+
+	ASCIIGeneralCi = "ascii_general_ci"
+	ASCIIBin       = "ascii_bin"
+
+	Latin1German1Ci = "latin1_german1_ci"
+	Latin1SwedishCi = "latin1_swedish_ci"
+	Latin1DanishCi  = "latin1_danish_ci"
+	Latin1German2Ci = "latin1_german2_ci"
+	Latin1Bin       = "latin1_bin"
+	Latin1GeneralCi = "latin1_general_ci"
+	Latin1GeneralCs = "latin1_general_cs"
+	Latin1SpanishCi = "latin1_spanish_ci"
+
+	GBKChineseCi = "gbk_chinese_ci"
+	GBKBin       = "gbk_bin"
+
+	GB18030ChineseCi    = "gb18030_chinese_ci"
+	GB18030Bin          = "gb18030_bin"
+	GB18030Unicode520Ci = "gb18030_unicode_520_ci"
+
+	Big5ChineseCi = "big5_chinese_ci"
+	Big5Bin       = "big5_bin"
+
+	SJISJapaneseCi = "sjis_japanese_ci"
+	SJISBin        = "sjis_bin"
+
+	UCS2GeneralCi = "ucs2_general_ci"
+	UCS2Bin       = "ucs2_bin"
+	UCS2UnicodeCi = "ucs2_unicode_ci"
+
+	UTF16GeneralCi = "utf16_general_ci"
+	UTF16Bin       = "utf16_bin"
+	UTF16UnicodeCi = "utf16_unicode_ci"
+
+	UTF16LEGeneralCi = "utf16le_general_ci"
+	UTF16LEBin       = "utf16le_bin"
 )
 
 type Collation struct {
@@ -0,0 +1,61 @@
+package charset
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var (
+	encodings = map[string]encoding.Encoding{
+		// MySQL latin1 is cp1252 actually
+		// https://dev.mysql.com/doc/refman/8.0/en/charset-we-sets.html
+		Latin1:  charmap.Windows1252,
+		GBK:     simplifiedchinese.GBK,
+		GB18030: simplifiedchinese.GB18030,
+		Big5:    traditionalchinese.Big5,
+		SJIS:    japanese.ShiftJIS,
+		// ucs2 is subset of utf16, both are big-endian
+		UCS2:    unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+		UTF16:   unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+		UTF16LE: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	}
+)
+
+// Encoding returns encoding converting between the charset and UTF-8,
+// nil means no conversion is needed, such as utf8mb4, ascii and binary.
+func (c *Charset) Encoding() encoding.Encoding {
+	return encodings[c.name]
+}
+
+// IsClientAllowed reports whether the charset can be used as client character set.
+// https://dev.mysql.com/doc/refman/8.0/en/charset-connection.html#charset-connection-impermissible-client-charset
+func (c *Charset) IsClientAllowed() bool {
+	switch c.name {
+	case UCS2, UTF16, UTF16LE:
+		return false
+	default:
+		return true
+	}
+}
+
+// Decode converts bs in the charset to UTF-8.
+func (c *Charset) Decode(bs []byte) ([]byte, error) {
+	e := c.Encoding()
+	if e == nil {
+		return bs, nil
+	}
+	return e.NewDecoder().Bytes(bs)
+}
+
+// Encode converts UTF-8 bs to the charset.
+func (c *Charset) Encode(bs []byte) ([]byte, error) {
+	e := c.Encoding()
+	if e == nil {
+		return bs, nil
+	}
+	return e.NewEncoder().Bytes(bs)
+}
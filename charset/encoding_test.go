@@ -0,0 +1,46 @@
+package charset
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		collation string
+		data      []byte
+		want      string
+	}{
+		{Latin1SwedishCi, []byte{0x63, 0x61, 0x66, 0xe9, 0x80}, "café€"},
+		{GBKChineseCi, []byte{0xc4, 0xe3, 0xba, 0xc3}, "你好"},
+		{GB18030ChineseCi, []byte{0xc4, 0xe3, 0xba, 0xc3}, "你好"},
+		{Big5ChineseCi, []byte{0xa7, 0x41, 0xa6, 0x6e}, "你好"},
+		{SJISJapaneseCi, []byte{0x82, 0xa0}, "あ"},
+		{UCS2GeneralCi, []byte{0x4f, 0x60, 0x59, 0x7d}, "你好"},
+		{UTF16GeneralCi, []byte{0xd8, 0x3d, 0xde, 0x00}, "😀"},
+		{UTF8MB4GeneralCi, []byte("你好"), "你好"},
+	}
+
+	for _, test := range tests {
+		collation, err := GetCollationByName(test.collation)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := collation.Charset().Decode(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(decoded) != test.want {
+			t.Errorf("%s: got %q, want %q", test.collation, decoded, test.want)
+		}
+
+		encoded, err := collation.Charset().Encode([]byte(test.want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, test.data) {
+			t.Errorf("%s: encode got %x, want %x", test.collation, encoded, test.data)
+		}
+	}
+}
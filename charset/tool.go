@@ -29,9 +29,50 @@ func GetCollationByName(name string) (*Collation, error) {
 var (
 	collations = []*Collation{
 		{UTF8, true, 33, UTF8GeneralCi},
+		{UTF8, false, 83, UTF8Bin},
+		{UTF8, false, 192, UTF8UnicodeCi},
 		{UTF8MB4, false, 45, UTF8MB4GeneralCi},
+		{UTF8MB4, false, 46, UTF8MB4Bin},
+		{UTF8MB4, false, 224, UTF8MB4UnicodeCi},
 		{UTF8MB4, true, 255, UTF8MB40900AiCi},
+		{UTF8MB4, false, 309, UTF8MB40900Bin},
 		{Binary, true, 63, Binary},
+
+		{ASCII, true, 11, ASCIIGeneralCi},
+		{ASCII, false, 65, ASCIIBin},
+
+		{Latin1, false, 5, Latin1German1Ci},
+		{Latin1, true, 8, Latin1SwedishCi},
+		{Latin1, false, 15, Latin1DanishCi},
+		{Latin1, false, 31, Latin1German2Ci},
+		{Latin1, false, 47, Latin1Bin},
+		{Latin1, false, 48, Latin1GeneralCi},
+		{Latin1, false, 49, Latin1GeneralCs},
+		{Latin1, false, 94, Latin1SpanishCi},
+
+		{GBK, true, 28, GBKChineseCi},
+		{GBK, false, 87, GBKBin},
+
+		{GB18030, true, 248, GB18030ChineseCi},
+		{GB18030, false, 249, GB18030Bin},
+		{GB18030, false, 250, GB18030Unicode520Ci},
+
+		{Big5, true, 1, Big5ChineseCi},
+		{Big5, false, 84, Big5Bin},
+
+		{SJIS, true, 13, SJISJapaneseCi},
+		{SJIS, false, 88, SJISBin},
+
+		{UCS2, true, 35, UCS2GeneralCi},
+		{UCS2, false, 90, UCS2Bin},
+		{UCS2, false, 128, UCS2UnicodeCi},
+
+		{UTF16, true, 54, UTF16GeneralCi},
+		{UTF16, false, 55, UTF16Bin},
+		{UTF16, false, 101, UTF16UnicodeCi},
+
+		{UTF16LE, true, 56, UTF16LEGeneralCi},
+		{UTF16LE, false, 62, UTF16LEBin},
 	}
 
 	collationNameMap = map[string]*Collation{}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/packet"
+	"io"
+	"strings"
+)
+
+// Process is a row of SHOW PROCESSLIST or COM_PROCESS_INFO response.
+// https://dev.mysql.com/doc/refman/8.0/en/show-processlist.html
+type Process struct {
+	Id      uint64
+	User    string
+	Host    string
+	DB      string
+	Command string
+	Time    int64
+	State   string
+	Info    string
+}
+
+// Statistics sends COM_STATISTICS and return parsed server status.
+func (c *Conn) Statistics() (*packet.Statistics, error) {
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComStatistics, nil)); err != nil {
+		return nil, err
+	}
+
+	data, err := c.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 && packet.IsErr(data) {
+		return nil, c.handleOKERRPacket(data)
+	}
+	return packet.ParseStatistics(data)
+}
+
+// ProcessInfo sends COM_PROCESS_INFO, it is deprecated by server in favor of SHOW PROCESSLIST.
+func (c *Conn) ProcessInfo() ([]*Process, error) {
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComProcessInfo, nil)); err != nil {
+		return nil, err
+	}
+	rows, err := c.readRows()
+	if err != nil {
+		return nil, err
+	}
+	return scanProcesses(rows)
+}
+
+// ProcessList executes SHOW PROCESSLIST, full means SHOW FULL PROCESSLIST.
+func (c *Conn) ProcessList(full bool) ([]*Process, error) {
+	query := "SHOW PROCESSLIST"
+	if full {
+		query = "SHOW FULL PROCESSLIST"
+	}
+	rows, err := c.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	return scanProcesses(rows)
+}
+
+// Kill sends COM_PROCESS_KILL to terminate the connection.
+func (c *Conn) Kill(connId uint32) error {
+	data := packet.FixedLengthInteger.Dump(uint64(connId), 4)
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComProcessKill, data)); err != nil {
+		return err
+	}
+	return c.readOKERRPacket()
+}
+
+// Refresh sends COM_REFRESH, it is similar to FLUSH statement.
+func (c *Conn) Refresh(r flag.Refresh) error {
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComRefresh, []byte{byte(r)})); err != nil {
+		return err
+	}
+	return c.readOKERRPacket()
+}
+
+// Debug sends COM_DEBUG to make server dump debug information to error log.
+func (c *Conn) Debug() error {
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComDebug, nil)); err != nil {
+		return err
+	}
+	return c.readEOFOKERRPacket()
+}
+
+// Shutdown sends COM_SHUTDOWN to shut down server.
+func (c *Conn) Shutdown() error {
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComShutdown, []byte{byte(flag.ShutdownDefault)})); err != nil {
+		return err
+	}
+	return c.readEOFOKERRPacket()
+}
+
+// SetOption sends COM_SET_OPTION, such as toggling multi statements.
+func (c *Conn) SetOption(o flag.SetOption) error {
+	data := packet.FixedLengthInteger.Dump(uint64(o), 2)
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComSetOption, data)); err != nil {
+		return err
+	}
+	if err := c.readEOFOKERRPacket(); err != nil {
+		return err
+	}
+
+	switch o {
+	case flag.MultiStatementsOn:
+		c.mysqlConn.SetCapabilities(c.Capabilities() | flag.ClientMultiStatements)
+	case flag.MultiStatementsOff:
+		c.mysqlConn.SetCapabilities(c.Capabilities() &^ flag.ClientMultiStatements)
+	}
+	return nil
+}
+
+func (c *Conn) readEOFOKERRPacket() error {
+	data, err := c.ReadPacket()
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && packet.IsEOF(data) {
+		eofPkt, err := packet.ParseEOF(data, c.Capabilities())
+		if err != nil {
+			return err
+		}
+		c.status = eofPkt.StatusFlags
+		return nil
+	}
+	return c.handleOKERRPacket(data)
+}
+
+func scanProcesses(rows *Rows) ([]*Process, error) {
+	columns := rows.Columns()
+
+	var processes []*Process
+	for {
+		values, err := rows.NextRaw()
+		if err != nil {
+			if err == io.EOF {
+				return processes, nil
+			}
+			return nil, err
+		}
+
+		p := new(Process)
+		for i, column := range columns {
+			v := values[i]
+			if v.IsNull() {
+				continue
+			}
+			switch strings.ToLower(column.Name) {
+			case "id":
+				if p.Id, err = v.Uint64(); err != nil {
+					return nil, err
+				}
+			case "user":
+				p.User = v.String()
+			case "host":
+				p.Host = v.String()
+			case "db":
+				p.DB = v.String()
+			case "command":
+				p.Command = v.String()
+			case "time":
+				if p.Time, err = v.Int64(); err != nil {
+					return nil, err
+				}
+			case "state":
+				p.State = v.String()
+			case "info":
+				p.Info = v.String()
+			}
+		}
+		processes = append(processes, p)
+	}
+}
+
+// InitDB sends COM_INIT_DB to change current database, it is like USE statement.
+func (c *Conn) InitDB(database string) error {
+	data, err := c.encode(database)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComInitDB, data)); err != nil {
+		return err
+	}
+	return c.readOKERRPacket()
+}
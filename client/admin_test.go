@@ -0,0 +1,214 @@
+package client
+
+import (
+	"bytes"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"github.com/vczyh/mysql-protocol/server"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"strings"
+	"testing"
+)
+
+// adminHandler responds SHOW PROCESSLIST with sessions of processes, and commands server
+// doesn't handle by other, data of other doesn't include command.
+type adminHandler struct {
+	server.DefaultHandler
+	processes [][]interface{}
+	other     func(session *server.Session, data []byte) error
+}
+
+func (h *adminHandler) Query(session *server.Session, query string) (interface{}, error) {
+	switch query {
+	case "SHOW PROCESSLIST", "SHOW FULL PROCESSLIST":
+		return h.processList()
+	case "SELECT DATABASE()":
+		return server.NewSimpleResultSet([]string{"DATABASE()"}, [][]interface{}{{session.Database()}})
+	}
+	return h.DefaultHandler.Query(session, query)
+}
+
+func (h *adminHandler) processList() (*server.ResultSet, error) {
+	return server.NewSimpleResultSet([]string{"Id", "User", "Host", "db", "Command", "Time", "State", "Info"}, h.processes)
+}
+
+func (h *adminHandler) Other(session *server.Session, data []byte) {
+	if h.other == nil {
+		h.DefaultHandler.Other(session, data)
+		return
+	}
+	if err := h.other(session, data); err != nil {
+		panic(err)
+	}
+}
+
+func connectAdmin(t *testing.T, h server.Handler) (*server.Server, *Conn) {
+	t.Helper()
+	srv := servertest.NewServer(h, nil)
+	conn, err := CreateConnection(WithUser("root"), WithDialer(servertest.Dialer(srv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, conn
+}
+
+func expectCode(t *testing.T, err error, c code.Err) {
+	t.Helper()
+	if errPkt, ok := err.(*packet.ERR); !ok || errPkt.ErrorCode != c {
+		t.Fatalf("expected error %d, got %v", c, err)
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	_, conn := connectAdmin(t, server.NewDefaultHandler())
+	if err := conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := conn.Statistics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Threads != 1 {
+		t.Fatalf("expected 1 thread, got %d", stats.Threads)
+	}
+}
+
+func TestProcessList(t *testing.T) {
+	h := &adminHandler{processes: [][]interface{}{
+		{uint64(1), "root", "localhost:5000", "app", "Query", int64(0), "starting", "SHOW PROCESSLIST"},
+		{uint64(2), "app", "10.0.0.1:5001", nil, "Sleep", int64(30), "", nil},
+	}}
+	h.other = func(session *server.Session, data []byte) error {
+		rs, err := h.processList()
+		if err != nil {
+			return err
+		}
+		return rs.WriteText(session.Conn())
+	}
+	_, conn := connectAdmin(t, h)
+
+	expected := []*Process{
+		{Id: 1, User: "root", Host: "localhost:5000", DB: "app", Command: "Query", State: "starting", Info: "SHOW PROCESSLIST"},
+		{Id: 2, User: "app", Host: "10.0.0.1:5001", Command: "Sleep", Time: 30},
+	}
+	for _, f := range []func() ([]*Process, error){
+		func() ([]*Process, error) { return conn.ProcessList(false) },
+		func() ([]*Process, error) { return conn.ProcessList(true) },
+		conn.ProcessInfo,
+	} {
+		processes, err := f()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(processes) != len(expected) {
+			t.Fatalf("expected %d processes, got %d", len(expected), len(processes))
+		}
+		for i, p := range processes {
+			if *p != *expected[i] {
+				t.Fatalf("process %d: expected %+v, got %+v", i, expected[i], p)
+			}
+		}
+	}
+}
+
+func TestKill(t *testing.T) {
+	srv, conn := connectAdmin(t, server.NewDefaultHandler())
+	other, err := CreateConnection(WithUser("root"), WithDialer(servertest.Dialer(srv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	if err := conn.Kill(other.ConnectionId()); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Ping(); err == nil {
+		t.Fatal("killed connection is still alive")
+	}
+	expectCode(t, conn.Kill(other.ConnectionId()), code.ErrNoSuchThread)
+	if err := conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshDebugShutdown(t *testing.T) {
+	var received [][]byte
+	h := &adminHandler{other: func(session *server.Session, data []byte) error {
+		received = append(received, append([]byte(nil), data...))
+		// COM_REFRESH is responded with OK, COM_DEBUG and COM_SHUTDOWN with EOF
+		if len(data) == 1 && data[0] == byte(flag.RefreshTables|flag.RefreshHosts) {
+			return (&mysql.Result{Status: session.Status()}).Write(session.Conn())
+		}
+		return session.Conn().WritePacket(packet.NewEOF(0, session.Status()))
+	}}
+	_, conn := connectAdmin(t, h)
+
+	if err := conn.Refresh(flag.RefreshTables | flag.RefreshHosts); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Debug(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]byte{{byte(flag.RefreshTables | flag.RefreshHosts)}, {}, {byte(flag.ShutdownDefault)}}
+	if len(received) != len(expected) {
+		t.Fatalf("expected %d commands, got %d", len(expected), len(received))
+	}
+	for i := range expected {
+		if !bytes.Equal(received[i], expected[i]) {
+			t.Fatalf("command %d: expected data %v, got %v", i, expected[i], received[i])
+		}
+	}
+
+	// ERR of server is returned
+	_, unsupported := connectAdmin(t, server.NewDefaultHandler())
+	if err := unsupported.Debug(); err == nil || !strings.Contains(err.Error(), "unsupported command") {
+		t.Fatalf("expected unsupported command error, got %v", err)
+	}
+	if err := unsupported.Ping(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetOption(t *testing.T) {
+	_, conn := connectAdmin(t, server.NewDefaultHandler())
+	if err := conn.SetOption(flag.MultiStatementsOn); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Capabilities()&flag.ClientMultiStatements == 0 {
+		t.Fatal("multi statements is off after turning it on")
+	}
+	if err := conn.SetOption(flag.MultiStatementsOff); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Capabilities()&flag.ClientMultiStatements != 0 {
+		t.Fatal("multi statements is on after turning it off")
+	}
+	expectCode(t, conn.SetOption(flag.SetOption(2)), code.ErrUnknownComError)
+}
+
+func TestInitDB(t *testing.T) {
+	_, conn := connectAdmin(t, &adminHandler{})
+	if err := conn.InitDB("app"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := conn.Query("SELECT DATABASE()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := rows.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if database := row[0].String(); database != "app" {
+		t.Fatalf("expected database app, got %q", database)
+	}
+	if _, err := rows.Next(); err == nil {
+		t.Fatal("expected end of rows")
+	}
+}
@@ -1,58 +1,79 @@
 package client
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha1"
-	"crypto/x509"
-	"encoding/pem"
-	"fmt"
 	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/mysql"
 	"github.com/vczyh/mysql-protocol/packet"
 )
 
+// auth performs the rest of authentication after HandshakeResponse packet is sent,
+// it consults the plugin registry for every AuthSwitchRequest and AuthMoreData packet.
 func (c *Conn) auth(method auth.Method, authData []byte) error {
-	data, err := c.ReadPacket()
+	plugin, err := method.Plugin()
 	if err != nil {
 		return err
 	}
 
-	if packet.IsAuthSwitchRequest(data) {
-		return c.handleAuthSwitchRequestPacket(data)
+	switched := false
+	for {
+		data, err := c.ReadPacket()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return packet.ErrPacketData
+		}
+
+		switch {
+		case packet.IsOK(data) || packet.IsErr(data):
+			return c.handleOKERRPacket(data)
+
+		case packet.IsAuthSwitchRequest(data) && !switched:
+			switched = true
+			if plugin, authData, err = c.handleAuthSwitchRequestPacket(data); err != nil {
+				return err
+			}
+
+		case packet.IsAuthMoreData(data):
+			pluginData, err := packet.ParseAuthMoreData(data)
+			if err != nil {
+				return err
+			}
+			res, err := plugin.MoreData(c.authContext(authData), pluginData)
+			if err != nil {
+				return err
+			}
+			if res != nil {
+				if err := c.WritePacket(packet.NewSimple(res)); err != nil {
+					return err
+				}
+			}
+
+		default:
+			return packet.ErrPacketData
+		}
 	}
-	return c.finalAuth(method, data, authData)
 }
 
-func (c *Conn) handleAuthSwitchRequestPacket(data []byte) error {
+func (c *Conn) handleAuthSwitchRequestPacket(data []byte) (auth.Plugin, []byte, error) {
 	switchPkt, err := packet.ParseAuthSwitchRequest(data)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	method := switchPkt.AuthPlugin
-	authData := switchPkt.AuthData[:len(switchPkt.AuthData)-1]
-	if err = c.writeAuthSwitchResponsePacket(method, authData); err != nil {
-		return err
-	}
-
-	data, err = c.ReadPacket()
+	plugin, err := switchPkt.AuthPlugin.Plugin()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return c.finalAuth(method, data, authData)
-}
 
-func (c *Conn) finalAuth(method auth.Method, data, authData []byte) error {
-	switch method {
-	case auth.MySQLNativePassword:
-		return c.handleOKERRPacket(data)
-	case auth.SHA256Password:
-		return c.sha256Authentication(data, authData)
-	case auth.CachingSha2Password:
-		return c.cachingSHA2Authentication(data, authData)
-	default:
-		return auth.ErrUnsupportedAuthenticationMethod
+	authData := switchPkt.AuthData
+	if len(authData) > 0 && authData[len(authData)-1] == 0x00 {
+		authData = authData[:len(authData)-1]
+	}
+	if err = c.writeAuthSwitchResponsePacket(switchPkt.AuthPlugin, authData); err != nil {
+		return nil, nil, err
 	}
+	return plugin, authData, nil
 }
 
 func (c *Conn) writeAuthSwitchResponsePacket(method auth.Method, authData []byte) (err error) {
@@ -64,114 +85,20 @@ func (c *Conn) writeAuthSwitchResponsePacket(method auth.Method, authData []byte
 }
 
 func (c *Conn) generateAuthRes(method auth.Method, authData []byte) (authRes []byte, err error) {
-	switch method {
-	case auth.MySQLNativePassword, auth.CachingSha2Password:
-		if c.password == "" {
-			return nil, nil
-		}
-		return method.EncryptPassword([]byte(c.password), authData)
-
-	case auth.SHA256Password:
-		if c.password == "" {
-			return []byte{0x00}, nil
-		}
-		if c.mysqlConn.TLSed() {
-			return append([]byte(c.password), 0x00), nil
-		}
-		// request public key from server
-		return []byte{0x01}, nil
-
-	default:
-		return nil, auth.ErrUnsupportedAuthenticationMethod
-	}
-}
-
-func (c *Conn) sha256Authentication(data, authData []byte) error {
-	pluginData, err := packet.ParseAuthMoreData(data)
+	plugin, err := method.Plugin()
 	if err != nil {
-		return err
-	}
-	if err := c.writePasswordEncryptedWithPublicKeyPacket(pluginData, authData); err != nil {
-		return err
-	}
-	return c.readOKERRPacket()
-}
-
-func (c *Conn) cachingSHA2Authentication(data, authData []byte) error {
-	switch {
-	case packet.IsOK(data) || packet.IsErr(data):
-		return c.handleOKERRPacket(data)
-	case packet.IsAuthMoreData(data):
-		pluginData, err := packet.ParseAuthMoreData(data)
-		if err != nil {
-			return err
-		}
-		// https://dev.mysql.com/blog-archive/preparing-your-community-connector-for-mysql-8-part-2-sha256/
-		// https://dev.mysql.com/doc/dev/mysql-server/latest/page_caching_sha2_authentication_exchanges.html
-		switch pluginData[0] {
-		// fast authentication
-		case 0x03:
-			return c.readOKERRPacket()
-		// full authentication
-		case 0x04:
-			// TODO if TLSed
-			// request public key
-			pubKeyData, err := c.requestPublicKey()
-			if err != nil {
-				return err
-			}
-			// send encrypted password
-			if err := c.writePasswordEncryptedWithPublicKeyPacket(pubKeyData, authData); err != nil {
-				return err
-			}
-			return c.readOKERRPacket()
-		}
-	}
-
-	return packet.ErrPacketData
-}
-
-func (c *Conn) requestPublicKey() ([]byte, error) {
-	simplePkt := packet.NewSimple([]byte{0x02})
-	if err := c.WritePacket(simplePkt); err != nil {
 		return nil, err
 	}
-
-	data, err := c.ReadPacket()
-	if err != nil {
-		return nil, err
-	}
-	pluginData, err := packet.ParseAuthMoreData(data)
-	if err != nil {
-		return nil, err
-	}
-
-	return pluginData, nil
+	return plugin.InitialResponse(c.authContext(authData))
 }
 
-func (c *Conn) writePasswordEncryptedWithPublicKeyPacket(pubBytes []byte, seed []byte) error {
-	block, rest := pem.Decode(pubBytes)
-	if block == nil {
-		return fmt.Errorf("no pem data found, data: %s", rest)
-	}
-	pkix, err := x509.ParsePKIXPublicKey(block.Bytes)
-	if err != nil {
-		return err
-	}
-
-	plain := make([]byte, len(c.password)+1)
-	copy(plain, c.password)
-	for i := range plain {
-		j := i % len(seed)
-		plain[i] ^= seed[j]
-	}
+func (c *Conn) authContext(authData []byte) *auth.ClientContext {
+	return &auth.ClientContext{
+		Password: []byte(c.password),
+		AuthData: authData,
+		TLSed:    c.mysqlConn.TLSed(),
 
-	h := sha1.New()
-	encryptedPassword, err := rsa.EncryptOAEP(h, rand.Reader, pkix.(*rsa.PublicKey), plain, nil)
-	if err != nil {
-		return err
+		SecureTransport:         mysql.IsSecureTransport(c.mysqlConn),
+		AllowCleartextPasswords: c.allowCleartextPasswords,
 	}
-
-	simplePkt := packet.NewSimple(encryptedPassword)
-	return c.WritePacket(simplePkt)
 }
@@ -1,13 +1,14 @@
 package client
 
 import (
-	"fmt"
+	"errors"
 	"github.com/vczyh/mysql-protocol/auth"
 	"github.com/vczyh/mysql-protocol/charset"
 	"github.com/vczyh/mysql-protocol/flag"
 	"github.com/vczyh/mysql-protocol/mysql"
 	"github.com/vczyh/mysql-protocol/packet"
 	"net"
+	"strconv"
 	"time"
 )
 
@@ -15,6 +16,10 @@ const (
 	maxPacketSize = 1<<24 - 1
 )
 
+var (
+	ErrClientCharset = errors.New("client: character set can't be used as client character set")
+)
+
 type Conn struct {
 	host      string
 	port      int
@@ -24,17 +29,31 @@ type Conn struct {
 	attrs     map[string]string
 	collation *charset.Collation
 
+	// decode character string columns to UTF-8 Go string
+	utf8Strings bool
+
 	useSSL             bool
 	insecureSkipVerify bool
 	sslCA              string
 	sslCert            string
 	sslKey             string
 
+	allowCleartextPasswords bool
+	allowExpiredPasswords   bool
+
+	interceptors []Interceptor
+
+	dial func(network, address string) (net.Conn, error)
+
 	mysqlConn mysql.Conn
+	connId    uint32
 
 	status       flag.Status
 	affectedRows uint64
 	lastInsertId uint64
+
+	// current transaction started by Begin
+	tx *Tx
 }
 
 func CreateConnection(opts ...Option) (*Conn, error) {
@@ -47,13 +66,23 @@ func CreateConnection(opts ...Option) (*Conn, error) {
 		return nil, err
 	}
 
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", c.host, c.port))
-	if err != nil {
-		return nil, err
+	err := c.intercept(&Call{Op: OpConnect}, func() error {
+		conn, err := c.dial("tcp", net.JoinHostPort(c.host, strconv.Itoa(c.port)))
+		if err != nil {
+			return err
+		}
+		c.mysqlConn = mysql.NewClientConnection(conn, c.defaultCapabilities())
+		return c.connect()
+	})
+	if err == nil && c.mysqlConn == nil {
+		err = ErrShortCircuited
 	}
+	return c, err
+}
 
-	c.mysqlConn = mysql.NewClientConnection(conn, c.defaultCapabilities())
-	return c, c.dial()
+// ConnectionId returns thread id assigned by server in handshake.
+func (c *Conn) ConnectionId() uint32 {
+	return c.connId
 }
 
 func (c *Conn) Capabilities() flag.Capability {
@@ -68,6 +97,11 @@ func (c *Conn) LastInsertId() uint64 {
 	return c.lastInsertId
 }
 
+// Status return status flags of the last OK or EOF packet sent by server.
+func (c *Conn) Status() flag.Status {
+	return c.status
+}
+
 func (c *Conn) ReadPacket() ([]byte, error) {
 	return c.mysqlConn.ReadPacket()
 }
@@ -81,6 +115,10 @@ func (c *Conn) WriteCommandPacket(pkt packet.Packet) error {
 }
 
 func (c *Conn) Ping() error {
+	return c.intercept(&Call{Op: OpPing}, c.ping)
+}
+
+func (c *Conn) ping() error {
 	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComPing, nil)); err != nil {
 		return err
 	}
@@ -96,6 +134,9 @@ func (c *Conn) build() error {
 	if c.loc == nil {
 		c.loc = time.Local
 	}
+	if c.dial == nil {
+		c.dial = net.Dial
+	}
 	if c.collation == nil {
 		collation, err := charset.GetCollationByName(charset.UTF8MB4GeneralCi)
 		if err != nil {
@@ -103,9 +144,17 @@ func (c *Conn) build() error {
 		}
 		c.collation = collation
 	}
+	if !c.collation.Charset().IsClientAllowed() {
+		return ErrClientCharset
+	}
 	return nil
 }
 
+// encode converts UTF-8 statement to connection character set.
+func (c *Conn) encode(s string) ([]byte, error) {
+	return c.collation.Charset().Encode([]byte(s))
+}
+
 func (c *Conn) quit() error {
 	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComQuit, nil)); err != nil {
 		return err
@@ -119,7 +168,7 @@ func (c *Conn) quit() error {
 	return nil
 }
 
-func (c *Conn) dial() error {
+func (c *Conn) connect() error {
 	hs, err := c.handleHandshakePacket()
 	if err != nil {
 		return err
@@ -152,6 +201,7 @@ func (c *Conn) handleHandshakePacket() (*packet.Handshake, error) {
 		return nil, err
 	}
 
+	c.connId = pkt.ConnectionId
 	if pkt.GetCapabilities()&flag.ClientSSL != 0 && c.useSSL {
 		c.mysqlConn.SetCapabilities(c.Capabilities() | flag.ClientSSL)
 	}
@@ -185,7 +235,7 @@ func (c *Conn) writeHandshakeResponsePacket(method auth.Method, authData []byte)
 }
 
 func (c *Conn) defaultCapabilities() flag.Capability {
-	return flag.ClientProtocol41 |
+	capabilities := flag.ClientProtocol41 |
 		flag.ClientSecureConnection |
 		flag.ClientPluginAuth |
 		flag.ClientLongPassword |
@@ -193,6 +243,10 @@ func (c *Conn) defaultCapabilities() flag.Capability {
 		flag.ClientTransactions |
 		flag.ClientInteractive |
 		flag.ClientMultiResults
+	if c.allowExpiredPasswords {
+		capabilities |= flag.ClientCanHandleExpiredPasswords
+	}
+	return capabilities
 }
 
 func (c *Conn) readUntilEOFPacket() error {
@@ -294,6 +348,22 @@ func WithCollation(collation *charset.Collation) Option {
 	})
 }
 
+// WithDialer replaces net.Dial to create the underlying connection,
+// such as dialing through a tunnel or a fake server of mysqltest.
+func WithDialer(dial func(network, address string) (net.Conn, error)) Option {
+	return optionFun(func(c *Conn) {
+		c.dial = dial
+	})
+}
+
+// WithUTF8Strings returns values of character string columns as Go string normalized to UTF-8
+// according to column collation, instead of raw []byte.
+func WithUTF8Strings(utf8Strings bool) Option {
+	return optionFun(func(c *Conn) {
+		c.utf8Strings = utf8Strings
+	})
+}
+
 func WithUseSSL(useSSL bool) Option {
 	return optionFun(func(c *Conn) {
 		c.useSSL = useSSL
@@ -324,6 +394,22 @@ func WithSSLKey(sslKey string) Option {
 	})
 }
 
+// WithAllowCleartextPasswords allows mysql_clear_password plugin to send
+// password in cleartext when connection is not TLS.
+func WithAllowCleartextPasswords(allow bool) Option {
+	return optionFun(func(c *Conn) {
+		c.allowCleartextPasswords = allow
+	})
+}
+
+// WithAllowExpiredPasswords connects to account whose password is expired in sandbox mode,
+// where password can be changed by ALTER USER, like --connect-expired-password of mysql.
+func WithAllowExpiredPasswords(allow bool) Option {
+	return optionFun(func(c *Conn) {
+		c.allowExpiredPasswords = allow
+	})
+}
+
 type Option interface {
 	apply(*Conn)
 }
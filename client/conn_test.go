@@ -1,89 +1,144 @@
 package client
 
 import (
-	"fmt"
-	"github.com/vczyh/mysql-protocol/charset"
-	"io"
-	"log"
-	"math/rand"
+	"flag"
+	"github.com/vczyh/mysql-protocol/mysqltest"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
-var c *Conn
+var record = flag.Bool("record", false, "record sessions into testdata from server at MYSQL_TEST_ADDR")
 
-func TestMain(m *testing.M) {
-	var err error
-	c, err = CreateConnection(
-		WithHost("10.0.44.59"),
-		WithPort(3306),
-		WithUser("root"),
-		WithPassword("Unicloud@1221"),
+// connect returns connection replaying testdata/<test name>.json.
+//
+// With -record, it connects to the live server at MYSQL_TEST_ADDR, such as 127.0.0.1:3306,
+// as root with password MYSQL_TEST_PASSWORD, and the session is saved after the test,
+// so tests must be deterministic. TLS sessions can't be recorded.
+func connect(t *testing.T) *Conn {
+	t.Helper()
+	name := filepath.Join("testdata", t.Name()+".json")
+	opts := []Option{WithUser("root"), WithPassword(os.Getenv("MYSQL_TEST_PASSWORD"))}
 
-		WithCollation(charset.Utf8mb40900AiCi),
+	if *record {
+		addr := os.Getenv("MYSQL_TEST_ADDR")
+		if addr == "" {
+			t.Fatal("MYSQL_TEST_ADDR is required by -record")
+		}
+		var rec *mysqltest.Conn
+		conn, err := CreateConnection(append(opts, WithDialer(func(network, address string) (net.Conn, error) {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			rec = mysqltest.Record(conn, mysqltest.ClientToServer)
+			return rec, nil
+		}))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if err := conn.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := rec.Session().WriteFile(name); err != nil {
+				t.Fatal(err)
+			}
+		})
+		return conn
+	}
 
-		WithUseSSL(true),
-		WithInsecureSkipVerify(true),
-		WithSSLCA("tmp/ca.pem"),
-		WithSSLCert("tmp/client-cert.pem"),
-		WithSSLKey("tmp/client-key.pem"),
-	)
+	session, err := mysqltest.ReadFile(name)
 	if err != nil {
-		log.Fatalf("CreateConnection(): %v", err)
+		t.Fatal(err)
 	}
-
-	setup()
-	m.Run()
-	shutdown()
-}
-
-func setup() {}
-
-func shutdown() {
-	if err := c.Close(); err != nil {
-		log.Fatalf("Close(): %v", err)
+	p := mysqltest.NewPlayer(session)
+	conn, err := CreateConnection(append(opts, WithDialer(p.Dial))...)
+	if err != nil {
+		t.Fatal(err)
 	}
+	t.Cleanup(func() {
+		conn.Close()
+		if err := p.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return conn
 }
 
 func TestPing(t *testing.T) {
+	c := connect(t)
 	if err := c.Ping(); err != nil {
 		t.Fatal(err)
 	}
 }
 
 func TestExecute(t *testing.T) {
-	name := fmt.Sprintf("dbtest_%d", rand.Int())
-	rs, err := c.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", name))
+	c := connect(t)
+	rs, err := c.Exec("CREATE DATABASE IF NOT EXISTS dbtest_execute")
 	if err != nil {
 		t.Fatalf("Exec(): %v", err)
 	}
-	t.Log(rs.AffectedRows, rs.LastInsertId)
+	if rs.AffectedRows != 1 {
+		t.Fatalf("AffectedRows = %d, want 1", rs.AffectedRows)
+	}
 
-	rs, err = c.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", name))
-	if err != nil {
+	if _, err := c.Exec("DROP DATABASE IF EXISTS dbtest_execute"); err != nil {
 		t.Fatalf("Exec(): %v", err)
 	}
-	t.Log(rs.AffectedRows, rs.LastInsertId)
+	if _, err := c.Exec("DROP DATABASE dbtest_execute"); err == nil {
+		t.Fatal("expected error of dropping missing database")
+	}
 }
 
 func TestQuery(t *testing.T) {
-	//Rows, err := c.Query("SELECT @@version_comment")
-	rows, err := c.Query("SHOW COLUMNS FROM mysql.user")
+	c := connect(t)
+	rows, err := c.Query("SELECT 1 + 2 AS sum, CONCAT('a', 'b') AS str")
 	if err != nil {
 		t.Fatalf("Query(): %v", err)
 	}
+	columns := rows.Columns()
+	if len(columns) != 2 || columns[0].Name != "sum" || columns[1].Name != "str" {
+		t.Fatalf("unexpected columns: %v", columns)
+	}
 
-	for _, column := range rows.Columns() {
-		t.Log(column.String())
+	row, err := rows.Next()
+	if err != nil {
+		t.Fatalf("Rows.Next(): %v", err)
+	}
+	if row[0].String() != "3" || row[1].String() != "ab" {
+		t.Fatalf("unexpected row: %v", row)
 	}
+	if _, err := rows.Next(); err == nil {
+		t.Fatal("expected end of rows")
+	}
+}
 
-	for {
-		row, err := rows.Next()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			t.Fatalf("Rows.Next(): %v", err)
-		}
-		t.Log(row)
+func TestTransaction(t *testing.T) {
+	c := connect(t)
+	tx, err := c.Begin(&TxOptions{Isolation: LevelReadCommitted})
+	if err != nil {
+		t.Fatalf("Begin(): %v", err)
+	}
+	if err := tx.Savepoint("sp1"); err != nil {
+		t.Fatalf("Savepoint(): %v", err)
+	}
+	if err := tx.RollbackTo("sp1"); err != nil {
+		t.Fatalf("RollbackTo(): %v", err)
+	}
+	if err := tx.Release("sp1"); err != nil {
+		t.Fatalf("Release(): %v", err)
+	}
+
+	// DDL causes an implicit commit
+	if _, err := tx.Exec("CREATE DATABASE IF NOT EXISTS dbtest_transaction"); err != nil {
+		t.Fatalf("Exec(): %v", err)
+	}
+	if err := tx.Commit(); err != ErrTxImplicitCommit {
+		t.Fatalf("Commit() error = %v, want %v", err, ErrTxImplicitCommit)
+	}
+	if _, err := c.Exec("DROP DATABASE IF EXISTS dbtest_transaction"); err != nil {
+		t.Fatalf("Exec(): %v", err)
 	}
 }
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrShortCircuited returned when an interceptor does not call next and
+	// returns nil error for call that must produce result, such as Query.
+	ErrShortCircuited = errors.New("client: call short-circuited by interceptor")
+)
+
+// Operation is the kind of intercepted call.
+type Operation uint8
+
+const (
+	OpConnect Operation = iota
+	OpPing
+	OpExec
+	OpQuery
+	OpPrepare
+	OpStmtExec
+	OpStmtQuery
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OpConnect:
+		return "Connect"
+	case OpPing:
+		return "Ping"
+	case OpExec:
+		return "Exec"
+	case OpQuery:
+		return "Query"
+	case OpPrepare:
+		return "Prepare"
+	case OpStmtExec:
+		return "StmtExec"
+	case OpStmtQuery:
+		return "StmtQuery"
+	default:
+		return "Unknown Operation"
+	}
+}
+
+// Call describes an intercepted call of Conn.
+type Call struct {
+	Op Operation
+
+	// Statement is query of Exec and Query, or prepared statement.
+	Statement string
+	// Args is arguments of prepared statement.
+	Args []interface{}
+
+	// ConnectionId is zero before handshake of OpConnect.
+	ConnectionId uint32
+
+	// The following fields are filled when next returns, Err and Duration are set by every
+	// interceptor level, so they are what next returned even if inner interceptor short-circuits
+	// the call or replaces the error.
+	//
+	// Duration of Query does not include reading rows.
+	Start        time.Time
+	Duration     time.Duration
+	AffectedRows uint64
+	LastInsertId uint64
+	Err          error
+}
+
+// Interceptor wraps Connect, Ping, Exec, Query and Prepare of Conn.
+//
+// Intercept should call next to continue the chain. It can short-circuit the call by
+// returning without calling next, then the returned error is returned to caller.
+// Exec and Ping succeed with AffectedRows and LastInsertId of call if the returned error is nil.
+type Interceptor interface {
+	Intercept(call *Call, next func() error) error
+}
+
+// InterceptorFunc adapts function to Interceptor.
+type InterceptorFunc func(call *Call, next func() error) error
+
+func (f InterceptorFunc) Intercept(call *Call, next func() error) error {
+	return f(call, next)
+}
+
+// intercept runs fn through the interceptor chain.
+func (c *Conn) intercept(call *Call, fn func() error) error {
+	if len(c.interceptors) == 0 {
+		return fn()
+	}
+
+	call.ConnectionId = c.connId
+	call.Start = time.Now()
+
+	var run func(i int) error
+	run = func(i int) error {
+		var err error
+		if i == len(c.interceptors) {
+			err = fn()
+			call.ConnectionId = c.connId
+		} else {
+			err = c.interceptors[i].Intercept(call, func() error {
+				return run(i + 1)
+			})
+		}
+		call.Err = err
+		call.Duration = time.Since(call.Start)
+		return err
+	}
+	return run(0)
+}
+
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return optionFun(func(c *Conn) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	})
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntercept(t *testing.T) {
+	var order []string
+	c := new(Conn)
+	WithInterceptors(
+		InterceptorFunc(func(call *Call, next func() error) error {
+			order = append(order, "first")
+			return next()
+		}),
+		InterceptorFunc(func(call *Call, next func() error) error {
+			order = append(order, "second")
+			return next()
+		}),
+	).apply(c)
+
+	call := &Call{Op: OpExec, Statement: "DELETE FROM t"}
+	err := c.intercept(call, func() error {
+		order = append(order, "call")
+		call.AffectedRows = 3
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "first,second,call" {
+		t.Fatalf("unexpected order: %v", order)
+	}
+	if call.AffectedRows != 3 || call.Start.IsZero() {
+		t.Fatalf("unexpected call: %+v", call)
+	}
+
+	errBlocked := errors.New("blocked")
+	c = new(Conn)
+	WithInterceptors(InterceptorFunc(func(call *Call, next func() error) error {
+		return errBlocked
+	})).apply(c)
+	called := false
+	if err := c.intercept(&Call{Op: OpQuery}, func() error {
+		called = true
+		return nil
+	}); err != errBlocked || called {
+		t.Fatalf("short-circuit failed: err=%v called=%v", err, called)
+	}
+
+	// outer interceptors see error of short-circuited and rewritten calls
+	m := NewMetrics()
+	var out bytes.Buffer
+	errRewritten := errors.New("rewritten")
+	c = new(Conn)
+	WithInterceptors(
+		m,
+		NewSlowStatementLogger(0, &out),
+		InterceptorFunc(func(call *Call, next func() error) error {
+			if call.Op == OpQuery {
+				return errBlocked
+			}
+			if err := next(); err != nil {
+				return errRewritten
+			}
+			return nil
+		}),
+	).apply(c)
+	c.intercept(&Call{Op: OpQuery, Statement: "SELECT 1"}, func() error { return nil })
+	c.intercept(&Call{Op: OpExec, Statement: "DELETE FROM t"}, func() error { return errors.New("failed") })
+	snapshot := m.Snapshot()
+	if snapshot[OpQuery].Errors != 1 || snapshot[OpExec].Errors != 1 {
+		t.Fatalf("unexpected metrics: %+v", snapshot)
+	}
+	if !strings.Contains(out.String(), `error="blocked"`) || !strings.Contains(out.String(), `error="rewritten"`) {
+		t.Fatalf("unexpected slow log: %s", out.String())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics(10*time.Millisecond, time.Millisecond)
+	var out bytes.Buffer
+	slow := NewSlowStatementLogger(5*time.Millisecond, &out)
+
+	// durations are set by Conn.intercept, so interceptors are called directly
+	ok := func() error { return nil }
+	for _, d := range []time.Duration{0, 6 * time.Millisecond, 20 * time.Millisecond} {
+		call := &Call{Op: OpExec, Statement: "SELECT SLEEP(1)", Duration: d}
+		m.Intercept(call, func() error {
+			return slow.Intercept(call, ok)
+		})
+	}
+	pingErr := errors.New("ping failed")
+	m.Intercept(&Call{Op: OpPing, Err: pingErr}, func() error { return pingErr })
+
+	snapshot := m.Snapshot()
+	exec := snapshot[OpExec]
+	if exec.Count != 3 || exec.Errors != 0 || exec.TotalDuration != 26*time.Millisecond {
+		t.Fatalf("unexpected exec metrics: %+v", exec)
+	}
+	if exec.Histogram[0] != 1 || exec.Histogram[1] != 1 || exec.Histogram[2] != 1 {
+		t.Fatalf("unexpected histogram: %v", exec.Histogram)
+	}
+	if ping := snapshot[OpPing]; ping.Count != 1 || ping.Errors != 1 {
+		t.Fatalf("unexpected ping metrics: %+v", ping)
+	}
+	if n := strings.Count(out.String(), "[slow] Exec"); n != 2 {
+		t.Fatalf("expected 2 slow statements, got %d: %s", n, out.String())
+	}
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	DefaultBuckets = []time.Duration{
+		time.Millisecond,
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		500 * time.Millisecond,
+		time.Second,
+		5 * time.Second,
+	}
+)
+
+// NewSlowStatementLogger returns Interceptor which writes calls taking longer than threshold to out.
+func NewSlowStatementLogger(threshold time.Duration, out io.Writer) Interceptor {
+	var mu sync.Mutex
+	return InterceptorFunc(func(call *Call, next func() error) error {
+		err := next()
+		if call.Duration < threshold {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if call.Err != nil {
+			fmt.Fprintf(out, "[slow] %s connection_id=%d duration=%s statement=%q error=%q\n",
+				call.Op, call.ConnectionId, call.Duration, call.Statement, call.Err)
+		} else {
+			fmt.Fprintf(out, "[slow] %s connection_id=%d duration=%s statement=%q affected_rows=%d\n",
+				call.Op, call.ConnectionId, call.Duration, call.Statement, call.AffectedRows)
+		}
+		return err
+	})
+}
+
+// OperationMetrics is statistics of one Operation.
+type OperationMetrics struct {
+	Count         uint64
+	Errors        uint64
+	AffectedRows  uint64
+	TotalDuration time.Duration
+
+	// Histogram[i] is count of calls whose duration <= Buckets[i],
+	// the last one counts calls exceeding all buckets.
+	Histogram []uint64
+}
+
+// Metrics is an Interceptor collecting counters and duration histogram of calls,
+// it can be shared by multiple connections.
+type Metrics struct {
+	buckets []time.Duration
+
+	mu  sync.Mutex
+	ops map[Operation]*OperationMetrics
+}
+
+// NewMetrics creates Metrics with histogram buckets, DefaultBuckets is used if buckets is empty.
+func NewMetrics(buckets ...time.Duration) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	bs := make([]time.Duration, len(buckets))
+	copy(bs, buckets)
+	sort.Slice(bs, func(i, j int) bool { return bs[i] < bs[j] })
+
+	return &Metrics{
+		buckets: bs,
+		ops:     make(map[Operation]*OperationMetrics),
+	}
+}
+
+func (m *Metrics) Intercept(call *Call, next func() error) error {
+	err := next()
+	m.observe(call)
+	return err
+}
+
+func (m *Metrics) Buckets() []time.Duration {
+	return m.buckets
+}
+
+// Snapshot returns a copy of current metrics.
+func (m *Metrics) Snapshot() map[Operation]OperationMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[Operation]OperationMetrics, len(m.ops))
+	for op, om := range m.ops {
+		c := *om
+		c.Histogram = make([]uint64, len(om.Histogram))
+		copy(c.Histogram, om.Histogram)
+		snapshot[op] = c
+	}
+	return snapshot
+}
+
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops = make(map[Operation]*OperationMetrics)
+}
+
+func (m *Metrics) observe(call *Call) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	om, ok := m.ops[call.Op]
+	if !ok {
+		om = &OperationMetrics{Histogram: make([]uint64, len(m.buckets)+1)}
+		m.ops[call.Op] = om
+	}
+
+	om.Count++
+	if call.Err != nil {
+		om.Errors++
+	}
+	om.AffectedRows += call.AffectedRows
+	om.TotalDuration += call.Duration
+	om.Histogram[sort.Search(len(m.buckets), func(i int) bool {
+		return call.Duration <= m.buckets[i]
+	})]++
+}
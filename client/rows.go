@@ -1,19 +1,34 @@
 package client
 
 import (
+	"errors"
 	"github.com/vczyh/mysql-protocol/flag"
 	"github.com/vczyh/mysql-protocol/mysql"
 	"github.com/vczyh/mysql-protocol/packet"
 	"io"
 )
 
+const (
+	binaryCollationId = 63
+)
+
+var (
+	ErrBinaryRows = errors.New("client: NextRaw does not support binary result set")
+)
+
 type Rows struct {
 	conn       *Conn
 	columnDefs []*packet.ColumnDefinition
 	columns    []mysql.Column
 
+	// reused by NextRaw
+	rawValues []packet.RawValue
+
 	// current result set packet is read off or not
 	done bool
+
+	// rows are BinaryResultSetRow of prepared statement
+	binary bool
 }
 
 func (r *Rows) Columns() []mysql.Column {
@@ -33,21 +48,94 @@ func (r *Rows) Next() (mysql.Row, error) {
 	case packet.IsErr(data):
 		return nil, r.conn.handleOKERRPacket(data)
 	case packet.IsEOF(data):
-		r.done = true
-		return nil, io.EOF
+		return nil, r.handleEOFPacket(data)
 	default:
-		pktRow, err := packet.ParseTextResultSetRow(data, r.columnDefs, r.conn.loc)
+		var pktRow packet.Row
+		if r.binary {
+			pktRow, err = packet.ParseBinaryResultSetRow(data, r.columnDefs, r.conn.loc)
+		} else {
+			pktRow, err = packet.ParseTextResultSetRow(data, r.columnDefs, r.conn.loc)
+		}
 		if err != nil {
 			return nil, err
 		}
 		row := make(mysql.Row, len(pktRow))
 		for i, pktColumnVal := range pktRow {
-			row[i] = mysql.NewColumnValue(pktColumnVal.Value)
+			val := pktColumnVal.Value
+			if r.conn.utf8Strings {
+				if val, err = decodeString(r.columnDefs[i], val); err != nil {
+					return nil, err
+				}
+			}
+			row[i] = mysql.NewColumnValue(val)
 		}
 		return row, nil
 	}
 }
 
+// decodeString converts value of character string column to UTF-8 string by column collation.
+func decodeString(column *packet.ColumnDefinition, val interface{}) (interface{}, error) {
+	bs, ok := val.([]byte)
+	if !ok || column.CharacterSet == nil || column.CharacterSet.Id() == binaryCollationId {
+		return val, nil
+	}
+
+	switch column.ColumnType {
+	case flag.MySQLTypeVarchar,
+		flag.MySQLTypeEnum,
+		flag.MySQLTypeSet,
+		flag.MySQLTypeTinyBlob, flag.MySQLTypeMediumBlob, flag.MySQLTypeLongBlob, flag.MySQLTypeBlob,
+		flag.MySQLTypeVarString, flag.MySQLTypeString:
+		decoded, err := column.CharacterSet.Charset().Decode(bs)
+		if err != nil {
+			return nil, err
+		}
+		return string(decoded), nil
+	default:
+		return val, nil
+	}
+}
+
+// NextRaw is like Next but return column values without decoding or copying them,
+// so that no allocation happens for every row. Use packet.RawValue methods to decode lazily.
+//
+// The returned values, including their Data, are only valid until the next call of NextRaw or Next.
+func (r *Rows) NextRaw() ([]packet.RawValue, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	if r.binary {
+		return nil, ErrBinaryRows
+	}
+
+	data, err := r.conn.mysqlConn.ReadPacketNoCopy()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case packet.IsErr(data):
+		return nil, r.conn.handleOKERRPacket(data)
+	case packet.IsEOF(data):
+		return nil, r.handleEOFPacket(data)
+	default:
+		r.rawValues, err = packet.ParseTextResultSetRowRaw(data, len(r.columnDefs), r.rawValues)
+		if err != nil {
+			return nil, err
+		}
+		return r.rawValues, nil
+	}
+}
+
+func (r *Rows) handleEOFPacket(data []byte) error {
+	r.done = true
+	eofPkt, err := packet.ParseEOF(data, r.conn.Capabilities())
+	if err != nil {
+		return err
+	}
+	r.conn.status = eofPkt.StatusFlags
+	return io.EOF
+}
+
 func (r *Rows) HasNextResultSet() bool {
 	return r.conn.status&flag.ServerMoreResultsExists != 0
 }
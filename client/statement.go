@@ -8,7 +8,51 @@ import (
 )
 
 func (c *Conn) Exec(query string) (rs mysql.Result, err error) {
-	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComQuery, []byte(query))); err != nil {
+	call := &Call{Op: OpExec, Statement: query}
+	err = c.intercept(call, func() error {
+		rs, err = c.exec(query)
+		call.AffectedRows = rs.AffectedRows
+		call.LastInsertId = rs.LastInsertId
+		return err
+	})
+	if err == nil {
+		rs.AffectedRows = call.AffectedRows
+		rs.LastInsertId = call.LastInsertId
+	}
+	return rs, err
+}
+
+func (c *Conn) Query(query string) (rows *Rows, err error) {
+	call := &Call{Op: OpQuery, Statement: query}
+	err = c.intercept(call, func() error {
+		rows, err = c.query(query)
+		return err
+	})
+	if err == nil && rows == nil {
+		err = ErrShortCircuited
+	}
+	return rows, err
+}
+
+// Prepare creates a prepared statement, call Stmt.Close when it's no longer needed.
+func (c *Conn) Prepare(query string) (stmt *Stmt, err error) {
+	call := &Call{Op: OpPrepare, Statement: query}
+	err = c.intercept(call, func() error {
+		stmt, err = c.prepare(query)
+		return err
+	})
+	if err == nil && stmt == nil {
+		err = ErrShortCircuited
+	}
+	return stmt, err
+}
+
+func (c *Conn) exec(query string) (rs mysql.Result, err error) {
+	data, err := c.encode(query)
+	if err != nil {
+		return rs, err
+	}
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComQuery, data)); err != nil {
 		return rs, err
 	}
 
@@ -37,11 +81,19 @@ func (c *Conn) Exec(query string) (rs mysql.Result, err error) {
 	return rs, nil
 }
 
-func (c *Conn) Query(query string) (*Rows, error) {
-	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComQuery, []byte(query))); err != nil {
+func (c *Conn) query(query string) (*Rows, error) {
+	data, err := c.encode(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComQuery, data)); err != nil {
 		return nil, err
 	}
+	return c.readRows()
+}
 
+// readRows reads column definitions of text result set response and return Rows to read rows.
+func (c *Conn) readRows() (*Rows, error) {
 	columnCount, err := c.readExecuteResponseFirstPacket()
 	if err != nil {
 		return nil, err
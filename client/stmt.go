@@ -0,0 +1,195 @@
+package client
+
+import (
+	"errors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+)
+
+var (
+	ErrArgumentCount = errors.New("client: argument count mismatch")
+)
+
+// Stmt is a server side prepared statement.
+// https://dev.mysql.com/doc/internals/en/prepared-statements.html
+type Stmt struct {
+	conn       *Conn
+	id         uint32
+	statement  string
+	paramCount int
+	columns    []mysql.Column
+}
+
+func (c *Conn) prepare(query string) (*Stmt, error) {
+	data, err := c.encode(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.WriteCommandPacket(packet.NewCmd(packet.ComStmtPrepare, data)); err != nil {
+		return nil, err
+	}
+
+	data, err = c.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, packet.ErrPacketData
+	}
+	if packet.IsErr(data) {
+		return nil, c.handleOKERRPacket(data)
+	}
+	okPkt, err := packet.ParseStmtPrepareOKFirst(data)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &Stmt{
+		conn:       c,
+		id:         okPkt.StmtId,
+		statement:  query,
+		paramCount: int(okPkt.ParamCount),
+	}
+	if okPkt.ParamCount > 0 {
+		if _, _, err := c.readColumns(int(okPkt.ParamCount)); err != nil {
+			return nil, err
+		}
+	}
+	if okPkt.ColumnCount > 0 {
+		if _, stmt.columns, err = c.readColumns(int(okPkt.ColumnCount)); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (s *Stmt) Id() uint32 {
+	return s.id
+}
+
+func (s *Stmt) ParamCount() int {
+	return s.paramCount
+}
+
+// Columns return columns of result set, it's empty if the statement doesn't return result set.
+func (s *Stmt) Columns() []mysql.Column {
+	return s.columns
+}
+
+func (s *Stmt) Exec(args ...interface{}) (rs mysql.Result, err error) {
+	call := &Call{Op: OpStmtExec, Statement: s.statement, Args: args}
+	err = s.conn.intercept(call, func() error {
+		rs, err = s.exec(args)
+		call.AffectedRows = rs.AffectedRows
+		call.LastInsertId = rs.LastInsertId
+		return err
+	})
+	if err == nil {
+		rs.AffectedRows = call.AffectedRows
+		rs.LastInsertId = call.LastInsertId
+	}
+	return rs, err
+}
+
+func (s *Stmt) Query(args ...interface{}) (rows *Rows, err error) {
+	call := &Call{Op: OpStmtQuery, Statement: s.statement, Args: args}
+	err = s.conn.intercept(call, func() error {
+		rows, err = s.query(args)
+		return err
+	})
+	if err == nil && rows == nil {
+		err = ErrShortCircuited
+	}
+	return rows, err
+}
+
+// Close deallocates the statement, server sends no response.
+func (s *Stmt) Close() error {
+	data := packet.FixedLengthInteger.Dump(uint64(s.id), 4)
+	return s.conn.WriteCommandPacket(packet.NewCmd(packet.ComStmtClose, data))
+}
+
+func (s *Stmt) exec(args []interface{}) (rs mysql.Result, err error) {
+	if err := s.writeExecutePacket(args); err != nil {
+		return rs, err
+	}
+
+	columnCount, err := s.conn.readExecuteResponseFirstPacket()
+	if err != nil {
+		return rs, err
+	}
+	if columnCount > 0 {
+		// columnCount * ColumnDefinition packet
+		if err := s.conn.readUntilEOFPacket(); err != nil {
+			return rs, err
+		}
+		// n * BinaryResultSetRow packet
+		if err := s.conn.readUntilEOFPacket(); err != nil {
+			return rs, err
+		}
+	}
+
+	rs.AffectedRows = s.conn.affectedRows
+	rs.LastInsertId = s.conn.lastInsertId
+	return rs, nil
+}
+
+func (s *Stmt) query(args []interface{}) (*Rows, error) {
+	if err := s.writeExecutePacket(args); err != nil {
+		return nil, err
+	}
+	rows, err := s.conn.readRows()
+	if err != nil {
+		return nil, err
+	}
+	rows.binary = true
+	return rows, nil
+}
+
+// WriteExecute sends COM_STMT_EXECUTE without reading response, the response must be read
+// by Conn.ReadPacket, such as relaying it to another connection.
+func (s *Stmt) WriteExecute(args ...interface{}) error {
+	return s.writeExecutePacket(args)
+}
+
+func (s *Stmt) writeExecutePacket(args []interface{}) error {
+	if len(args) != s.paramCount {
+		return ErrArgumentCount
+	}
+
+	pkt := &packet.StmtExecute{
+		ComStmtExecute: packet.ComStmtExecute.Byte(),
+		StmtId:         s.id,
+		IterationCount: 1,
+	}
+
+	if s.paramCount > 0 {
+		pkt.CreateNullBitMap(s.paramCount)
+		pkt.NewParamsBoundFlag = 1
+
+		for i, arg := range args {
+			cv := packet.ColumnValue{Value: arg}
+			if arg == nil {
+				pkt.NullBitMapSet(s.paramCount, i)
+			}
+
+			columnType, unsigned, err := cv.BinaryType()
+			if err != nil {
+				return err
+			}
+			var paramFlag byte
+			if unsigned {
+				paramFlag = 0x80
+			}
+			pkt.ParamType = append(pkt.ParamType, byte(columnType), paramFlag)
+
+			val, err := cv.DumpBinary(columnType)
+			if err != nil {
+				return err
+			}
+			pkt.ParamValue = append(pkt.ParamValue, val...)
+		}
+	}
+
+	return s.conn.WriteCommandPacket(pkt)
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"github.com/vczyh/mysql-protocol/server/memengine"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestStmt(t *testing.T) {
+	var calls []*Call
+	srv := servertest.NewServer(memengine.NewHandler(), nil)
+	conn, err := CreateConnection(WithDialer(servertest.Dialer(srv)), WithUser("root"),
+		WithInterceptors(InterceptorFunc(func(call *Call, next func() error) error {
+			calls = append(calls, call)
+			return next()
+		})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, query := range []string{
+		"CREATE DATABASE app",
+		"USE app",
+		"CREATE TABLE users (id BIGINT PRIMARY KEY, name VARCHAR(32), score DOUBLE)",
+	} {
+		if _, err := conn.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insert, err := conn.Prepare("INSERT INTO users (id, name, score) VALUES (?, ?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if insert.ParamCount() != 3 {
+		t.Fatalf("ParamCount() = %d, want 3", insert.ParamCount())
+	}
+	for _, args := range [][]interface{}{{1, "alice", 1.5}, {int64(2), []byte("bob"), nil}} {
+		rs, err := insert.Exec(args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rs.AffectedRows != 1 {
+			t.Fatalf("AffectedRows = %d, want 1", rs.AffectedRows)
+		}
+	}
+	if err := insert.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sel, err := conn.Prepare("SELECT id, name, score FROM users WHERE id >= ? ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sel.Close()
+	rows, err := sel.Query(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rows.NextRaw(); err != ErrBinaryRows {
+		t.Fatalf("NextRaw() error = %v, want %v", err, ErrBinaryRows)
+	}
+	var got [][]interface{}
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var values []interface{}
+		for _, cv := range row {
+			values = append(values, cv.Value())
+		}
+		got = append(got, values)
+	}
+	want := [][]interface{}{
+		{int64(1), []byte("alice"), 1.5},
+		{int64(2), []byte("bob"), nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows = %#v, want %#v", got, want)
+	}
+
+	var ops []Operation
+	for _, call := range calls {
+		ops = append(ops, call.Op)
+	}
+	wantOps := []Operation{OpConnect, OpExec, OpExec, OpExec, OpPrepare, OpStmtExec, OpStmtExec, OpPrepare, OpStmtQuery}
+	if !reflect.DeepEqual(ops, wantOps) {
+		t.Fatalf("operations = %v, want %v", ops, wantOps)
+	}
+	if call := calls[5]; call.Statement != "INSERT INTO users (id, name, score) VALUES (?, ?, ?)" ||
+		len(call.Args) != 3 || call.AffectedRows != 1 {
+		t.Fatalf("unexpected call: %+v", call)
+	}
+}
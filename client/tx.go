@@ -0,0 +1,227 @@
+package client
+
+import (
+	"errors"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"strings"
+)
+
+var (
+	ErrTxDone       = errors.New("client: transaction has already been committed or rolled back")
+	ErrTxInProgress = errors.New("client: transaction is in progress")
+	ErrTxNotStarted = errors.New("client: transaction not started by server")
+
+	// ErrTxImplicitCommit returned when server committed transaction implicitly,
+	// such as executing DDL statement.
+	ErrTxImplicitCommit = errors.New("client: transaction committed implicitly")
+
+	// ErrTxRolledBack returned when server rolled back transaction because of error,
+	// such as deadlock.
+	ErrTxRolledBack = errors.New("client: transaction rolled back by server")
+)
+
+// IsolationLevel https://dev.mysql.com/doc/refman/8.0/en/innodb-transaction-isolation-levels.html
+type IsolationLevel uint8
+
+const (
+	// LevelDefault uses isolation level of session.
+	LevelDefault IsolationLevel = iota
+	LevelReadUncommitted
+	LevelReadCommitted
+	LevelRepeatableRead
+	LevelSerializable
+)
+
+func (l IsolationLevel) String() string {
+	switch l {
+	case LevelDefault:
+		return "DEFAULT"
+	case LevelReadUncommitted:
+		return "READ UNCOMMITTED"
+	case LevelReadCommitted:
+		return "READ COMMITTED"
+	case LevelRepeatableRead:
+		return "REPEATABLE READ"
+	case LevelSerializable:
+		return "SERIALIZABLE"
+	default:
+		return "Unknown IsolationLevel"
+	}
+}
+
+// TxOptions https://dev.mysql.com/doc/refman/8.0/en/commit.html
+type TxOptions struct {
+	Isolation          IsolationLevel
+	ReadOnly           bool
+	ConsistentSnapshot bool
+}
+
+// Tx is a transaction started by Conn.Begin.
+//
+// Tx tracks SERVER_STATUS_IN_TRANS flag of OK and EOF packets, so that
+// it knows the transaction is ended by server implicitly.
+type Tx struct {
+	conn *Conn
+	done bool
+	err  error
+}
+
+// Begin starts a transaction, opts may be nil.
+func (c *Conn) Begin(opts *TxOptions) (*Tx, error) {
+	if c.InTransaction() || (c.tx != nil && !c.tx.done) {
+		return nil, ErrTxInProgress
+	}
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+
+	if opts.Isolation != LevelDefault {
+		if _, err := c.Exec("SET TRANSACTION ISOLATION LEVEL " + opts.Isolation.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	var characteristics []string
+	if opts.ConsistentSnapshot {
+		characteristics = append(characteristics, "WITH CONSISTENT SNAPSHOT")
+	}
+	if opts.ReadOnly {
+		characteristics = append(characteristics, "READ ONLY")
+	}
+	query := "START TRANSACTION"
+	if len(characteristics) > 0 {
+		query += " " + strings.Join(characteristics, ", ")
+	}
+	if _, err := c.Exec(query); err != nil {
+		return nil, err
+	}
+	if !c.InTransaction() {
+		return nil, ErrTxNotStarted
+	}
+
+	c.tx = &Tx{conn: c}
+	return c.tx, nil
+}
+
+// InTransaction reports whether the session is in a transaction,
+// according to the status flags of last OK or EOF packet.
+func (c *Conn) InTransaction() bool {
+	return c.status&flag.ServerStatusInTrans != 0
+}
+
+func (tx *Tx) Exec(query string) (mysql.Result, error) {
+	if err := tx.check(); err != nil {
+		return mysql.Result{}, err
+	}
+	rs, err := tx.conn.Exec(query)
+	tx.track(err)
+	return rs, err
+}
+
+// Query does not track transaction state until rows are read off,
+// it is checked again by the next call of Tx.
+func (tx *Tx) Query(query string) (*Rows, error) {
+	if err := tx.check(); err != nil {
+		return nil, err
+	}
+	rows, err := tx.conn.Query(query)
+	if err != nil {
+		tx.track(err)
+	}
+	return rows, err
+}
+
+func (tx *Tx) Commit() error {
+	return tx.end("COMMIT")
+}
+
+func (tx *Tx) Rollback() error {
+	return tx.end("ROLLBACK")
+}
+
+func (tx *Tx) Savepoint(name string) error {
+	_, err := tx.Exec("SAVEPOINT " + quoteIdentifier(name))
+	return err
+}
+
+func (tx *Tx) RollbackTo(name string) error {
+	_, err := tx.Exec("ROLLBACK TO SAVEPOINT " + quoteIdentifier(name))
+	return err
+}
+
+func (tx *Tx) Release(name string) error {
+	_, err := tx.Exec("RELEASE SAVEPOINT " + quoteIdentifier(name))
+	return err
+}
+
+// Active reports whether the transaction is still in progress.
+func (tx *Tx) Active() bool {
+	return tx.check() == nil
+}
+
+func (tx *Tx) end(query string) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	_, err := tx.conn.Exec(query)
+	tx.done = true
+	if err != nil {
+		tx.err = err
+	} else {
+		tx.err = ErrTxDone
+	}
+	return err
+}
+
+// check return error if transaction is ended, including ended by server implicitly.
+func (tx *Tx) check() error {
+	if tx.done {
+		return tx.err
+	}
+	if !tx.conn.InTransaction() {
+		tx.done = true
+		tx.err = ErrTxImplicitCommit
+	}
+	return tx.err
+}
+
+// track updates transaction state after a statement.
+//
+// ERR packet does not contain status flags, so the state is only changed by errors
+// which roll back transaction, other errors such as duplicate key don't end the transaction.
+func (tx *Tx) track(err error) {
+	if err == nil {
+		tx.check()
+		return
+	}
+	errPkt, ok := err.(*packet.ERR)
+	if !ok {
+		return
+	}
+	switch errPkt.ErrorCode {
+	case code.ErrLockDeadlock:
+		// deadlock always rolls back transaction
+		tx.conn.status &^= flag.ServerStatusInTrans
+		tx.done = true
+		tx.err = ErrTxRolledBack
+	case code.ErrLockWaitTimeout:
+		// lock wait timeout rolls back transaction only if innodb_rollback_on_timeout is enabled,
+		// refresh status flags by OK packet of COM_PING, it costs a round trip only for this error.
+		if pingErr := tx.conn.Ping(); pingErr != nil {
+			tx.done = true
+			tx.err = pingErr
+			return
+		}
+		if !tx.conn.InTransaction() {
+			tx.done = true
+			tx.err = ErrTxRolledBack
+		}
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/server"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// txHandler maintains SERVER_STATUS_IN_TRANS like MySQL.
+type txHandler struct {
+	server.DefaultHandler
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (h *txHandler) Query(session *server.Session, query string) (interface{}, error) {
+	h.mu.Lock()
+	h.queries = append(h.queries, query)
+	h.mu.Unlock()
+
+	status := session.Status()
+	switch {
+	case strings.HasPrefix(query, "START TRANSACTION"):
+		session.SetStatus(status | flag.ServerStatusInTrans)
+	case query == "COMMIT", query == "ROLLBACK", strings.HasPrefix(query, "CREATE"):
+		session.SetStatus(status &^ flag.ServerStatusInTrans)
+	case query == "deadlock":
+		session.SetStatus(status &^ flag.ServerStatusInTrans)
+		return nil, myerrors.NewServer(code.ErrLockDeadlock, "Deadlock found when trying to get lock")
+	case query == "timeout":
+		return nil, myerrors.NewServer(code.ErrLockWaitTimeout, "Lock wait timeout exceeded")
+	case query == "timeout rollback":
+		session.SetStatus(status &^ flag.ServerStatusInTrans)
+		return nil, myerrors.NewServer(code.ErrLockWaitTimeout, "Lock wait timeout exceeded")
+	case query == "duplicate":
+		return nil, myerrors.NewServer(code.ErrDupEntry, "Duplicate entry")
+	}
+	return &mysql.Result{}, nil
+}
+
+func (h *txHandler) Ping(session *server.Session) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queries = append(h.queries, "PING")
+	return nil
+}
+
+func (h *txHandler) take() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	queries := h.queries
+	h.queries = nil
+	return queries
+}
+
+func TestTx(t *testing.T) {
+	h := new(txHandler)
+	srv := servertest.NewServer(h, nil)
+	conn, err := CreateConnection(WithDialer(servertest.Dialer(srv)), WithUser("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	begin := func(opts *TxOptions) *Tx {
+		t.Helper()
+		tx, err := conn.Begin(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !conn.InTransaction() || !tx.Active() {
+			t.Fatal("expected transaction in progress")
+		}
+		return tx
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		h.take()
+		tx := begin(&TxOptions{Isolation: LevelReadCommitted, ReadOnly: true, ConsistentSnapshot: true})
+		if _, err := conn.Begin(nil); err != ErrTxInProgress {
+			t.Fatalf("Begin() error = %v, want %v", err, ErrTxInProgress)
+		}
+		if err := tx.Savepoint("sp`1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if conn.InTransaction() || tx.Active() {
+			t.Fatal("expected transaction ended")
+		}
+		if err := tx.Rollback(); err != ErrTxDone {
+			t.Fatalf("Rollback() error = %v, want %v", err, ErrTxDone)
+		}
+		want := []string{
+			"SET TRANSACTION ISOLATION LEVEL READ COMMITTED",
+			"START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY",
+			"SAVEPOINT `sp``1`",
+			"COMMIT",
+		}
+		if got := h.take(); strings.Join(got, ";") != strings.Join(want, ";") {
+			t.Fatalf("queries = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("ImplicitCommit", func(t *testing.T) {
+		tx := begin(nil)
+		if _, err := tx.Exec("CREATE TABLE t (id INT)"); err != nil {
+			t.Fatal(err)
+		}
+		if tx.Active() {
+			t.Fatal("expected transaction committed by DDL")
+		}
+		if _, err := tx.Exec("INSERT INTO t VALUES (1)"); err != ErrTxImplicitCommit {
+			t.Fatalf("Exec() error = %v, want %v", err, ErrTxImplicitCommit)
+		}
+		if err := tx.Commit(); err != ErrTxImplicitCommit {
+			t.Fatalf("Commit() error = %v, want %v", err, ErrTxImplicitCommit)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		tx := begin(nil)
+		h.take()
+		if _, err := tx.Exec("duplicate"); err == nil {
+			t.Fatal("expected duplicate error")
+		}
+		if _, err := tx.Exec("timeout"); err == nil {
+			t.Fatal("expected timeout error")
+		}
+		if !tx.Active() {
+			t.Fatal("expected transaction in progress")
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		// only lock wait timeout refreshes status flags by COM_PING
+		if got := h.take(); strings.Join(got, ";") != "duplicate;timeout;PING;ROLLBACK" {
+			t.Fatalf("unexpected queries: %q", got)
+		}
+	})
+
+	for _, query := range []string{"deadlock", "timeout rollback"} {
+		t.Run(query, func(t *testing.T) {
+			tx := begin(nil)
+			if _, err := tx.Exec(query); err == nil {
+				t.Fatal("expected error")
+			}
+			if tx.Active() || conn.InTransaction() {
+				t.Fatal("expected transaction rolled back")
+			}
+			if _, err := tx.Exec("SELECT 1"); err != ErrTxRolledBack {
+				t.Fatalf("Exec() error = %v, want %v", err, ErrTxRolledBack)
+			}
+		})
+	}
+}
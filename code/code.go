@@ -18,16 +18,68 @@ const ()
 
 // 1,000 to 1,999: Server error codes reserved for messages sent to clients.
 const (
-	ErrNo                Err = 1002
-	ErrYes               Err = 1003
-	ErrAccessDeniedError Err = 1045
+	ErrNo                     Err = 1002
+	ErrYes                    Err = 1003
+	ErrDbCreateExists         Err = 1007
+	ErrDbDropExists           Err = 1008
+	ErrConCountError          Err = 1040
+	ErrDbAccessDenied         Err = 1044
+	ErrAccessDeniedError      Err = 1045
+	ErrNoDbError              Err = 1046
+	ErrUnknownComError        Err = 1047
+	ErrBadNullError           Err = 1048
+	ErrBadDbError             Err = 1049
+	ErrTableExistsError       Err = 1050
+	ErrBadTableError          Err = 1051
+	ErrServerShutdown         Err = 1053
+	ErrBadFieldError          Err = 1054
+	ErrDupFieldName           Err = 1060
+	ErrDupKeyName             Err = 1061
+	ErrDupEntry               Err = 1062
+	ErrParseError             Err = 1064
+	ErrMultiplePriKey         Err = 1068
+	ErrKeyColumnDoesNotExits  Err = 1072
+	ErrNoSuchThread           Err = 1094
+	ErrKillDeniedError        Err = 1095
+	ErrNoTablesUsed           Err = 1096
+	ErrInvalidGroupFuncUse    Err = 1111
+	ErrUnknownCharacterSet    Err = 1115
+	ErrWrongValueCountOnRow   Err = 1136
+	ErrTableAccessDenied      Err = 1142
+	ErrColumnAccessDenied     Err = 1143
+	ErrNoSuchTable            Err = 1146
+	ErrNetPacketTooLarge      Err = 1153
+	ErrUnknownSystemVariable  Err = 1193
+	ErrTooManyUserConnections Err = 1203
+	ErrLockWaitTimeout        Err = 1205
+	ErrWrongArguments         Err = 1210
+	ErrLockDeadlock           Err = 1213
+	ErrSpecificAccessDenied   Err = 1227
+	ErrLocalVariable          Err = 1228
+	ErrGlobalVariable         Err = 1229
+	ErrWrongValueForVar       Err = 1231
+	ErrNotSupportedYet        Err = 1235
+	ErrIncorrectGlobalLocal   Err = 1238
+	ErrUnknownStmtHandler     Err = 1243
+	ErrUnknownCollation       Err = 1273
+	ErrNoDefaultForField      Err = 1364
+	ErrTruncatedWrongValue    Err = 1366
+	ErrWrongParamCount        Err = 1582
+	ErrInternalError          Err = 1815
+	ErrMustChangePassword     Err = 1820
+	ErrMalformedPacket        Err = 1835
+	ErrExpiredPasswordLogin   Err = 1862
 )
 
 // 2,000 to 2,999: Client error codes reserved for use by the client library.
 const ()
 
 // 3,000 to 4,999: Server error codes reserved for messages sent to clients.
-const ()
+const (
+	ErrAccountHasBeenLocked     Err = 3118
+	ErrUserAccountBlocked       Err = 3955
+	ErrClientInteractionTimeout Err = 4031
+)
 
 // 5,000 to 5,999: Error codes reserved for use by X Plugin for messages sent to clients.
 const ()
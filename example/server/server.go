@@ -3,9 +3,9 @@ package main
 import (
 	"fmt"
 	"github.com/vczyh/mysql-protocol/auth"
-	"github.com/vczyh/mysql-protocol/mysql"
 	"github.com/vczyh/mysql-protocol/server"
 	"log"
+	"os"
 	"sync"
 )
 
@@ -34,9 +34,19 @@ func main() {
 		log.Fatal(err)
 	}
 
+	logger := server.NewDefaultLogger(server.InfoLevel, os.Stdout)
+	handler := server.Chain(
+		server.NewDefaultHandler(),
+		server.Recovery(logger),
+		server.Logging(logger),
+		func(next server.Handler) server.Handler {
+			return &connections{Wrapper: server.Wrapper{Next: next}}
+		},
+	)
+
 	srv := server.NewServer(
 		userProvider,
-		newTestHandler(),
+		handler,
 		server.WithPort(3306),
 		//server.WithRSAKeysDir("/Users/zhangyuheng/tmp/certs/t1"),
 
@@ -59,39 +69,20 @@ func main() {
 	}
 }
 
-type testHandler struct {
-	dh        server.Handler
+// connections is a middleware tracking connections.
+type connections struct {
+	server.Wrapper
 	connIdSet sync.Map
 }
 
-func newTestHandler() *testHandler {
-	h := new(testHandler)
-	h.dh = server.NewDefaultHandler()
-	return h
-}
-
-func (h *testHandler) Ping() error {
-	return h.dh.Ping()
-}
-
-func (h *testHandler) Query(query string) (interface{}, error) {
-	return h.dh.Query(query)
-}
-
-func (h *testHandler) Quit() {
-	fmt.Println("QUIT command called")
-}
-
-func (h *testHandler) Other(data []byte, conn mysql.Conn) {
-	h.dh.Other(data, conn)
-}
-
-func (h *testHandler) OnConnect(connId uint32) {
+func (h *connections) OnConnect(connId uint32) {
 	fmt.Println("new connect: ", connId)
 	h.connIdSet.Store(connId, "")
+	h.Wrapper.OnConnect(connId)
 }
 
-func (h *testHandler) OnClose(connId uint32) {
+func (h *connections) OnClose(connId uint32) {
 	fmt.Println("close connect: ", connId)
 	h.connIdSet.Delete(connId)
+	h.Wrapper.OnClose(connId)
 }
@@ -0,0 +1,88 @@
+package flag
+
+import "strings"
+
+// Refresh is sub command of COM_REFRESH.
+// https://dev.mysql.com/doc/internals/en/com-refresh.html
+type Refresh uint8
+
+const (
+	RefreshGrant Refresh = 1 << iota
+	RefreshLog
+	RefreshTables
+	RefreshHosts
+	RefreshStatus
+	RefreshThreads
+	RefreshSlave
+	RefreshMaster
+)
+
+func (r Refresh) String() string {
+	var rs []string
+	for _, o := range []Refresh{
+		RefreshGrant,
+		RefreshLog,
+		RefreshTables,
+		RefreshHosts,
+		RefreshStatus,
+		RefreshThreads,
+		RefreshSlave,
+		RefreshMaster,
+	} {
+		if r&o != 0 {
+			rs = append(rs, o.string())
+		}
+	}
+	return "[" + strings.Join(rs, " ") + "]"
+}
+
+func (r Refresh) string() string {
+	switch r {
+	case RefreshGrant:
+		return "REFRESH_GRANT"
+	case RefreshLog:
+		return "REFRESH_LOG"
+	case RefreshTables:
+		return "REFRESH_TABLES"
+	case RefreshHosts:
+		return "REFRESH_HOSTS"
+	case RefreshStatus:
+		return "REFRESH_STATUS"
+	case RefreshThreads:
+		return "REFRESH_THREADS"
+	case RefreshSlave:
+		return "REFRESH_SLAVE"
+	case RefreshMaster:
+		return "REFRESH_MASTER"
+	default:
+		return "Unknown Refresh"
+	}
+}
+
+// SetOption is option of COM_SET_OPTION.
+// https://dev.mysql.com/doc/internals/en/com-set-option.html
+type SetOption uint16
+
+const (
+	MultiStatementsOn SetOption = iota
+	MultiStatementsOff
+)
+
+func (o SetOption) String() string {
+	switch o {
+	case MultiStatementsOn:
+		return "MYSQL_OPTION_MULTI_STATEMENTS_ON"
+	case MultiStatementsOff:
+		return "MYSQL_OPTION_MULTI_STATEMENTS_OFF"
+	default:
+		return "Unknown SetOption"
+	}
+}
+
+// Shutdown is shutdown level of COM_SHUTDOWN.
+// https://dev.mysql.com/doc/internals/en/com-shutdown.html
+type Shutdown uint8
+
+const (
+	ShutdownDefault Shutdown = 0x00
+)
@@ -3,7 +3,9 @@ module github.com/vczyh/mysql-protocol
 go 1.16
 
 require (
-	github.com/google/uuid v1.3.0 // indirect
+	github.com/google/uuid v1.3.0
 	github.com/pingcap/parser v0.0.0-20200623164729-3a18f1e5dceb
 	github.com/vczyh/mysql-password v1.0.1
+	golang.org/x/text v0.3.1-0.20180807135948-17ff2d5776d2
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -7,7 +7,56 @@ import (
 
 // https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
 var (
-	AccessDenied = NewTemplate(ServerName, code.ErrAccessDeniedError, "28000", "Access denied for user '%s'@'%s' (using password: %s)")
+	DbCreateExists           = NewTemplate(ServerName, code.ErrDbCreateExists, SQLStateDef, "Can't create database '%s'; database exists")
+	DbDropExists             = NewTemplate(ServerName, code.ErrDbDropExists, SQLStateDef, "Can't drop database '%s'; database doesn't exist")
+	ConCount                 = NewTemplate(ServerName, code.ErrConCountError, "08004", "Too many connections")
+	AccessDenied             = NewTemplate(ServerName, code.ErrAccessDeniedError, "28000", "Access denied for user '%s'@'%s' (using password: %s)")
+	DbAccessDenied           = NewTemplate(ServerName, code.ErrDbAccessDenied, "42000", "Access denied for user '%s'@'%s' to database '%s'")
+	NoDb                     = NewTemplate(ServerName, code.ErrNoDbError, "3D000", "No database selected")
+	UnknownCom               = NewTemplate(ServerName, code.ErrUnknownComError, "08S01", "Unknown command")
+	BadNull                  = NewTemplate(ServerName, code.ErrBadNullError, "23000", "Column '%s' cannot be null")
+	BadDb                    = NewTemplate(ServerName, code.ErrBadDbError, "42000", "Unknown database '%s'")
+	TableExists              = NewTemplate(ServerName, code.ErrTableExistsError, "42S01", "Table '%s' already exists")
+	BadTable                 = NewTemplate(ServerName, code.ErrBadTableError, "42S02", "Unknown table '%s'")
+	ServerShutdown           = NewTemplate(ServerName, code.ErrServerShutdown, "08S01", "Server shutdown in progress")
+	BadField                 = NewTemplate(ServerName, code.ErrBadFieldError, "42S22", "Unknown column '%s' in '%s'")
+	DupFieldName             = NewTemplate(ServerName, code.ErrDupFieldName, "42S21", "Duplicate column name '%s'")
+	DupKeyName               = NewTemplate(ServerName, code.ErrDupKeyName, "42000", "Duplicate key name '%s'")
+	DupEntry                 = NewTemplate(ServerName, code.ErrDupEntry, "23000", "Duplicate entry '%s' for key '%s'")
+	ParseError               = NewTemplate(ServerName, code.ErrParseError, "42000", "%s")
+	MultiplePriKey           = NewTemplate(ServerName, code.ErrMultiplePriKey, "42000", "Multiple primary key defined")
+	KeyColumnDoesNotExist    = NewTemplate(ServerName, code.ErrKeyColumnDoesNotExits, "42000", "Key column '%s' doesn't exist in table")
+	NoSuchThread             = NewTemplate(ServerName, code.ErrNoSuchThread, SQLStateDef, "Unknown thread id: %d")
+	KillDenied               = NewTemplate(ServerName, code.ErrKillDeniedError, SQLStateDef, "You are not owner of thread %d")
+	NoTablesUsed             = NewTemplate(ServerName, code.ErrNoTablesUsed, SQLStateDef, "No tables used")
+	InvalidGroupFuncUse      = NewTemplate(ServerName, code.ErrInvalidGroupFuncUse, SQLStateDef, "Invalid use of group function")
+	TableAccessDenied        = NewTemplate(ServerName, code.ErrTableAccessDenied, "42000", "%s command denied to user '%s'@'%s' for table '%s'")
+	ColumnAccessDenied       = NewTemplate(ServerName, code.ErrColumnAccessDenied, "42000", "%s command denied to user '%s'@'%s' for column '%s' in table '%s'")
+	UnknownCharacterSet      = NewTemplate(ServerName, code.ErrUnknownCharacterSet, "42000", "Unknown character set: '%s'")
+	WrongValueCountOnRow     = NewTemplate(ServerName, code.ErrWrongValueCountOnRow, "21S01", "Column count doesn't match value count at row %d")
+	NoSuchTable              = NewTemplate(ServerName, code.ErrNoSuchTable, "42S02", "Table '%s.%s' doesn't exist")
+	NetPacketTooLarge        = NewTemplate(ServerName, code.ErrNetPacketTooLarge, "08S01", "Got a packet bigger than 'max_allowed_packet' bytes")
+	UnknownSystemVariable    = NewTemplate(ServerName, code.ErrUnknownSystemVariable, SQLStateDef, "Unknown system variable '%s'")
+	TooManyUserConnections   = NewTemplate(ServerName, code.ErrTooManyUserConnections, "42000", "User %s already has more than 'max_user_connections' active connections")
+	SpecificAccessDenied     = NewTemplate(ServerName, code.ErrSpecificAccessDenied, "42000", "Access denied; you need (at least one of) the %s privilege(s) for this operation")
+	WrongArguments           = NewTemplate(ServerName, code.ErrWrongArguments, SQLStateDef, "Incorrect arguments to %s")
+	LocalVariable            = NewTemplate(ServerName, code.ErrLocalVariable, SQLStateDef, "Variable '%s' is a SESSION variable and can't be used with SET GLOBAL")
+	GlobalVariable           = NewTemplate(ServerName, code.ErrGlobalVariable, SQLStateDef, "Variable '%s' is a GLOBAL variable and should be set with SET GLOBAL")
+	WrongValueForVar         = NewTemplate(ServerName, code.ErrWrongValueForVar, "42000", "Variable '%s' can't be set to the value of '%s'")
+	NotSupportedYet          = NewTemplate(ServerName, code.ErrNotSupportedYet, "42000", "This version of MySQL doesn't yet support '%s'")
+	IncorrectGlobalLocalVar  = NewTemplate(ServerName, code.ErrIncorrectGlobalLocal, SQLStateDef, "Variable '%s' is a %s variable")
+	UnknownStmtHandler       = NewTemplate(ServerName, code.ErrUnknownStmtHandler, SQLStateDef, "Unknown prepared statement handler (%d) given to %s")
+	UnknownCollation         = NewTemplate(ServerName, code.ErrUnknownCollation, SQLStateDef, "Unknown collation: '%s'")
+	NoDefaultForField        = NewTemplate(ServerName, code.ErrNoDefaultForField, SQLStateDef, "Field '%s' doesn't have a default value")
+	TruncatedWrongValue      = NewTemplate(ServerName, code.ErrTruncatedWrongValue, SQLStateDef, "Incorrect %s value: '%s' for column '%s' at row %d")
+	WrongParamCount          = NewTemplate(ServerName, code.ErrWrongParamCount, "42000", "Incorrect parameter count in the call to native function '%s'")
+	InternalError            = NewTemplate(ServerName, code.ErrInternalError, SQLStateDef, "Internal error: %s")
+	MustChangePassword       = NewTemplate(ServerName, code.ErrMustChangePassword, SQLStateDef, "You must reset your password using ALTER USER statement before executing this statement.")
+	MalformedPacket          = NewTemplate(ServerName, code.ErrMalformedPacket, "08S01", "Malformed communication packet.")
+	MustChangePasswordLogin  = NewTemplate(ServerName, code.ErrExpiredPasswordLogin, SQLStateDef, "Your password has expired. To log in you must change it using a client that supports expired passwords.")
+	AccountHasBeenLocked     = NewTemplate(ServerName, code.ErrAccountHasBeenLocked, SQLStateDef, "Access denied for user '%s'@'%s'. Account is locked.")
+	UserAccountBlocked       = NewTemplate(ServerName, code.ErrUserAccountBlocked, SQLStateDef, "Access denied for user '%s'@'%s'. Account is blocked for %s day(s) (%s day(s) remaining) due to %d consecutive failed logins.")
+	ClientInteractionTimeout = NewTemplate(ServerName, code.ErrClientInteractionTimeout, SQLStateDef, "The client was disconnected by the server because of inactivity. See wait_timeout and interactive_timeout for configuring this behavior.")
 )
 
 type template struct {
@@ -81,6 +81,8 @@ func (cv *ColumnValue) String() string {
 		return v.Format("2006-01-02 15:04:05.000000")
 	case []byte:
 		return string(v)
+	case string:
+		return v
 	default:
 		return "unsupported column type"
 	}
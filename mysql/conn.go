@@ -2,12 +2,21 @@ package mysql
 
 import (
 	"crypto/tls"
-	"encoding/hex"
-	"fmt"
+	"errors"
 	"github.com/vczyh/mysql-protocol/flag"
 	"github.com/vczyh/mysql-protocol/myerrors"
 	"github.com/vczyh/mysql-protocol/packet"
+	"io"
 	"net"
+	"sync/atomic"
+	"time"
+)
+
+// maxPayloadLen is max payload length of one packet.
+const maxPayloadLen = 1<<24 - 1
+
+var (
+	ErrPacketTooLarge = errors.New("mysql: packet bigger than max allowed packet")
 )
 
 type Conn interface {
@@ -20,16 +29,32 @@ type Conn interface {
 	ConnectionId() uint32
 	Capabilities() flag.Capability
 
+	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
 
+	// ReadPacket joins payload split into multiple packets, it return ErrPacketTooLarge
+	// without reading the rest of payload if payload is bigger than max allowed packet.
 	ReadPacket() ([]byte, error)
 
+	// ReadPacketNoCopy is like ReadPacket but reuses the read buffer,
+	// the returned payload is only valid until the next read.
+	ReadPacketNoCopy() ([]byte, error)
+
 	WritePacket(packet.Packet) error
 	WriteCommandPacket(packet.Packet) error
 
 	WriteEmptyOK() error
 	WriteError(error) error
 
+	// SetReadDeadline sets deadline of underlying connection, it can be called
+	// concurrently to interrupt blocked ReadPacket.
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+
+	// SetMaxAllowedPacket limits size of payload read, including payload split into
+	// multiple packets, 0 means no limit.
+	SetMaxAllowedPacket(n int)
+
 	Close() error
 	Closed() bool
 }
@@ -40,7 +65,12 @@ type mysqlConn struct {
 	useTLS  bool
 
 	sequence int
-	closed   bool
+	closed   int32
+
+	header [4]byte
+	buf    []byte
+
+	maxAllowedPacket int
 
 	connId       uint32 // only for server
 	capabilities flag.Capability
@@ -89,6 +119,10 @@ func (c *mysqlConn) ConnectionId() uint32 {
 	return c.connId
 }
 
+func (c *mysqlConn) LocalAddr() net.Addr {
+	return c.getConnection().LocalAddr()
+}
+
 func (c *mysqlConn) RemoteAddr() net.Addr {
 	return c.getConnection().RemoteAddr()
 }
@@ -98,37 +132,61 @@ func (c *mysqlConn) Host() {
 }
 
 func (c *mysqlConn) ReadPacket() ([]byte, error) {
-	// payload length
-	lenData, err := c.next(3)
-	if err != nil {
-		return nil, err
+	var payload []byte
+	for {
+		length, err := c.readHeader(len(payload))
+		if err != nil {
+			return nil, err
+		}
+		data, err := c.next(length)
+		if err != nil {
+			return nil, err
+		}
+		if payload == nil && length < maxPayloadLen {
+			return data, nil
+		}
+		payload = append(payload, data...)
+		if length < maxPayloadLen {
+			return payload, nil
+		}
 	}
-	length := packet.FixedLengthInteger.Get(lenData)
+}
 
-	// sequence
-	seqData, err := c.next(1)
-	if err != nil {
-		return nil, err
-	}
-	if len(seqData) == 0 {
-		return nil, packet.ErrPacketData
+func (c *mysqlConn) ReadPacketNoCopy() ([]byte, error) {
+	size := 0
+	for {
+		length, err := c.readHeader(size)
+		if err != nil {
+			return nil, err
+		}
+		if cap(c.buf) < size+length {
+			buf := make([]byte, size+length)
+			copy(buf, c.buf[:size])
+			c.buf = buf
+		}
+		if _, err := io.ReadFull(c.getConnection(), c.buf[size:size+length]); err != nil {
+			return nil, err
+		}
+		size += length
+		if length < maxPayloadLen {
+			return c.buf[:size], nil
+		}
 	}
-	c.sequence = int(seqData[0])
+}
 
-	// payload
-	payloadData, err := c.next(int(length))
-	if err != nil {
-		return nil, err
+// readHeader reads header of packet following read bytes of payload, payload bigger than
+// maxPayloadLen is split into packets and the last one is shorter than maxPayloadLen.
+// Max allowed packet limits size of the whole payload.
+func (c *mysqlConn) readHeader(read int) (int, error) {
+	if _, err := io.ReadFull(c.getConnection(), c.header[:]); err != nil {
+		return 0, err
 	}
-
-	// packet bytes
-	pktData := append(lenData, seqData...)
-	pktData = append(pktData, payloadData...)
-
-	// TODO
-	//fmt.Println(hex.Dump(pktData))
-
-	return payloadData, nil
+	length := int(c.header[0]) | int(c.header[1])<<8 | int(c.header[2])<<16
+	if c.maxAllowedPacket > 0 && read+length > c.maxAllowedPacket {
+		return 0, ErrPacketTooLarge
+	}
+	c.sequence = int(c.header[3])
+	return length, nil
 }
 
 func (c *mysqlConn) WritePacket(packet packet.Packet) error {
@@ -138,12 +196,7 @@ func (c *mysqlConn) WritePacket(packet packet.Packet) error {
 	if err != nil {
 		return err
 	}
-	headerData := c.buildPacketHeader(len(data))
-	pktData := append(headerData, data...)
-
-	// TODO
-	fmt.Println(hex.Dump(pktData))
-	return c.write(pktData)
+	return c.writePayload(data)
 }
 
 func (c *mysqlConn) WriteCommandPacket(packet packet.Packet) error {
@@ -152,12 +205,26 @@ func (c *mysqlConn) WriteCommandPacket(packet packet.Packet) error {
 	if err != nil {
 		return err
 	}
-	headerData := c.buildPacketHeader(len(data))
-	pktData := append(headerData, data...)
+	return c.writePayload(data)
+}
 
-	// TODO
-	//fmt.Println(hex.Dump(pktData))
-	return c.write(pktData)
+// writePayload splits payload into packets of maxPayloadLen bytes, the last packet
+// is shorter than maxPayloadLen, it's empty if the payload is a multiple of maxPayloadLen.
+func (c *mysqlConn) writePayload(data []byte) error {
+	for {
+		n := len(data)
+		if n > maxPayloadLen {
+			n = maxPayloadLen
+		}
+		if err := c.write(append(c.buildPacketHeader(n), data[:n]...)); err != nil {
+			return err
+		}
+		if n < maxPayloadLen {
+			return nil
+		}
+		data = data[n:]
+		c.sequence++
+	}
 }
 
 func (c *mysqlConn) write(pktData []byte) error {
@@ -198,11 +265,12 @@ func (c *mysqlConn) WriteError(err error) error {
 	return nil
 }
 
+// Close is safe to be called concurrently with other methods, such as server shutting down
+// a connection blocked in ReadPacket.
 func (c *mysqlConn) Close() error {
-	if c.closed {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
 		return nil
 	}
-	c.closed = true
 
 	if c.useTLS {
 		return c.tlsConn.Close()
@@ -212,7 +280,31 @@ func (c *mysqlConn) Close() error {
 }
 
 func (c *mysqlConn) Closed() bool {
-	return c.closed
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+func (c *mysqlConn) SetReadDeadline(t time.Time) error {
+	return c.getConnection().SetReadDeadline(t)
+}
+
+func (c *mysqlConn) SetWriteDeadline(t time.Time) error {
+	return c.getConnection().SetWriteDeadline(t)
+}
+
+// IsUnixSocket return true if conn is a Unix domain socket connection.
+func IsUnixSocket(conn Conn) bool {
+	addr := conn.LocalAddr()
+	return addr != nil && addr.Network() == "unix"
+}
+
+// IsSecureTransport return true if conn uses TLS or Unix socket,
+// caching_sha2_password sends password in cleartext on secure transport.
+func IsSecureTransport(conn Conn) bool {
+	return conn.TLSed() || IsUnixSocket(conn)
+}
+
+func (c *mysqlConn) SetMaxAllowedPacket(n int) {
+	c.maxAllowedPacket = n
 }
 
 func (c *mysqlConn) getConnection() net.Conn {
@@ -224,7 +316,7 @@ func (c *mysqlConn) getConnection() net.Conn {
 
 func (c *mysqlConn) next(n int) ([]byte, error) {
 	bs := make([]byte, n)
-	_, err := c.getConnection().Read(bs)
+	_, err := io.ReadFull(c.getConnection(), bs)
 	if err != nil {
 		return nil, err
 	}
@@ -17,7 +17,7 @@ func (r *Result) Write(conn Conn) error {
 		OKHeader:     0x00,
 		AffectedRows: r.AffectedRows,
 		LastInsertId: r.LastInsertId,
-		StatusFlags:  0,
-		WarningCount: 0,
+		StatusFlags:  r.Status,
+		WarningCount: uint16(r.WarningCount),
 	})
 }
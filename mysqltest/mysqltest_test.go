@@ -0,0 +1,99 @@
+package mysqltest
+
+import (
+	"bytes"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/server"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"io"
+	"net"
+	"testing"
+)
+
+func runClient(t *testing.T, dial func(network, address string) (net.Conn, error)) {
+	conn, err := client.CreateConnection(
+		client.WithDialer(dial),
+		client.WithUser("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := conn.Query("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := rows.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+	}
+
+	rs, err := conn.Exec("CREATE DATABASE db1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.AffectedRows != 1 {
+		t.Fatalf("expected 1 affected row, got %d", rs.AffectedRows)
+	}
+	conn.Close()
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := servertest.NewServer(server.NewDefaultHandler(), nil)
+
+	var rec *Conn
+	runClient(t, func(network, address string) (net.Conn, error) {
+		clientConn, err := servertest.Dialer(srv)(network, address)
+		if err != nil {
+			return nil, err
+		}
+		rec = Record(clientConn, ClientToServer)
+		return rec, nil
+	})
+
+	var buf bytes.Buffer
+	if err := rec.Session().Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	session, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Packets) == 0 || session.Packets[0].Direction != ServerToClient {
+		t.Fatalf("unexpected session: %v", session.Packets)
+	}
+
+	t.Run("FakeServer", func(t *testing.T) {
+		p := NewPlayer(session)
+		runClient(t, p.Dial)
+		if err := p.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("FakeClient", func(t *testing.T) {
+		p := NewPlayer(session)
+		go srv.ServeConn(p.FakeClient())
+		if err := p.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		p := NewPlayer(session)
+		conn, err := client.CreateConnection(client.WithDialer(p.Dial), client.WithUser("root"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Exec("DROP DATABASE db1")
+		if err := p.Wait(); err == nil {
+			t.Fatal("expected mismatch error")
+		}
+	})
+}
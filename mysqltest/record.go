@@ -0,0 +1,94 @@
+package mysqltest
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Conn wraps net.Conn used by mysql.Conn and records packets passing through it.
+//
+// TLS sessions can't be recorded, because packets are encrypted below mysql.Conn.
+type Conn struct {
+	net.Conn
+
+	// direction of packets written by the wrapped connection
+	out Direction
+	in  Direction
+
+	mu      sync.Mutex
+	start   time.Time
+	rBuf    []byte
+	wBuf    []byte
+	packets []Packet
+}
+
+// Record wraps conn which is the connection of client (local is ClientToServer) or server
+// (local is ServerToClient), for example, connection returned by net.Dial or net.Listener.Accept.
+func Record(conn net.Conn, local Direction) *Conn {
+	in := ServerToClient
+	if local == ServerToClient {
+		in = ClientToServer
+	}
+	return &Conn{
+		Conn:  conn,
+		out:   local,
+		in:    in,
+		start: time.Now(),
+	}
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.mu.Lock()
+		c.rBuf = c.split(append(c.rBuf, b[:n]...), c.in)
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.mu.Lock()
+		c.wBuf = c.split(append(c.wBuf, b[:n]...), c.out)
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// Session returns packets recorded so far.
+func (c *Conn) Session() *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	packets := make([]Packet, len(c.packets))
+	copy(packets, c.packets)
+	return &Session{Packets: packets}
+}
+
+// split records complete packets in buf and return the remaining bytes.
+func (c *Conn) split(buf []byte, d Direction) []byte {
+	for len(buf) >= 4 {
+		length := int(buf[0]) | int(buf[1])<<8 | int(buf[2])<<16
+		if len(buf) < 4+length {
+			break
+		}
+
+		payload := make([]byte, length)
+		copy(payload, buf[4:4+length])
+		c.packets = append(c.packets, Packet{
+			Direction: d,
+			Sequence:  buf[3],
+			Offset:    time.Since(c.start),
+			Payload:   payload,
+		})
+		buf = buf[4+length:]
+	}
+
+	// copy remaining bytes so that consumed bytes can be released
+	rest := make([]byte, len(buf))
+	copy(rest, buf)
+	return rest
+}
@@ -0,0 +1,187 @@
+package mysqltest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnexpectedPacket = errors.New("mysqltest: unexpected packet")
+)
+
+// CompareFunc compares packet received by Player with the recorded one.
+type CompareFunc func(expected, actual *Packet) error
+
+// Player replays recorded session as fake server or fake client.
+//
+// The peer's packets in connection phase are read but not compared by default,
+// because they contain random data, such as salt of handshake and connection attributes.
+// So server tests should use accounts whose authentication doesn't depend on salt,
+// such as accounts with empty password.
+type Player struct {
+	session *Session
+	compare CompareFunc
+	timing  bool
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func NewPlayer(session *Session, opts ...Option) *Player {
+	p := &Player{
+		session: session,
+		compare: CompareExact,
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.apply(p)
+	}
+	return p
+}
+
+// FakeServer returns connection for client, the recorded server packets are sent to it.
+// Player replays only once, connections returned by later calls are closed.
+func (p *Player) FakeServer() net.Conn {
+	return p.play(ServerToClient)
+}
+
+// FakeClient returns connection for server, the recorded client packets are sent to it.
+func (p *Player) FakeClient() net.Conn {
+	return p.play(ClientToServer)
+}
+
+// Dial has the signature of dial function and returns FakeServer.
+func (p *Player) Dial(network, address string) (net.Conn, error) {
+	return p.FakeServer(), nil
+}
+
+// Wait waits for replay to finish and return the first mismatch or I/O error.
+func (p *Player) Wait() error {
+	<-p.done
+	return p.err
+}
+
+func (p *Player) play(local Direction) net.Conn {
+	conn, peer := net.Pipe()
+
+	played := false
+	p.once.Do(func() {
+		played = true
+		go func() {
+			defer close(p.done)
+			defer conn.Close()
+			p.err = p.run(conn, local)
+		}()
+	})
+	if !played {
+		conn.Close()
+	}
+	return peer
+}
+
+func (p *Player) run(conn net.Conn, local Direction) error {
+	start := time.Now()
+	connectionPhase := true
+
+	for i := range p.session.Packets {
+		expected := &p.session.Packets[i]
+		if expected.Direction == ClientToServer && expected.Sequence == 0 {
+			connectionPhase = false
+		}
+
+		if p.timing {
+			if d := expected.Offset - time.Since(start); d > 0 {
+				time.Sleep(d)
+			}
+		}
+
+		if expected.Direction == local {
+			if err := writePacket(conn, expected); err != nil {
+				return fmt.Errorf("write packet %d %s: %v", i, expected, err)
+			}
+			continue
+		}
+
+		actual, err := readPacket(conn, expected.Direction)
+		if err != nil {
+			return fmt.Errorf("read packet %d %s: %v", i, expected, err)
+		}
+		actual.Offset = time.Since(start)
+		if connectionPhase {
+			continue
+		}
+		if err := p.compare(expected, actual); err != nil {
+			return fmt.Errorf("packet %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// CompareExact requires the same sequence and payload.
+func CompareExact(expected, actual *Packet) error {
+	if expected.Sequence != actual.Sequence || !bytes.Equal(expected.Payload, actual.Payload) {
+		return fmt.Errorf("%w: expected %s %x, got %s %x",
+			ErrUnexpectedPacket, expected, expected.Payload, actual, actual.Payload)
+	}
+	return nil
+}
+
+func writePacket(w io.Writer, pkt *Packet) error {
+	length := len(pkt.Payload)
+	data := make([]byte, 4, 4+length)
+	data[0] = byte(length)
+	data[1] = byte(length >> 8)
+	data[2] = byte(length >> 16)
+	data[3] = pkt.Sequence
+	data = append(data, pkt.Payload...)
+	_, err := w.Write(data)
+	return err
+}
+
+func readPacket(r io.Reader, d Direction) (*Packet, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return &Packet{
+		Direction: d,
+		Sequence:  header[3],
+		Payload:   payload,
+	}, nil
+}
+
+// WithCompare sets function comparing packets in command phase, default is CompareExact.
+func WithCompare(compare CompareFunc) Option {
+	return optionFun(func(p *Player) {
+		p.compare = compare
+	})
+}
+
+// WithTiming keeps recorded interval between packets.
+func WithTiming(timing bool) Option {
+	return optionFun(func(p *Player) {
+		p.timing = timing
+	})
+}
+
+type Option interface {
+	apply(*Player)
+}
+
+type optionFun func(*Player)
+
+func (f optionFun) apply(p *Player) {
+	f(p)
+}
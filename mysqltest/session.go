@@ -0,0 +1,124 @@
+// Package mysqltest records MySQL sessions at packet level and replays them,
+// so that client and server can be tested without a live peer.
+package mysqltest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	ErrUnknownDirection = errors.New("mysqltest: unknown direction")
+)
+
+// Direction is the direction a packet is sent in.
+type Direction uint8
+
+const (
+	ClientToServer Direction = iota
+	ServerToClient
+)
+
+func (d Direction) String() string {
+	switch d {
+	case ClientToServer:
+		return "client"
+	case ServerToClient:
+		return "server"
+	default:
+		return "Unknown Direction"
+	}
+}
+
+func (d Direction) MarshalText() ([]byte, error) {
+	switch d {
+	case ClientToServer, ServerToClient:
+		return []byte(d.String()), nil
+	default:
+		return nil, ErrUnknownDirection
+	}
+}
+
+func (d *Direction) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "client":
+		*d = ClientToServer
+	case "server":
+		*d = ServerToClient
+	default:
+		return ErrUnknownDirection
+	}
+	return nil
+}
+
+// Packet is a recorded MySQL packet.
+// https://dev.mysql.com/doc/internals/en/mysql-packet.html
+type Packet struct {
+	Direction Direction `json:"direction"`
+	Sequence  uint8     `json:"sequence"`
+	// Offset is elapsed time since the session started.
+	Offset  time.Duration `json:"offset"`
+	Payload []byte        `json:"payload"`
+}
+
+func (p *Packet) String() string {
+	return fmt.Sprintf("[%s seq=%d offset=%s len=%d]", p.Direction, p.Sequence, p.Offset, len(p.Payload))
+}
+
+// Session is a recorded conversation between a client and a server.
+type Session struct {
+	Packets []Packet
+}
+
+// Load reads session written by Save, one JSON packet per line.
+func Load(r io.Reader) (*Session, error) {
+	s := new(Session)
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var pkt Packet
+		if err := dec.Decode(&pkt); err != nil {
+			if err == io.EOF {
+				return s, nil
+			}
+			return nil, err
+		}
+		s.Packets = append(s.Packets, pkt)
+	}
+}
+
+func ReadFile(name string) (*Session, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Save writes session as JSON lines, payload is base64 encoded.
+func (s *Session) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for i := range s.Packets {
+		if err := enc.Encode(&s.Packets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Session) WriteFile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := s.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -32,6 +32,18 @@ func ParseStmtPrepareOKFirst(data []byte) (p *StmtPrepareOKFirst, err error) {
 	return p, nil
 }
 
+func (p *StmtPrepareOKFirst) Dump(capabilities flag.Capability) ([]byte, error) {
+	var payload bytes.Buffer
+	payload.WriteByte(p.Status)
+	payload.Write(FixedLengthInteger.Dump(uint64(p.StmtId), 4))
+	payload.Write(FixedLengthInteger.Dump(uint64(p.ColumnCount), 2))
+	payload.Write(FixedLengthInteger.Dump(uint64(p.ParamCount), 2))
+	// reserved_1
+	payload.WriteByte(0x00)
+	payload.Write(FixedLengthInteger.Dump(uint64(p.WarningCount), 2))
+	return payload.Bytes(), nil
+}
+
 // StmtExecute https://dev.mysql.com/doc/internals/en/com-stmt-execute.html
 type StmtExecute struct {
 	ComStmtExecute     uint8
@@ -44,6 +56,47 @@ type StmtExecute struct {
 	ParamValue         []byte
 }
 
+// ParseStmtExecute parses COM_STMT_EXECUTE, paramCount is returned by COM_STMT_PREPARE.
+// ParamValue is left undecoded, because param types may be bound by previous execution.
+func ParseStmtExecute(data []byte, paramCount int) (*StmtExecute, error) {
+	if len(data) < 1+4+1+4 {
+		return nil, ErrPacketData
+	}
+	p := new(StmtExecute)
+
+	buf := bytes.NewBuffer(data)
+	p.ComStmtExecute, _ = buf.ReadByte()
+	p.StmtId = uint32(FixedLengthInteger.Get(buf.Next(4)))
+	p.Flags, _ = buf.ReadByte()
+	p.IterationCount = uint32(FixedLengthInteger.Get(buf.Next(4)))
+
+	if paramCount > 0 {
+		nullBitMapLen := (paramCount + 7) / 8
+		if buf.Len() < nullBitMapLen+1 {
+			return nil, ErrPacketData
+		}
+		p.NullBitMap = buf.Next(nullBitMapLen)
+		p.NewParamsBoundFlag, _ = buf.ReadByte()
+
+		if p.NewParamsBoundFlag == 1 {
+			if buf.Len() < paramCount*2 {
+				return nil, ErrPacketData
+			}
+			p.ParamType = buf.Next(paramCount * 2)
+		}
+		p.ParamValue = buf.Bytes()
+	}
+
+	return p, nil
+}
+
+func (p *StmtExecute) NullBitMapGet(index int) bool {
+	if p.NullBitMap == nil {
+		return false
+	}
+	return (p.NullBitMap[index/8]>>(index%8))&1 != 0
+}
+
 func (p *StmtExecute) CreateNullBitMap(paramCount int) {
 	if p.NullBitMap == nil {
 		offset := 0
@@ -80,3 +133,32 @@ func (p *StmtExecute) Dump(capabilities flag.Capability) ([]byte, error) {
 
 	return payload.Bytes(), nil
 }
+
+// StmtSendLongData https://dev.mysql.com/doc/internals/en/com-stmt-send-long-data.html
+type StmtSendLongData struct {
+	ComStmtSendLongData uint8
+	StmtId              uint32
+	ParamId             uint16
+	Data                []byte
+}
+
+func ParseStmtSendLongData(data []byte) (*StmtSendLongData, error) {
+	if len(data) < 1+4+2 {
+		return nil, ErrPacketData
+	}
+	p := new(StmtSendLongData)
+	p.ComStmtSendLongData = data[0]
+	p.StmtId = uint32(FixedLengthInteger.Get(data[1:5]))
+	p.ParamId = uint16(FixedLengthInteger.Get(data[5:7]))
+	p.Data = data[7:]
+	return p, nil
+}
+
+func (p *StmtSendLongData) Dump(capabilities flag.Capability) ([]byte, error) {
+	var payload bytes.Buffer
+	payload.WriteByte(p.ComStmtSendLongData)
+	payload.Write(FixedLengthInteger.Dump(uint64(p.StmtId), 4))
+	payload.Write(FixedLengthInteger.Dump(uint64(p.ParamId), 2))
+	payload.Write(p.Data)
+	return payload.Bytes(), nil
+}
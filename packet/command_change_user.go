@@ -0,0 +1,150 @@
+package packet
+
+import (
+	"bytes"
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/charset"
+	"github.com/vczyh/mysql-protocol/flag"
+)
+
+// ChangeUser https://dev.mysql.com/doc/internals/en/com-change-user.html
+type ChangeUser struct {
+	ComChangeUser uint8
+	Username      []byte
+	AuthRes       []byte
+	Database      []byte
+	CharacterSet  *charset.Collation // nil if not sent
+	AuthPlugin    auth.Method
+
+	AttributeLen uint64
+	Attributes   []Attribute
+}
+
+func ParseChangeUser(data []byte, capabilities flag.Capability) (p *ChangeUser, err error) {
+	p = new(ChangeUser)
+	buf := bytes.NewBuffer(data)
+
+	if buf.Len() == 0 {
+		return nil, ErrPacketData
+	}
+	p.ComChangeUser = buf.Next(1)[0]
+
+	// Username
+	if p.Username, err = NulTerminatedString.Get(buf); err != nil {
+		return nil, err
+	}
+
+	// Password
+	if capabilities&flag.ClientSecureConnection != 0 {
+		if buf.Len() == 0 {
+			return nil, ErrPacketData
+		}
+		l := int(buf.Next(1)[0])
+		if buf.Len() < l {
+			return nil, ErrPacketData
+		}
+		p.AuthRes = buf.Next(l)
+	} else {
+		if p.AuthRes, err = NulTerminatedString.Get(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	// Database
+	if p.Database, err = NulTerminatedString.Get(buf); err != nil {
+		return nil, err
+	}
+
+	// more data is optional
+	if buf.Len() == 0 {
+		return p, nil
+	}
+
+	// Character Set
+	if buf.Len() < 2 {
+		return nil, ErrPacketData
+	}
+	if p.CharacterSet, err = charset.GetCollation(FixedLengthInteger.Get(buf.Next(2))); err != nil {
+		return nil, err
+	}
+
+	// Auth Plugin Name
+	if capabilities&flag.ClientPluginAuth != 0 {
+		pluginName, err := NulTerminatedString.Get(buf)
+		if err != nil {
+			return nil, err
+		}
+		if p.AuthPlugin, err = auth.ParseAuthenticationPlugin(string(pluginName)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Attributes
+	if capabilities&flag.ClientConnectAttrs != 0 && buf.Len() > 0 {
+		if p.AttributeLen, err = LengthEncodedInteger.Get(buf); err != nil {
+			return nil, err
+		}
+		before := buf.Len()
+		for before-buf.Len() < int(p.AttributeLen) {
+			key, err := LengthEncodedString.Get(buf)
+			if err != nil {
+				return nil, err
+			}
+			val, err := LengthEncodedString.Get(buf)
+			if err != nil {
+				return nil, err
+			}
+			p.Attributes = append(p.Attributes, Attribute{string(key), string(val)})
+		}
+	}
+
+	return p, nil
+}
+
+func (p *ChangeUser) Dump(capabilities flag.Capability) ([]byte, error) {
+	var payload bytes.Buffer
+	payload.WriteByte(p.ComChangeUser)
+
+	// Username
+	payload.Write(NulTerminatedString.Dump(p.Username))
+
+	// Password
+	if capabilities&flag.ClientSecureConnection != 0 {
+		payload.WriteByte(byte(len(p.AuthRes)))
+		payload.Write(p.AuthRes)
+	} else {
+		payload.Write(NulTerminatedString.Dump(p.AuthRes))
+	}
+
+	// Database
+	payload.Write(NulTerminatedString.Dump(p.Database))
+
+	if p.CharacterSet == nil {
+		return payload.Bytes(), nil
+	}
+
+	// Character Set
+	payload.Write(FixedLengthInteger.Dump(p.CharacterSet.Id(), 2))
+
+	// Auth Plugin Name
+	if capabilities&flag.ClientPluginAuth != 0 {
+		payload.Write(NulTerminatedString.Dump([]byte(p.AuthPlugin.String())))
+	}
+
+	// Attributes
+	if capabilities&flag.ClientConnectAttrs != 0 {
+		payload.Write(LengthEncodedInteger.Dump(p.AttributeLen))
+		for _, attribute := range p.Attributes {
+			payload.Write(LengthEncodedString.Dump([]byte(attribute.Key)))
+			payload.Write(LengthEncodedString.Dump([]byte(attribute.Val)))
+		}
+	}
+
+	return payload.Bytes(), nil
+}
+
+func (p *ChangeUser) AddAttribute(key string, val string) {
+	p.Attributes = append(p.Attributes, Attribute{key, val})
+	p.AttributeLen += uint64(len(LengthEncodedString.Dump([]byte(key))))
+	p.AttributeLen += uint64(len(LengthEncodedString.Dump([]byte(val))))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/vczyh/mysql-protocol/charset"
 	"github.com/vczyh/mysql-protocol/flag"
+	"math"
 	"reflect"
 	"strconv"
 	"time"
@@ -25,7 +26,10 @@ type ColumnDefinition struct {
 	Flags        flag.ColumnDefinition
 	Decimals     uint8
 
-	// TODO command was COM_FIELD_LIST
+	// FieldList is true if command was COM_FIELD_LIST, DefaultValues is sent,
+	// nil means NULL. They are not parsed.
+	FieldList     bool
+	DefaultValues []byte
 }
 
 func ParseColumnDefinition(bs []byte) (p *ColumnDefinition, err error) {
@@ -111,6 +115,14 @@ func (p *ColumnDefinition) Dump(capabilities flag.Capability) ([]byte, error) {
 
 	payload.Write([]byte{0x00, 0x00})
 
+	if p.FieldList {
+		if p.DefaultValues == nil {
+			payload.WriteByte(0xfb)
+		} else {
+			payload.Write(LengthEncodedString.Dump(p.DefaultValues))
+		}
+	}
+
 	return payload.Bytes(), nil
 }
 
@@ -155,7 +167,198 @@ func (cv *ColumnValue) DumpText() ([]byte, error) {
 	return LengthEncodedString.Dump([]byte(val)), nil
 }
 
-func (cv *ColumnValue) DumpBinary() ([]byte, error) {
-	// TODO implement
-	return nil, nil
+// BinaryType return MySQL type and whether it is unsigned that represents the Go value,
+// it is used by parameters of COM_STMT_EXECUTE.
+func (cv *ColumnValue) BinaryType() (flag.TableColumnType, bool, error) {
+	switch cv.Value.(type) {
+	case nil:
+		return flag.MySQLTypeNull, false, nil
+	case bool, int8:
+		return flag.MySQLTypeTiny, false, nil
+	case int16:
+		return flag.MySQLTypeShort, false, nil
+	case int32:
+		return flag.MySQLTypeLong, false, nil
+	case int, int64:
+		return flag.MySQLTypeLongLong, false, nil
+	case uint8:
+		return flag.MySQLTypeTiny, true, nil
+	case uint16:
+		return flag.MySQLTypeShort, true, nil
+	case uint32:
+		return flag.MySQLTypeLong, true, nil
+	case uint, uint64:
+		return flag.MySQLTypeLongLong, true, nil
+	case float32:
+		return flag.MySQLTypeFloat, false, nil
+	case float64:
+		return flag.MySQLTypeDouble, false, nil
+	case string, []byte:
+		return flag.MySQLTypeVarString, false, nil
+	case time.Time:
+		return flag.MySQLTypeDatetime, false, nil
+	case time.Duration:
+		return flag.MySQLTypeTime, false, nil
+	default:
+		return flag.MySQLTypeNull, false, fmt.Errorf("unsupported type %T", cv.Value)
+	}
+}
+
+// DumpBinary encodes value as columnType in binary protocol.
+// https://dev.mysql.com/doc/internals/en/binary-protocol-value.html
+func (cv *ColumnValue) DumpBinary(columnType flag.TableColumnType) ([]byte, error) {
+	switch columnType {
+	case flag.MySQLTypeNull:
+		return nil, nil
+
+	case flag.MySQLTypeTiny:
+		v, err := cv.uint64()
+		return FixedLengthInteger.Dump(v, 1), err
+
+	case flag.MySQLTypeShort, flag.MySQLTypeYear:
+		v, err := cv.uint64()
+		return FixedLengthInteger.Dump(v, 2), err
+
+	case flag.MySQLTypeInt24, flag.MySQLTypeLong:
+		v, err := cv.uint64()
+		return FixedLengthInteger.Dump(v, 4), err
+
+	case flag.MySQLTypeLongLong:
+		v, err := cv.uint64()
+		return FixedLengthInteger.Dump(v, 8), err
+
+	case flag.MySQLTypeFloat:
+		v, err := cv.float64()
+		return FixedLengthInteger.Dump(uint64(math.Float32bits(float32(v))), 4), err
+
+	case flag.MySQLTypeDouble:
+		v, err := cv.float64()
+		return FixedLengthInteger.Dump(math.Float64bits(v), 8), err
+
+	case flag.MySQLTypeDate, flag.MySQLTypeDatetime, flag.MySQLTypeTimestamp:
+		t, ok := cv.Value.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("unsupported type %T for %s", cv.Value, columnType)
+		}
+		return dumpBinaryDatetime(t, columnType == flag.MySQLTypeDate), nil
+
+	case flag.MySQLTypeTime:
+		switch v := cv.Value.(type) {
+		case time.Duration:
+			return dumpBinaryTime(v), nil
+		case int64:
+			return dumpBinaryTime(time.Duration(v)), nil
+		default:
+			return nil, fmt.Errorf("unsupported type %T for %s", cv.Value, columnType)
+		}
+
+	default:
+		// string types are sent as length encoded string of text representation
+		if cv.Value == nil {
+			return nil, nil
+		}
+		return cv.DumpText()
+	}
+}
+
+func (cv *ColumnValue) uint64() (uint64, error) {
+	if b, ok := cv.Value.(bool); ok {
+		if b {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	rv := reflect.ValueOf(cv.Value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return uint64(int64(rv.Float())), nil
+	default:
+		return 0, fmt.Errorf("unsupported type %T for integer", cv.Value)
+	}
+}
+
+func (cv *ColumnValue) float64() (float64, error) {
+	rv := reflect.ValueOf(cv.Value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	default:
+		return 0, fmt.Errorf("unsupported type %T for float", cv.Value)
+	}
+}
+
+func dumpBinaryDatetime(t time.Time, dateOnly bool) []byte {
+	if t.IsZero() {
+		return []byte{0x00}
+	}
+
+	var buf bytes.Buffer
+	switch {
+	case dateOnly || t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0:
+		buf.WriteByte(4)
+	case t.Nanosecond() == 0:
+		buf.WriteByte(7)
+	default:
+		buf.WriteByte(11)
+	}
+	l := buf.Bytes()[0]
+
+	buf.Write(FixedLengthInteger.Dump(uint64(t.Year()), 2))
+	buf.WriteByte(byte(t.Month()))
+	buf.WriteByte(byte(t.Day()))
+	if l >= 7 {
+		buf.WriteByte(byte(t.Hour()))
+		buf.WriteByte(byte(t.Minute()))
+		buf.WriteByte(byte(t.Second()))
+	}
+	if l == 11 {
+		buf.Write(FixedLengthInteger.Dump(uint64(t.Nanosecond()/1000), 4))
+	}
+	return buf.Bytes()
+}
+
+func dumpBinaryTime(d time.Duration) []byte {
+	if d == 0 {
+		return []byte{0x00}
+	}
+
+	var isNegative byte
+	if d < 0 {
+		isNegative = 1
+		d = -d
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+	seconds := d / time.Second
+	d -= seconds * time.Second
+	microSecs := d / time.Microsecond
+
+	var buf bytes.Buffer
+	if microSecs == 0 {
+		buf.WriteByte(8)
+	} else {
+		buf.WriteByte(12)
+	}
+	buf.WriteByte(isNegative)
+	buf.Write(FixedLengthInteger.Dump(uint64(days), 4))
+	buf.WriteByte(byte(hours))
+	buf.WriteByte(byte(minutes))
+	buf.WriteByte(byte(seconds))
+	if microSecs != 0 {
+		buf.Write(FixedLengthInteger.Dump(uint64(microSecs), 4))
+	}
+	return buf.Bytes()
 }
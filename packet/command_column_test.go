@@ -1,8 +1,12 @@
 package packet
 
 import (
+	"bytes"
 	"github.com/vczyh/mysql-protocol/flag"
+	"math"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestTableColumnType(t *testing.T) {
@@ -11,3 +15,102 @@ func TestTableColumnType(t *testing.T) {
 	t.Log(uint8(flag.MySQLTypeGeometry))
 	t.Log(uint8(flag.MySQLTypeString))
 }
+
+func TestColumnValueDumpBinary(t *testing.T) {
+	dt := time.Date(2021, 1, 2, 3, 4, 5, 6000, time.UTC)
+	tests := []struct {
+		value      interface{}
+		columnType flag.TableColumnType
+		unsigned   bool
+		// want is value parsed by ParseBinaryResultSetRow
+		want interface{}
+	}{
+		{nil, flag.MySQLTypeNull, false, nil},
+		{true, flag.MySQLTypeTiny, false, int8(1)},
+		{int8(-1), flag.MySQLTypeTiny, false, int8(-1)},
+		{uint8(255), flag.MySQLTypeTiny, true, uint8(255)},
+		{int16(-300), flag.MySQLTypeShort, false, int16(-300)},
+		{uint16(65535), flag.MySQLTypeShort, true, uint16(65535)},
+		{int32(-70000), flag.MySQLTypeLong, false, int32(-70000)},
+		{uint32(70000), flag.MySQLTypeLong, true, uint32(70000)},
+		{1, flag.MySQLTypeLongLong, false, int64(1)},
+		{int64(math.MinInt64), flag.MySQLTypeLongLong, false, int64(math.MinInt64)},
+		{uint64(math.MaxUint64), flag.MySQLTypeLongLong, true, uint64(math.MaxUint64)},
+		{float32(1.5), flag.MySQLTypeFloat, false, float32(1.5)},
+		{-2.25, flag.MySQLTypeDouble, false, -2.25},
+		{"hello", flag.MySQLTypeVarString, false, []byte("hello")},
+		{[]byte{0x00, 0xff}, flag.MySQLTypeVarString, false, []byte{0x00, 0xff}},
+		{dt, flag.MySQLTypeDatetime, false, dt},
+		{dt.Truncate(time.Second), flag.MySQLTypeDatetime, false, dt.Truncate(time.Second)},
+		{time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC), flag.MySQLTypeDatetime, false, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{-(26*time.Hour + 3*time.Second), flag.MySQLTypeTime, false, int64(-(26*time.Hour + 3*time.Second))},
+		{time.Hour + 7*time.Microsecond, flag.MySQLTypeTime, false, int64(time.Hour + 7*time.Microsecond)},
+	}
+
+	// binary result set row having all values
+	nullBitMap := make([]byte, (len(tests)+7+2)>>3)
+	data := []byte{0x00}
+	var values []byte
+	var columns []*ColumnDefinition
+	for i, tt := range tests {
+		cv := &ColumnValue{Value: tt.value}
+		columnType, unsigned, err := cv.BinaryType()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if columnType != tt.columnType || unsigned != tt.unsigned {
+			t.Fatalf("BinaryType() of %#v = %s %v, want %s %v", tt.value, columnType, unsigned, tt.columnType, tt.unsigned)
+		}
+		v, err := cv.DumpBinary(columnType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.value == nil {
+			nullBitMap[(i+2)>>3] |= 1 << ((i + 2) % 8)
+		}
+		values = append(values, v...)
+
+		column := &ColumnDefinition{ColumnType: columnType}
+		if unsigned {
+			column.Flags |= flag.UnsignedFlag
+		}
+		columns = append(columns, column)
+	}
+	data = append(append(data, nullBitMap...), values...)
+
+	row, err := ParseBinaryResultSetRow(data, columns, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		if got := row[i].Value; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%#v: parsed %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		value      interface{}
+		columnType flag.TableColumnType
+		want       []byte
+	}{
+		{int64(-1), flag.MySQLTypeLongLong, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{dt, flag.MySQLTypeDatetime, []byte{11, 0xe5, 0x07, 1, 2, 3, 4, 5, 0x06, 0x00, 0x00, 0x00}},
+		{dt, flag.MySQLTypeDate, []byte{4, 0xe5, 0x07, 1, 2}},
+		{time.Time{}, flag.MySQLTypeDatetime, []byte{0}},
+		{time.Duration(0), flag.MySQLTypeTime, []byte{0}},
+		{"1.50", flag.MySQLTypeNewDecimal, []byte{4, '1', '.', '5', '0'}},
+	} {
+		cv := &ColumnValue{Value: tt.value}
+		got, err := cv.DumpBinary(tt.columnType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("DumpBinary(%s) of %#v = %x, want %x", tt.columnType, tt.value, got, tt.want)
+		}
+	}
+
+	if _, _, err := (&ColumnValue{Value: struct{}{}}).BinaryType(); err == nil {
+		t.Fatal("expected error of unsupported type")
+	}
+}
@@ -174,13 +174,130 @@ func (p *TextResultSetRow) Dump(capabilities flag.Capability) ([]byte, error) {
 	return payload.Bytes(), nil
 }
 
+// RawValue is an undecoded column value of TextResultSetRow.
+// Data refers to packet data directly and is not copied.
+type RawValue struct {
+	Data []byte
+	Null bool
+}
+
+// ParseTextResultSetRowRaw splits data into columnCount values without copying or converting them.
+// values is reused if it has enough capacity, so that no allocation happens for every row.
+func ParseTextResultSetRowRaw(data []byte, columnCount int, values []RawValue) ([]RawValue, error) {
+	values = values[:0]
+
+	pos := 0
+	for i := 0; i < columnCount; i++ {
+		if pos >= len(data) {
+			return nil, ErrPacketData
+		}
+
+		if data[pos] == 0xfb {
+			values = append(values, RawValue{Null: true})
+			pos++
+			continue
+		}
+
+		l, n, err := LengthEncodedInteger.Decode(data[pos:])
+		if err != nil {
+			return nil, ErrPacketData
+		}
+		pos += n
+		if uint64(len(data)-pos) < l {
+			return nil, ErrPacketData
+		}
+		end := pos + int(l)
+		values = append(values, RawValue{Data: data[pos:end:end]})
+		pos = end
+	}
+
+	return values, nil
+}
+
+func (v RawValue) IsNull() bool {
+	return v.Null
+}
+
+func (v RawValue) Bytes() []byte {
+	return v.Data
+}
+
+func (v RawValue) String() string {
+	if v.Null {
+		return "NULL"
+	}
+	return string(v.Data)
+}
+
+func (v RawValue) Int64() (int64, error) {
+	data := v.Data
+	neg := len(data) > 0 && data[0] == '-'
+	if neg {
+		data = data[1:]
+	}
+
+	u, err := parseUint(data)
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		if u > 1<<63 {
+			return 0, strconv.ErrRange
+		}
+		return -int64(u), nil
+	}
+	if u > math.MaxInt64 {
+		return 0, strconv.ErrRange
+	}
+	return int64(u), nil
+}
+
+func (v RawValue) Uint64() (uint64, error) {
+	return parseUint(v.Data)
+}
+
+func (v RawValue) Float64() (float64, error) {
+	return strconv.ParseFloat(string(v.Data), 64)
+}
+
+// Time decodes DATE, DATETIME and TIMESTAMP value.
+func (v RawValue) Time(loc *time.Location) (time.Time, error) {
+	dt, err := parseDatetime(string(v.Data), loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return *dt, nil
+}
+
+func parseUint(data []byte) (uint64, error) {
+	if len(data) == 0 {
+		return 0, strconv.ErrSyntax
+	}
+
+	var u uint64
+	for _, c := range data {
+		if c < '0' || c > '9' {
+			return 0, strconv.ErrSyntax
+		}
+		if u > (math.MaxUint64-uint64(c-'0'))/10 {
+			return 0, strconv.ErrRange
+		}
+		u = u*10 + uint64(c-'0')
+	}
+	return u, nil
+}
+
+// BinaryResultSetRow https://dev.mysql.com/doc/internals/en/binary-protocol-resultset-row.html
 type BinaryResultSetRow struct {
 	PktHeader  byte // 0x00
 	NullBitMap []byte
 	Row        Row
+
+	// ColumnTypes is used by Dump to encode values of Row.
+	ColumnTypes []flag.TableColumnType
 }
 
-func ParseBinaryResultSetRow(data []byte, columns []ColumnDefinition, loc *time.Location) (Row, error) {
+func ParseBinaryResultSetRow(data []byte, columns []*ColumnDefinition, loc *time.Location) (Row, error) {
 	var p BinaryResultSetRow
 	var err error
 
@@ -195,147 +312,167 @@ func ParseBinaryResultSetRow(data []byte, columns []ColumnDefinition, loc *time.
 
 	values := make([]ColumnValue, columnCount)
 	for i := range columns {
-		var cv ColumnValue
-		//cv := ColumnValue{mysqlType: columns[i].GetType()}
-
 		if p.NullBitMapGet(i) {
-			cv.Value = nil
-			//cv.isNull = true
 			continue
 		}
 
-		flags := columns[i].Flags
-
-		// https://dev.mysql.com/doc/internals/en/binary-protocol-value.html
-		switch columns[i].ColumnType {
-		case flag.MySQLTypeTiny:
-			val := FixedLengthInteger.Get(buf.Next(1))
-			if flags&flag.UnsignedFlag != 0 {
-				cv.Value = uint8(val)
-			} else {
-				cv.Value = int8(val)
-			}
+		unsigned := columns[i].Flags&flag.UnsignedFlag != 0
+		if values[i].Value, err = ParseBinaryValue(buf, columns[i].ColumnType, unsigned, loc); err != nil {
+			return nil, err
+		}
+	}
 
-		case flag.MySQLTypeShort, flag.MySQLTypeYear:
-			val := FixedLengthInteger.Get(buf.Next(2))
-			if flags&flag.UnsignedFlag != 0 {
-				cv.Value = uint16(val)
-			} else {
-				cv.Value = int16(val)
-			}
+	for _, val := range values {
+		p.Row = append(p.Row, val)
+	}
 
-		case flag.MySQLTypeInt24, flag.MySQLTypeLong:
-			val := FixedLengthInteger.Get(buf.Next(4))
-			if flags&flag.UnsignedFlag != 0 {
-				cv.Value = uint32(val)
-			} else {
-				cv.Value = int32(val)
-			}
+	return p.Row, nil
+}
 
-		case flag.MySQLTypeLongLong:
-			val := FixedLengthInteger.Get(buf.Next(8))
-			if flags&flag.UnsignedFlag != 0 {
-				cv.Value = val
-			} else {
-				cv.Value = int64(val)
-			}
+// ParseBinaryValue reads a value of binary protocol from buf, it is used by BinaryResultSetRow
+// and parameters of COM_STMT_EXECUTE.
+// https://dev.mysql.com/doc/internals/en/binary-protocol-value.html
+func ParseBinaryValue(buf *bytes.Buffer, columnType flag.TableColumnType, unsigned bool, loc *time.Location) (interface{}, error) {
+	switch columnType {
+	case flag.MySQLTypeNull:
+		return nil, nil
+
+	case flag.MySQLTypeTiny:
+		if buf.Len() < 1 {
+			return nil, ErrPacketData
+		}
+		val := FixedLengthInteger.Get(buf.Next(1))
+		if unsigned {
+			return uint8(val), nil
+		}
+		return int8(val), nil
 
-		case flag.MySQLTypeFloat:
-			cv.Value = math.Float32frombits(uint32(FixedLengthInteger.Get(buf.Next(4))))
+	case flag.MySQLTypeShort, flag.MySQLTypeYear:
+		if buf.Len() < 2 {
+			return nil, ErrPacketData
+		}
+		val := FixedLengthInteger.Get(buf.Next(2))
+		if unsigned {
+			return uint16(val), nil
+		}
+		return int16(val), nil
 
-		case flag.MySQLTypeDouble:
-			cv.Value = math.Float64frombits(FixedLengthInteger.Get(buf.Next(8)))
+	case flag.MySQLTypeInt24, flag.MySQLTypeLong:
+		if buf.Len() < 4 {
+			return nil, ErrPacketData
+		}
+		val := FixedLengthInteger.Get(buf.Next(4))
+		if unsigned {
+			return uint32(val), nil
+		}
+		return int32(val), nil
 
-		case flag.MySQLTypeVarchar,
-			flag.MySQLTypeBit,
-			flag.MySQLTypeEnum,
-			flag.MySQLTypeSet,
-			flag.MySQLTypeTinyBlob, flag.MySQLTypeMediumBlob, flag.MySQLTypeLongBlob, flag.MySQLTypeBlob,
-			flag.MySQLTypeVarString, flag.MySQLTypeString,
-			flag.MySQLTypeDecimal, flag.MySQLTypeNewDecimal:
-			data, err := LengthEncodedString.Get(buf)
-			if err != nil {
-				return nil, err
-			}
-			cv.Value = data
+	case flag.MySQLTypeLongLong:
+		if buf.Len() < 8 {
+			return nil, ErrPacketData
+		}
+		val := FixedLengthInteger.Get(buf.Next(8))
+		if unsigned {
+			return val, nil
+		}
+		return int64(val), nil
 
-		case flag.MySQLTypeDate, flag.MySQLTypeDatetime, flag.MySQLTypeTimestamp:
-			dataLen := FixedLengthInteger.Get(buf.Next(1))
-			if dataLen == 0 {
-				cv.Value = time.Time{}
-				continue
-			}
+	case flag.MySQLTypeFloat:
+		if buf.Len() < 4 {
+			return nil, ErrPacketData
+		}
+		return math.Float32frombits(uint32(FixedLengthInteger.Get(buf.Next(4)))), nil
 
-			switch dataLen {
-			case 0:
-				cv.Value = time.Time{}
-			case 4:
-				cv.Value = time.Date(
-					int(FixedLengthInteger.Get(buf.Next(2))),
-					time.Month(int(FixedLengthInteger.Get(buf.Next(1)))),
-					int(FixedLengthInteger.Get(buf.Next(1))),
-					0, 0, 0, 0, loc)
-			case 7:
-				cv.Value = time.Date(
-					int(FixedLengthInteger.Get(buf.Next(2))),
-					time.Month(int(FixedLengthInteger.Get(buf.Next(1)))),
-					int(FixedLengthInteger.Get(buf.Next(1))),
-					int(FixedLengthInteger.Get(buf.Next(1))),
-					int(FixedLengthInteger.Get(buf.Next(1))),
-					int(FixedLengthInteger.Get(buf.Next(1))),
-					0, loc)
-			case 11:
-				cv.Value = time.Date(
-					int(FixedLengthInteger.Get(buf.Next(2))),
-					time.Month(int(FixedLengthInteger.Get(buf.Next(1)))),
-					int(FixedLengthInteger.Get(buf.Next(1))),
-					int(FixedLengthInteger.Get(buf.Next(1))),
-					int(FixedLengthInteger.Get(buf.Next(1))),
-					int(FixedLengthInteger.Get(buf.Next(1))),
-					int(FixedLengthInteger.Get(buf.Next(4)))*1000,
-					loc)
-			}
+	case flag.MySQLTypeDouble:
+		if buf.Len() < 8 {
+			return nil, ErrPacketData
+		}
+		return math.Float64frombits(FixedLengthInteger.Get(buf.Next(8))), nil
+
+	case flag.MySQLTypeVarchar,
+		flag.MySQLTypeBit,
+		flag.MySQLTypeEnum,
+		flag.MySQLTypeSet,
+		flag.MySQLTypeTinyBlob, flag.MySQLTypeMediumBlob, flag.MySQLTypeLongBlob, flag.MySQLTypeBlob,
+		flag.MySQLTypeVarString, flag.MySQLTypeString,
+		flag.MySQLTypeDecimal, flag.MySQLTypeNewDecimal,
+		flag.MySQLTypeJson, flag.MySQLTypeGeometry:
+		data, err := LengthEncodedString.Get(buf)
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
 
-		case flag.MySQLTypeTime:
-			dataLen := FixedLengthInteger.Get(buf.Next(1))
-			if dataLen == 0 {
-				cv.Value = time.Time{}
-				continue
-			}
+	case flag.MySQLTypeDate, flag.MySQLTypeDatetime, flag.MySQLTypeTimestamp:
+		dataLen := FixedLengthInteger.Get(buf.Next(1))
+		if buf.Len() < int(dataLen) {
+			return nil, ErrPacketData
+		}
+		switch dataLen {
+		case 0:
+			return time.Time{}, nil
+		case 4:
+			return time.Date(
+				int(FixedLengthInteger.Get(buf.Next(2))),
+				time.Month(int(FixedLengthInteger.Get(buf.Next(1)))),
+				int(FixedLengthInteger.Get(buf.Next(1))),
+				0, 0, 0, 0, loc), nil
+		case 7:
+			return time.Date(
+				int(FixedLengthInteger.Get(buf.Next(2))),
+				time.Month(int(FixedLengthInteger.Get(buf.Next(1)))),
+				int(FixedLengthInteger.Get(buf.Next(1))),
+				int(FixedLengthInteger.Get(buf.Next(1))),
+				int(FixedLengthInteger.Get(buf.Next(1))),
+				int(FixedLengthInteger.Get(buf.Next(1))),
+				0, loc), nil
+		case 11:
+			return time.Date(
+				int(FixedLengthInteger.Get(buf.Next(2))),
+				time.Month(int(FixedLengthInteger.Get(buf.Next(1)))),
+				int(FixedLengthInteger.Get(buf.Next(1))),
+				int(FixedLengthInteger.Get(buf.Next(1))),
+				int(FixedLengthInteger.Get(buf.Next(1))),
+				int(FixedLengthInteger.Get(buf.Next(1))),
+				int(FixedLengthInteger.Get(buf.Next(4)))*1000,
+				loc), nil
+		default:
+			return nil, ErrPacketData
+		}
 
-			isNegative := FixedLengthInteger.Get(buf.Next(1)) == 1
-			day := int(FixedLengthInteger.Get(buf.Next(4)))
-			hour := int(FixedLengthInteger.Get(buf.Next(1)))
-			min := int(FixedLengthInteger.Get(buf.Next(1)))
-			sec := int(FixedLengthInteger.Get(buf.Next(1)))
+	case flag.MySQLTypeTime:
+		dataLen := FixedLengthInteger.Get(buf.Next(1))
+		if dataLen == 0 {
+			return int64(0), nil
+		}
+		if (dataLen != 8 && dataLen != 12) || buf.Len() < int(dataLen) {
+			return nil, ErrPacketData
+		}
 
-			var microSec int
-			if dataLen == 12 {
-				microSec = int(FixedLengthInteger.Get(buf.Next(4)))
-			}
+		isNegative := FixedLengthInteger.Get(buf.Next(1)) == 1
+		day := int(FixedLengthInteger.Get(buf.Next(4)))
+		hour := int(FixedLengthInteger.Get(buf.Next(1)))
+		min := int(FixedLengthInteger.Get(buf.Next(1)))
+		sec := int(FixedLengthInteger.Get(buf.Next(1)))
 
-			sum := time.Duration(24*day+hour)*time.Hour +
-				time.Duration(min)*time.Minute +
-				time.Duration(sec)*time.Second +
-				time.Duration(microSec)*time.Microsecond
+		var microSec int
+		if dataLen == 12 {
+			microSec = int(FixedLengthInteger.Get(buf.Next(4)))
+		}
 
-			if isNegative {
-				sum = -sum
-			}
-			cv.Value = int64(sum)
+		sum := time.Duration(24*day+hour)*time.Hour +
+			time.Duration(min)*time.Minute +
+			time.Duration(sec)*time.Second +
+			time.Duration(microSec)*time.Microsecond
 
-		default:
-			return nil, fmt.Errorf("not supported mysql type: %s", columns[i].ColumnType)
+		if isNegative {
+			sum = -sum
 		}
+		return int64(sum), nil
 
-		values[i] = cv
-	}
-
-	for _, val := range values {
-		p.Row = append(p.Row, val)
+	default:
+		return nil, fmt.Errorf("not supported mysql type: %s", columnType)
 	}
-
-	return p.Row, nil
 }
 
 func (p *BinaryResultSetRow) NullBitMapGet(index int) bool {
@@ -348,9 +485,38 @@ func (p *BinaryResultSetRow) NullBitMapGet(index int) bool {
 	return (p.NullBitMap[bytePos]>>bitPos)&1 != 0
 }
 
+func (p *BinaryResultSetRow) NullBitMapSet(index int) {
+	offset := 2
+	bytePos := (index + offset) >> 3
+	bitPos := (index + offset) % 8
+	p.NullBitMap[bytePos] |= 1 << bitPos
+}
+
 func (p *BinaryResultSetRow) Dump(capability flag.Capability) ([]byte, error) {
-	// TODO
-	panic("implement me")
+	if len(p.ColumnTypes) != len(p.Row) {
+		return nil, ErrPacketData
+	}
+
+	p.NullBitMap = make([]byte, (len(p.Row)+7+2)>>3)
+	var values bytes.Buffer
+	for i := range p.Row {
+		cv := &p.Row[i]
+		if cv.Value == nil {
+			p.NullBitMapSet(i)
+			continue
+		}
+		data, err := cv.DumpBinary(p.ColumnTypes[i])
+		if err != nil {
+			return nil, err
+		}
+		values.Write(data)
+	}
+
+	var payload bytes.Buffer
+	payload.WriteByte(p.PktHeader)
+	payload.Write(p.NullBitMap)
+	payload.Write(values.Bytes())
+	return payload.Bytes(), nil
 }
 
 func parseDatetime(datetime string, loc *time.Location) (*time.Time, error) {
@@ -0,0 +1,139 @@
+package packet
+
+import (
+	"github.com/vczyh/mysql-protocol/flag"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTextResultSetRowRaw(t *testing.T) {
+	data := []byte{
+		0x03, '1', '2', '3',
+		0xfb,
+		0x00,
+		0x05, 'h', 'e', 'l', 'l', 'o',
+	}
+
+	values, err := ParseTextResultSetRowRaw(data, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 4 {
+		t.Fatalf("len(values) = %d, want 4", len(values))
+	}
+
+	if i, err := values[0].Int64(); err != nil || i != 123 {
+		t.Fatalf("values[0].Int64() = %d, %v", i, err)
+	}
+	if !values[1].IsNull() {
+		t.Fatalf("values[1] should be NULL")
+	}
+	if values[2].IsNull() || len(values[2].Bytes()) != 0 {
+		t.Fatalf("values[2] should be empty string")
+	}
+	if values[3].String() != "hello" {
+		t.Fatalf("values[3].String() = %s", values[3].String())
+	}
+
+	if _, err := ParseTextResultSetRowRaw(data[:len(data)-1], 4, values); err != ErrPacketData {
+		t.Fatalf("ParseTextResultSetRowRaw() error = %v, want %v", err, ErrPacketData)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		values, _ = ParseTextResultSetRowRaw(data, 4, values)
+		_, _ = values[0].Int64()
+	})
+	if allocs != 0 {
+		t.Fatalf("allocs = %v, want 0", allocs)
+	}
+}
+
+func TestRawValueInt64(t *testing.T) {
+	tests := []struct {
+		data string
+		want int64
+		err  bool
+	}{
+		{"0", 0, false},
+		{"-42", -42, false},
+		{"9223372036854775807", 9223372036854775807, false},
+		{"-9223372036854775808", -9223372036854775808, false},
+		{"9223372036854775808", 0, true},
+		{"1.5", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := RawValue{Data: []byte(tt.data)}.Int64()
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("Int64(%q) = %d, %v", tt.data, got, err)
+		}
+	}
+}
+
+func TestBinaryResultSetRowRoundTrip(t *testing.T) {
+	dt := time.Date(2021, 1, 2, 3, 4, 5, 6000, time.UTC)
+	tests := []struct {
+		columnType flag.TableColumnType
+		unsigned   bool
+		value      interface{}
+		want       interface{}
+	}{
+		{flag.MySQLTypeTiny, false, int8(-1), int8(-1)},
+		{flag.MySQLTypeTiny, true, uint8(255), uint8(255)},
+		{flag.MySQLTypeTiny, false, true, int8(1)},
+		{flag.MySQLTypeShort, false, int16(-300), int16(-300)},
+		{flag.MySQLTypeYear, true, uint16(2021), uint16(2021)},
+		{flag.MySQLTypeLong, false, int32(-70000), int32(-70000)},
+		{flag.MySQLTypeInt24, true, uint32(70000), uint32(70000)},
+		{flag.MySQLTypeLongLong, false, int64(math.MinInt64), int64(math.MinInt64)},
+		{flag.MySQLTypeLongLong, true, uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{flag.MySQLTypeFloat, false, float32(1.5), float32(1.5)},
+		{flag.MySQLTypeDouble, false, -2.25, -2.25},
+		{flag.MySQLTypeVarString, false, "hello", []byte("hello")},
+		{flag.MySQLTypeBlob, false, []byte{0x00, 0xff}, []byte{0x00, 0xff}},
+		{flag.MySQLTypeNewDecimal, false, "1.50", []byte("1.50")},
+		{flag.MySQLTypeNull, false, nil, nil},
+		{flag.MySQLTypeDate, false, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{flag.MySQLTypeDatetime, false, dt.Truncate(time.Second), dt.Truncate(time.Second)},
+		{flag.MySQLTypeTimestamp, false, dt, dt},
+		{flag.MySQLTypeDatetime, false, time.Time{}, time.Time{}},
+		{flag.MySQLTypeTime, false, time.Duration(0), int64(0)},
+		{flag.MySQLTypeTime, false, -(26*time.Hour + 3*time.Second), int64(-(26*time.Hour + 3*time.Second))},
+		{flag.MySQLTypeTime, false, time.Hour + 7*time.Microsecond, int64(time.Hour + 7*time.Microsecond)},
+	}
+
+	row := &BinaryResultSetRow{}
+	var columns []*ColumnDefinition
+	for _, tt := range tests {
+		row.Row = append(row.Row, ColumnValue{Value: tt.value})
+		row.ColumnTypes = append(row.ColumnTypes, tt.columnType)
+		column := &ColumnDefinition{ColumnType: tt.columnType}
+		if tt.unsigned {
+			column.Flags |= flag.UnsignedFlag
+		}
+		columns = append(columns, column)
+	}
+	data, err := row.Dump(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseBinaryResultSetRow(data, columns, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(tests) {
+		t.Fatalf("len(parsed) = %d, want %d", len(parsed), len(tests))
+	}
+	for i, tt := range tests {
+		if got := parsed[i].Value; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parsed %#v, want %#v", tt.columnType, got, tt.want)
+		}
+	}
+
+	if _, err := ParseBinaryResultSetRow(data[:len(data)-1], columns, time.UTC); err == nil {
+		t.Fatal("expected error of truncated row")
+	}
+}
@@ -0,0 +1,69 @@
+package packet
+
+import (
+	"fmt"
+	"github.com/vczyh/mysql-protocol/flag"
+	"strconv"
+	"strings"
+)
+
+// Statistics is the response of COM_STATISTICS, it is a human readable string.
+// https://dev.mysql.com/doc/internals/en/com-statistics.html
+type Statistics struct {
+	Uptime              uint64
+	Threads             uint64
+	Questions           uint64
+	SlowQueries         uint64
+	Opens               uint64
+	FlushTables         uint64
+	OpenTables          uint64
+	QueriesPerSecondAvg float64
+}
+
+func ParseStatistics(data []byte) (*Statistics, error) {
+	p := new(Statistics)
+
+	for _, field := range strings.Split(string(data), "  ") {
+		kv := strings.SplitN(field, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, val := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+
+		var err error
+		switch key {
+		case "Uptime":
+			p.Uptime, err = strconv.ParseUint(val, 10, 64)
+		case "Threads":
+			p.Threads, err = strconv.ParseUint(val, 10, 64)
+		case "Questions":
+			p.Questions, err = strconv.ParseUint(val, 10, 64)
+		case "Slow queries":
+			p.SlowQueries, err = strconv.ParseUint(val, 10, 64)
+		case "Opens":
+			p.Opens, err = strconv.ParseUint(val, 10, 64)
+		case "Flush tables":
+			p.FlushTables, err = strconv.ParseUint(val, 10, 64)
+		case "Open tables":
+			p.OpenTables, err = strconv.ParseUint(val, 10, 64)
+		case "Queries per second avg":
+			p.QueriesPerSecondAvg, err = strconv.ParseFloat(val, 64)
+		}
+		if err != nil {
+			return nil, ErrPacketData
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Statistics) Dump(capabilities flag.Capability) ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Statistics) String() string {
+	return fmt.Sprintf("Uptime: %d  Threads: %d  Questions: %d  Slow queries: %d  Opens: %d  "+
+		"Flush tables: %d  Open tables: %d  Queries per second avg: %.3f",
+		p.Uptime, p.Threads, p.Questions, p.SlowQueries, p.Opens,
+		p.FlushTables, p.OpenTables, p.QueriesPerSecondAvg)
+}
@@ -1,5 +1,7 @@
 package packet
 
+import "testing"
+
 //func TestParseColumnCount(t *testing.T) {
 //	data := []byte{0x01, 0x00, 0x00, 0x01, 0x01}
 //	columnCount, err := ParseQueryResponse(data)
@@ -52,3 +54,20 @@ package packet
 //	str := strings.Join(arr, ",")
 //	t.Log(str)
 //}
+
+func TestParseStatistics(t *testing.T) {
+	data := "Uptime: 1024  Threads: 2  Questions: 36  Slow queries: 0  Opens: 117  " +
+		"Flush tables: 3  Open tables: 36  Queries per second avg: 0.035"
+
+	p, err := ParseStatistics([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Uptime != 1024 || p.Threads != 2 || p.Questions != 36 || p.Opens != 117 ||
+		p.FlushTables != 3 || p.OpenTables != 36 || p.QueriesPerSecondAvg != 0.035 {
+		t.Fatalf("ParseStatistics() = %+v", p)
+	}
+	if p.String() != data {
+		t.Fatalf("String() = %s", p.String())
+	}
+}
@@ -76,6 +76,37 @@ func (lengthEncodedInteger) Get(r io.Reader) (uint64, error) {
 	return binary.LittleEndian.Uint64(byteAlignment(bs, 8)), nil
 }
 
+// Decode decodes integer at the beginning of bs without allocating,
+// and return the value and the number of bytes consumed.
+func (lengthEncodedInteger) Decode(bs []byte) (uint64, int, error) {
+	if len(bs) == 0 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+
+	var size int
+	switch val := bs[0]; {
+	case val < 0xfb:
+		return uint64(val), 1, nil
+	case val == 0xfc:
+		size = 2
+	case val == 0xfd:
+		size = 3
+	case val == 0xfe:
+		size = 8
+	default:
+		return 0, 0, ErrPacketData
+	}
+	if len(bs) < 1+size {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+
+	var v uint64
+	for i := size; i > 0; i-- {
+		v = v<<8 | uint64(bs[i])
+	}
+	return v, 1 + size, nil
+}
+
 func (lengthEncodedInteger) Dump(v uint64) []byte {
 	switch {
 	case v < 251:
@@ -0,0 +1,318 @@
+// Package proxy provides a server.Handler that forwards commands of sessions to upstream MySQL server.
+//
+// Clients are authenticated by UserProvider of server, then every session opens its own
+// upstream connection by Connector when it sends the first command.
+package proxy
+
+import (
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"github.com/vczyh/mysql-protocol/server"
+	"io"
+	"sync"
+)
+
+// Connector opens upstream connection of session.
+type Connector func(session *server.Session) (*client.Conn, error)
+
+// Upstream return Connector creating connection by opts,
+// the connection uses current database of session.
+func Upstream(opts ...client.Option) Connector {
+	return func(session *server.Session) (*client.Conn, error) {
+		conn, err := client.CreateConnection(opts...)
+		if err != nil {
+			return nil, err
+		}
+		if database := session.Database(); database != "" {
+			if err := conn.InitDB(database); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		return conn, nil
+	}
+}
+
+// QueryHook is called before query or prepared statement is sent to upstream.
+// It return query to send, or error to reject the query, the error is sent to client.
+type QueryHook func(session *server.Session, query string) (string, error)
+
+// ResultHook inspects or rewrites result of query and prepared statement, result is
+// *mysql.Result or server.RowIterator, and the returned result is sent to client.
+//
+// Result sets are relayed without decoding rows unless ResultHook is set.
+// With ResultHook, only the first result of multiple statements is passed to hooks,
+// the others are discarded.
+type ResultHook func(session *server.Session, query string, result interface{}) (interface{}, error)
+
+// Handler forwards queries, prepared statements, COM_PING and COM_INIT_DB to upstream,
+// ERR packets of upstream are sent to client as they are.
+// Upstream connection is closed when session is closed, and reopened after COM_CHANGE_USER
+// or COM_RESET_CONNECTION.
+type Handler struct {
+	server.DefaultHandler
+
+	connect     Connector
+	queryHooks  []QueryHook
+	resultHooks []ResultHook
+
+	mu        sync.Mutex
+	upstreams map[uint32]*upstream
+}
+
+type upstream struct {
+	conn *client.Conn
+	// statements are shared by statements of session with the same query
+	stmts map[string]*upstreamStmt
+	// result set read by ResultHook, it must be read off before next command
+	pending *client.Rows
+}
+
+type upstreamStmt struct {
+	stmt *client.Stmt
+	refs int
+}
+
+func NewHandler(connect Connector, opts ...Option) *Handler {
+	h := &Handler{
+		connect:   connect,
+		upstreams: make(map[uint32]*upstream),
+	}
+	for _, opt := range opts {
+		opt.apply(h)
+	}
+	return h
+}
+
+// upstream return upstream of session, it's opened if session doesn't have one.
+func (h *Handler) upstream(session *server.Session) (*upstream, error) {
+	h.mu.Lock()
+	u, ok := h.upstreams[session.ConnectionId()]
+	h.mu.Unlock()
+	if ok {
+		return u, u.drain()
+	}
+
+	conn, err := h.connect(session)
+	if err != nil {
+		return nil, err
+	}
+	u = &upstream{conn: conn, stmts: make(map[string]*upstreamStmt)}
+	h.mu.Lock()
+	h.upstreams[session.ConnectionId()] = u
+	h.mu.Unlock()
+	return u, nil
+}
+
+func (h *Handler) closeUpstream(connId uint32) {
+	h.mu.Lock()
+	u, ok := h.upstreams[connId]
+	delete(h.upstreams, connId)
+	h.mu.Unlock()
+	if ok {
+		u.conn.Close()
+	}
+}
+
+// drain reads off result set left by ResultHook.
+func (u *upstream) drain() error {
+	rows := u.pending
+	if rows == nil {
+		return nil
+	}
+	u.pending = nil
+	for {
+		if err := rows.NextResultSet(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (h *Handler) rewrite(session *server.Session, query string) (string, error) {
+	for _, hook := range h.queryHooks {
+		var err error
+		if query, err = hook(session, query); err != nil {
+			return "", err
+		}
+	}
+	return query, nil
+}
+
+// result passes result of rows to ResultHook.
+func (h *Handler) result(session *server.Session, u *upstream, query string, rows *client.Rows) (interface{}, error) {
+	u.pending = rows
+	var result interface{}
+	if len(rows.Columns()) == 0 {
+		result = &mysql.Result{
+			AffectedRows: u.conn.AffectedRows(),
+			LastInsertId: u.conn.LastInsertId(),
+			Status:       u.conn.Status() &^ flag.ServerMoreResultsExists,
+		}
+	} else {
+		result = server.NewRowIterator(rows.Columns(), rows.Next)
+	}
+
+	for _, hook := range h.resultHooks {
+		var err error
+		if result, err = hook(session, query, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (h *Handler) Ping(session *server.Session) error {
+	u, err := h.upstream(session)
+	if err != nil {
+		return err
+	}
+	return u.conn.Ping()
+}
+
+func (h *Handler) Query(session *server.Session, query string) (interface{}, error) {
+	query, err := h.rewrite(session, query)
+	if err != nil {
+		return nil, err
+	}
+	u, err := h.upstream(session)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(h.resultHooks) > 0 {
+		rows, err := u.conn.Query(query)
+		if err != nil {
+			return nil, err
+		}
+		return h.result(session, u, query, rows)
+	}
+
+	// query is sent in character set of client without decoding
+	if err := u.conn.WriteCommandPacket(packet.NewCmd(packet.ComQuery, []byte(query))); err != nil {
+		return nil, err
+	}
+	return &relay{conn: u.conn}, nil
+}
+
+// InitDB changes database of upstream, current database is changed only if upstream succeeds.
+func (h *Handler) InitDB(session *server.Session, database string) error {
+	u, err := h.upstream(session)
+	if err != nil {
+		return err
+	}
+	return u.conn.InitDB(database)
+}
+
+// Prepare prepares statement on upstream, parameter count and columns of upstream are returned.
+func (h *Handler) Prepare(session *server.Session, query string) (int, []mysql.Column, error) {
+	upstreamQuery, err := h.rewrite(session, query)
+	if err != nil {
+		return 0, nil, err
+	}
+	u, err := h.upstream(session)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	s, ok := u.stmts[query]
+	if !ok {
+		stmt, err := u.conn.Prepare(upstreamQuery)
+		if err != nil {
+			return 0, nil, err
+		}
+		s = &upstreamStmt{stmt: stmt}
+		u.stmts[query] = s
+	}
+	s.refs++
+	return s.stmt.ParamCount(), s.stmt.Columns(), nil
+}
+
+func (h *Handler) Execute(session *server.Session, stmt *server.Stmt, args []interface{}) (interface{}, error) {
+	u, err := h.upstream(session)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := u.stmts[stmt.Query]
+	if !ok {
+		// upstream is reopened after statement is prepared
+		return nil, myerrors.UnknownStmtHandler.Build(stmt.Id, "mysqld_stmt_execute")
+	}
+
+	if len(h.resultHooks) > 0 {
+		rows, err := s.stmt.Query(args...)
+		if err != nil {
+			return nil, err
+		}
+		return h.result(session, u, stmt.Query, rows)
+	}
+
+	if err := s.stmt.WriteExecute(args...); err != nil {
+		return nil, err
+	}
+	return &relay{conn: u.conn}, nil
+}
+
+// CloseStmt closes upstream statement when no statement of session uses it.
+func (h *Handler) CloseStmt(session *server.Session, stmt *server.Stmt) {
+	h.mu.Lock()
+	u, ok := h.upstreams[session.ConnectionId()]
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+	s, ok := u.stmts[stmt.Query]
+	if !ok {
+		return
+	}
+	if s.refs--; s.refs == 0 {
+		delete(u.stmts, stmt.Query)
+		if err := u.drain(); err == nil {
+			s.stmt.Close()
+		}
+	}
+}
+
+// ChangeUser closes upstream, a new one is opened for the new user by the next command.
+func (h *Handler) ChangeUser(session *server.Session) error {
+	h.closeUpstream(session.ConnectionId())
+	return nil
+}
+
+// ResetConnection closes upstream, so that state of upstream session is reset too.
+func (h *Handler) ResetConnection(session *server.Session) error {
+	h.closeUpstream(session.ConnectionId())
+	return nil
+}
+
+func (h *Handler) OnClose(connId uint32) {
+	h.closeUpstream(connId)
+}
+
+// WithQueryHooks adds hooks called in order before query is sent.
+func WithQueryHooks(hooks ...QueryHook) Option {
+	return optionFun(func(h *Handler) {
+		h.queryHooks = append(h.queryHooks, hooks...)
+	})
+}
+
+// WithResultHooks adds hooks called in order with result of upstream.
+func WithResultHooks(hooks ...ResultHook) Option {
+	return optionFun(func(h *Handler) {
+		h.resultHooks = append(h.resultHooks, hooks...)
+	})
+}
+
+type Option interface {
+	apply(*Handler)
+}
+
+type optionFun func(*Handler)
+
+func (f optionFun) apply(h *Handler) {
+	f(h)
+}
@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"fmt"
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"github.com/vczyh/mysql-protocol/server"
+	"github.com/vczyh/mysql-protocol/server/memengine"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newServer(user string, handler server.Handler) *server.Server {
+	return servertest.NewServer(handler, []*server.CreateUserRequest{{User: user, Host: "%", Method: auth.MySQLNativePassword}})
+}
+
+func pipeDialer(srv *server.Server) client.Option {
+	return client.WithDialer(servertest.Dialer(srv))
+}
+
+func readRows(t *testing.T, rows *client.Rows) [][]string {
+	t.Helper()
+	var res [][]string
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			return res
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var values []string
+		for _, v := range row {
+			if v.IsNull() {
+				values = append(values, "NULL")
+			} else {
+				values = append(values, fmt.Sprintf("%s", v.Value()))
+			}
+		}
+		res = append(res, values)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	upstream := newServer("root", memengine.NewHandler())
+	var queries []string
+	handler := NewHandler(Upstream(pipeDialer(upstream), client.WithUser("root")),
+		WithQueryHooks(func(session *server.Session, query string) (string, error) {
+			if strings.HasPrefix(query, "DROP") {
+				return "", myerrors.NotSupportedYet.Build("DROP through proxy")
+			}
+			queries = append(queries, query)
+			return strings.Replace(query, "secret", "name", 1), nil
+		}))
+	proxy := newServer("app", handler)
+
+	conn, err := client.CreateConnection(pipeDialer(proxy), client.WithUser("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	exec := func(query string) mysql.Result {
+		t.Helper()
+		res, err := conn.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return res
+	}
+
+	exec("CREATE DATABASE test")
+	if err := conn.InitDB("test"); err != nil {
+		t.Fatal(err)
+	}
+	exec("CREATE TABLE t (id INT AUTO_INCREMENT PRIMARY KEY, name VARCHAR(16))")
+	if res := exec("INSERT INTO t (name) VALUES ('a'), ('b')"); res.AffectedRows != 2 || res.LastInsertId != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if err := conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		rows, err := conn.Query("SELECT id, secret FROM t ORDER BY id DESC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := readRows(t, rows); !reflect.DeepEqual(res, [][]string{{"2", "b"}, {"1", "a"}}) {
+			t.Fatalf("unexpected rows: %v", res)
+		}
+		if columns := rows.Columns(); columns[1].Name != "name" || columns[1].Table != "t" {
+			t.Fatalf("unexpected columns: %v", columns)
+		}
+		if queries[len(queries)-1] != "SELECT id, secret FROM t ORDER BY id DESC" {
+			t.Fatalf("unexpected hooked query: %s", queries[len(queries)-1])
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := conn.Exec("SELECT * FROM missing")
+		errPkt, ok := err.(*packet.ERR)
+		if !ok || errPkt.ErrorCode != code.ErrNoSuchTable || errPkt.SqlState != "42S02" ||
+			errPkt.ErrorMessage != "Table 'test.missing' doesn't exist" {
+			t.Fatalf("expected upstream error, got %v", err)
+		}
+		_, err = conn.Exec("DROP TABLE t")
+		if errPkt, ok := err.(*packet.ERR); !ok || errPkt.ErrorCode != code.ErrNotSupportedYet {
+			t.Fatalf("expected query rejected by hook, got %v", err)
+		}
+		if err := conn.InitDB("unknown"); err == nil {
+			t.Fatal("expected unknown database error")
+		}
+	})
+
+	t.Run("Stmt", func(t *testing.T) {
+		stmt, err := conn.Prepare("SELECT name FROM t WHERE id = ?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		for i, name := range []string{"a", "b"} {
+			rows, err := stmt.Query(i + 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res := readRows(t, rows); len(res) != 1 || res[0][0] != name {
+				t.Fatalf("unexpected rows: %v", res)
+			}
+		}
+
+		insert, err := conn.Prepare("INSERT INTO t (name) VALUES (?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer insert.Close()
+		if res, err := insert.Exec("c"); err != nil || res.LastInsertId != 3 {
+			t.Fatalf("unexpected result: %+v, %v", res, err)
+		}
+		if _, err := insert.Exec(nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ResultHook", func(t *testing.T) {
+		handler := NewHandler(Upstream(pipeDialer(upstream), client.WithUser("root")),
+			WithResultHooks(func(session *server.Session, query string, result interface{}) (interface{}, error) {
+				rows, ok := result.(server.RowIterator)
+				if !ok {
+					return result, nil
+				}
+				return server.NewRowIterator(rows.Columns(), func() (mysql.Row, error) {
+					row, err := rows.Next()
+					if err != nil {
+						return nil, err
+					}
+					row[0] = mysql.NewColumnValue("masked")
+					return row, nil
+				}), nil
+			}))
+		conn, err := client.CreateConnection(pipeDialer(newServer("app", handler)), client.WithUser("app"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if err := conn.InitDB("test"); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := conn.Query("SELECT name, id FROM t ORDER BY id LIMIT 2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := readRows(t, rows); !reflect.DeepEqual(res, [][]string{{"masked", "1"}, {"masked", "2"}}) {
+			t.Fatalf("unexpected rows: %v", res)
+		}
+		if res, err := conn.Exec("UPDATE t SET name = 'x' WHERE id < 3"); err != nil || res.AffectedRows != 2 {
+			t.Fatalf("unexpected result: %+v, %v", res, err)
+		}
+	})
+}
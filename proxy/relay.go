@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"errors"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/packet"
+	"github.com/vczyh/mysql-protocol/server"
+)
+
+var (
+	ErrLocalInfile = errors.New("proxy: LOCAL INFILE request is not supported")
+)
+
+// relay writes response of upstream to session packet by packet, column definitions
+// and rows are not decoded.
+type relay struct {
+	conn *client.Conn
+}
+
+func (r *relay) WriteResponse(session *server.Session) error {
+	for {
+		status, err := r.writeResult(session)
+		if err != nil {
+			return err
+		}
+		session.SetStatus(status &^ flag.ServerMoreResultsExists)
+		if status&flag.ServerMoreResultsExists == 0 {
+			return nil
+		}
+	}
+}
+
+// writeResult relays an OK, ERR or result set, it return status flags of upstream.
+func (r *relay) writeResult(session *server.Session) (flag.Status, error) {
+	conn := session.Conn()
+	data, err := r.read()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case packet.IsErr(data):
+		return session.Status(), conn.WritePacket(packet.NewSimple(data))
+
+	case packet.IsOK(data):
+		// OK packet is encoded again because its format depends on capabilities of client
+		okPkt, err := packet.ParseOk(data, r.conn.Capabilities())
+		if err != nil {
+			return 0, err
+		}
+		return okPkt.StatusFlags, conn.WritePacket(okPkt)
+
+	case packet.IsLocalInfileRequest(data):
+		return 0, ErrLocalInfile
+	}
+
+	// column count, column definitions and EOF
+	columnCount, err := packet.ParseColumnCount(data)
+	if err != nil {
+		return 0, err
+	}
+	if err := conn.WritePacket(packet.NewSimple(data)); err != nil {
+		return 0, err
+	}
+	for i := uint64(0); i <= columnCount; i++ {
+		if data, err = r.read(); err != nil {
+			return 0, err
+		}
+		if err := conn.WritePacket(packet.NewSimple(data)); err != nil {
+			return 0, err
+		}
+	}
+
+	// rows are terminated by EOF or ERR
+	for {
+		if data, err = r.read(); err != nil {
+			return 0, err
+		}
+		if err := conn.WritePacket(packet.NewSimple(data)); err != nil {
+			return 0, err
+		}
+		switch {
+		case packet.IsErr(data):
+			return session.Status(), nil
+		case packet.IsEOF(data):
+			eofPkt, err := packet.ParseEOF(data, r.conn.Capabilities())
+			if err != nil {
+				return 0, err
+			}
+			return eofPkt.StatusFlags, nil
+		}
+	}
+}
+
+func (r *relay) read() ([]byte, error) {
+	data, err := r.conn.ReadPacket()
+	if err == nil && len(data) == 0 {
+		err = packet.ErrPacketData
+	}
+	return data, err
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	pauth "github.com/pingcap/parser/auth"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"strconv"
+	"time"
+)
+
+// AccountManager is optionally implemented by UserProvider to lock accounts, expire passwords and
+// lock accounts temporarily after consecutive failed logins, like ACCOUNT LOCK, PASSWORD EXPIRE,
+// FAILED_LOGIN_ATTEMPTS and PASSWORD_LOCK_TIME options of CREATE USER.
+type AccountManager interface {
+	// AccountStatus return status of account after password is verified.
+	AccountStatus(key string) (*AccountStatus, error)
+
+	// LoginFailed records a failed login of account and return status after it,
+	// account should be blocked if it has too many consecutive failed logins.
+	LoginFailed(key string) (*AccountStatus, error)
+
+	// LoginSucceeded resets consecutive failed logins of account.
+	LoginSucceeded(key string) error
+
+	// ChangePassword changes password of account and makes password not expired,
+	// it's called by ALTER USER and SET PASSWORD of session in sandbox mode.
+	ChangePassword(key string, password []byte) error
+}
+
+// AccountStatus is status of account when client connects.
+type AccountStatus struct {
+	// Locked is true if account is locked, client gets error 3118.
+	Locked bool
+
+	// PasswordExpired is true if password is expired, client can only change password in sandbox mode,
+	// or gets error 1862 if it can't handle expired password.
+	PasswordExpired bool
+
+	// Blocked is true if account is locked temporarily by consecutive failed logins, client gets error 3955.
+	Blocked bool
+
+	// FailedLoginAttempts is number of consecutive failed logins blocking account.
+	FailedLoginAttempts int
+
+	// PasswordLockTime is how long account is blocked, negative means account is blocked until it's unlocked.
+	PasswordLockTime time.Duration
+
+	// Remaining is how long blocked account remains blocked.
+	Remaining time.Duration
+}
+
+// checkAccount checks status of account whose password is verified,
+// it return whether password is expired.
+func (s *Server) checkAccount(conn mysql.Conn, key, user, host string) (bool, error) {
+	am, ok := s.config.UserProvider.(AccountManager)
+	if !ok {
+		return false, nil
+	}
+	status, err := am.AccountStatus(key)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case status.Blocked:
+		return false, blockedError(user, host, status)
+	case status.Locked:
+		return false, myerrors.AccountHasBeenLocked.Build(user, host)
+	}
+	if err := am.LoginSucceeded(key); err != nil {
+		return false, err
+	}
+
+	if status.PasswordExpired && conn.Capabilities()&flag.ClientCanHandleExpiredPasswords == 0 {
+		return false, myerrors.MustChangePasswordLogin.Build()
+	}
+	return status.PasswordExpired, nil
+}
+
+// loginFailed records failed login of account, it return error 3955 if account is blocked.
+func (s *Server) loginFailed(key, user, host string) error {
+	am, ok := s.config.UserProvider.(AccountManager)
+	if !ok {
+		return nil
+	}
+	status, err := am.LoginFailed(key)
+	if err != nil {
+		return err
+	}
+	if status.Blocked {
+		return blockedError(user, host, status)
+	}
+	return nil
+}
+
+func blockedError(user, host string, status *AccountStatus) error {
+	days := func(d time.Duration) string {
+		if d < 0 {
+			return "unlimited"
+		}
+		// round up, like MySQL
+		return strconv.FormatInt(int64((d+24*time.Hour-1)/(24*time.Hour)), 10)
+	}
+	remaining := status.Remaining
+	if status.PasswordLockTime < 0 {
+		remaining = -1
+	}
+	return myerrors.UserAccountBlocked.Build(user, host, days(status.PasswordLockTime), days(remaining),
+		status.FailedLoginAttempts)
+}
+
+// sandboxCommand reports whether command can be performed by session whose password is expired.
+func sandboxCommand(data []byte) bool {
+	return packet.IsPing(data) || packet.IsQuit(data) || packet.IsQuery(data)
+}
+
+// handleSandboxQuery performs query of session whose password is expired, like MySQL sandbox mode.
+// ALTER USER and SET PASSWORD changing password of current account are performed by AccountManager,
+// SET statements are performed by Handler, and other statements get error 1820.
+// It return false if query should be performed by Handler.
+func (s *Server) handleSandboxQuery(session *Session, query string) (bool, error) {
+	stmtNodes, _, err := parser.New().Parse(query, "", "")
+	if err != nil {
+		return true, session.writeError(myerrors.ParseError.Build(err.Error()))
+	}
+
+	if len(stmtNodes) == 1 {
+		if password, ok := ownPassword(session, stmtNodes[0]); ok {
+			am := s.config.UserProvider.(AccountManager)
+			if err := am.ChangePassword(session.Key(), []byte(password)); err != nil {
+				return true, session.writeError(err)
+			}
+			s.config.SHA2Cache.Delete(session.Key())
+			session.setPasswordExpired(false)
+			return true, session.writeOK(&mysql.Result{})
+		}
+	}
+
+	for _, stmtNode := range stmtNodes {
+		if _, ok := stmtNode.(*ast.SetStmt); !ok {
+			return true, session.writeError(myerrors.MustChangePassword.Build())
+		}
+	}
+	return false, nil
+}
+
+// ownPassword return new password if statement changes password of current account,
+// such as ALTER USER USER() IDENTIFIED BY 'password' and SET PASSWORD = 'password'.
+// Account named by user and host is current account if its key is the same as session,
+// which is user@host like memory UserProvider.
+func ownPassword(session *Session, stmtNode ast.StmtNode) (string, bool) {
+	current := func(user *pauth.UserIdentity) bool {
+		return user.CurrentUser || user.Username+"@"+user.Hostname == session.Key()
+	}
+
+	switch v := stmtNode.(type) {
+	case *ast.AlterUserStmt:
+		if v.CurrentAuth != nil {
+			return v.CurrentAuth.AuthString, v.CurrentAuth.ByAuthString
+		}
+		if len(v.Specs) != 1 {
+			return "", false
+		}
+		spec := v.Specs[0]
+		if spec.AuthOpt == nil || !spec.AuthOpt.ByAuthString {
+			return "", false
+		}
+		if current(spec.User) {
+			return spec.AuthOpt.AuthString, true
+		}
+	case *ast.SetPwdStmt:
+		if v.User == nil || current(v.User) {
+			return v.Password, true
+		}
+	}
+	return "", false
+}
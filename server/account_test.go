@@ -0,0 +1,171 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/packet"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func errorCode(err error) code.Err {
+	if errPkt, ok := err.(*packet.ERR); ok {
+		return errPkt.ErrorCode
+	}
+	return 0
+}
+
+func TestAccountManager(t *testing.T) {
+	now := time.Now()
+	var users []*CreateUserRequest
+	for _, r := range []*CreateUserRequest{
+		{User: "locked", AccountLocked: true},
+		{User: "expired", Password: "123456", PasswordExpired: true},
+		{User: "old", PasswordLifetime: 24 * time.Hour, PasswordLastChanged: now.Add(-48 * time.Hour)},
+		{User: "guarded", Password: "123456", FailedLoginAttempts: 2, PasswordLockTime: 24 * time.Hour},
+		{User: "unbounded", Password: "123456", FailedLoginAttempts: 1, PasswordLockTime: -1},
+	} {
+		r.Host, r.Method = "%", auth.MySQLNativePassword
+		users = append(users, r)
+	}
+	userProvider := newUserProvider(t, users...)
+	var mu sync.Mutex
+	userProvider.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+	srv := newTestServer(userProvider, NewDefaultHandler())
+
+	connect := func(user, password string, opts ...client.Option) (*client.Conn, error) {
+		opts = append(opts, client.WithDialer(pipeDialer(srv)), client.WithUser(user), client.WithPassword(password))
+		return client.CreateConnection(opts...)
+	}
+	expect := func(user, password string, expected code.Err) {
+		t.Helper()
+		conn, err := connect(user, password)
+		if expected == 0 {
+			if err != nil {
+				t.Fatalf("%s: %v", user, err)
+			}
+			conn.Close()
+			return
+		}
+		if errorCode(err) != expected {
+			t.Fatalf("%s: expected error %d, got %v", user, expected, err)
+		}
+	}
+
+	t.Run("lock", func(t *testing.T) {
+		expect("locked", "", code.ErrAccountHasBeenLocked)
+		if err := userProvider.Unlock("locked", "%"); err != nil {
+			t.Fatal(err)
+		}
+		expect("locked", "", 0)
+		if err := userProvider.Lock("locked", "%"); err != nil {
+			t.Fatal(err)
+		}
+		expect("locked", "", code.ErrAccountHasBeenLocked)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expect("old", "", code.ErrExpiredPasswordLogin)
+		expect("expired", "123456", code.ErrExpiredPasswordLogin)
+
+		conn, err := connect("expired", "123456", client.WithAllowExpiredPasswords(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := conn.Exec("SELECT 1"); errorCode(err) != code.ErrMustChangePassword {
+			t.Fatalf("expected error %d, got %v", code.ErrMustChangePassword, err)
+		}
+		if err := conn.InitDB("db"); errorCode(err) != code.ErrMustChangePassword {
+			t.Fatalf("expected error %d, got %v", code.ErrMustChangePassword, err)
+		}
+		if err := conn.Ping(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec("SET NAMES utf8mb4"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec("ALTER USER USER() IDENTIFIED BY '654321'"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec("SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+
+		expect("expired", "123456", code.ErrAccessDeniedError)
+		expect("expired", "654321", 0)
+		// SET PASSWORD also leaves sandbox mode
+		if err := userProvider.ExpirePassword("expired", "%"); err != nil {
+			t.Fatal(err)
+		}
+		conn, err = connect("expired", "654321", client.WithAllowExpiredPasswords(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := conn.Exec("SET PASSWORD = '123456'"); err != nil {
+			t.Fatal(err)
+		}
+		expect("expired", "123456", 0)
+
+		// only password of current account can be changed, the same user of another host is another account
+		if err := userProvider.ExpirePassword("expired", "%"); err != nil {
+			t.Fatal(err)
+		}
+		conn, err = connect("expired", "123456", client.WithAllowExpiredPasswords(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		for _, query := range []string{
+			"ALTER USER 'expired'@'localhost' IDENTIFIED BY '654321'",
+			"SET PASSWORD FOR 'expired'@'10.%' = '654321'",
+			"ALTER USER 'old' IDENTIFIED BY '654321'",
+		} {
+			if _, err := conn.Exec(query); errorCode(err) != code.ErrMustChangePassword {
+				t.Fatalf("%s: expected error %d, got %v", query, code.ErrMustChangePassword, err)
+			}
+		}
+		if _, err := conn.Exec("SET PASSWORD FOR 'expired'@'%' = '654321'"); err != nil {
+			t.Fatal(err)
+		}
+		expect("expired", "654321", 0)
+	})
+
+	t.Run("failed logins", func(t *testing.T) {
+		expect("guarded", "wrong", code.ErrAccessDeniedError)
+		expect("guarded", "123456", 0)
+		expect("guarded", "wrong", code.ErrAccessDeniedError)
+		_, err := connect("guarded", "wrong")
+		if errorCode(err) != code.ErrUserAccountBlocked ||
+			!strings.Contains(err.Error(), "blocked for 1 day(s) (1 day(s) remaining) due to 2 consecutive failed logins") {
+			t.Fatalf("expected error %d, got %v", code.ErrUserAccountBlocked, err)
+		}
+		expect("guarded", "123456", code.ErrUserAccountBlocked)
+		advance(25 * time.Hour)
+		expect("guarded", "123456", 0)
+
+		_, err = connect("unbounded", "wrong")
+		if errorCode(err) != code.ErrUserAccountBlocked || !strings.Contains(err.Error(), "unlimited day(s) remaining") {
+			t.Fatalf("expected error %d, got %v", code.ErrUserAccountBlocked, err)
+		}
+		advance(1000 * time.Hour)
+		expect("unbounded", "123456", code.ErrUserAccountBlocked)
+		if err := userProvider.Unlock("unbounded", "%"); err != nil {
+			t.Fatal(err)
+		}
+		expect("unbounded", "123456", 0)
+	})
+}
@@ -4,11 +4,8 @@ import (
 	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha1"
 	"crypto/x509"
-	"encoding/hex"
 	"encoding/pem"
-	"errors"
 	"fmt"
 	"github.com/vczyh/mysql-protocol/auth"
 	"github.com/vczyh/mysql-protocol/charset"
@@ -23,7 +20,7 @@ import (
 )
 
 var (
-	ErrPrivateKeyNotFond = errors.New("auth: private key not found")
+	ErrPrivateKeyNotFond = auth.ErrPrivateKeyNotFound
 )
 
 const (
@@ -31,77 +28,121 @@ const (
 	PublicKeyName  = "public_key.pem"
 )
 
-func (s *Server) auth(conn mysql.Conn) error {
+// auth performs connection phase and return authenticated session.
+func (s *Server) auth(conn mysql.Conn) (*Session, error) {
 	hs, err := s.writeHandshakePacket(conn)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	hsr, err := s.handleTLSAndHandshakeResponsePacket(conn)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var database string
+	if conn.Capabilities()&flag.ClientConnectWithDB != 0 {
+		database = hsr.GetDatabase()
 	}
 
-	authData := hs.GetAuthData()
 	user := hsr.GetUsername()
-	authRes := hsr.AuthRes
+	host := clientHost(conn)
+	key, expired, err := s.authenticate(conn, user, host, database, hsr.AuthPlugin, hsr.AuthRes, hs.GetAuthData())
+	if err != nil {
+		return nil, err
+	}
 
-	var host string
-	switch v := conn.RemoteAddr().(type) {
-	case *net.TCPAddr:
-		host = v.IP.String()
+	session := newSession(conn, s.config.Variables)
+	session.salt = hs.GetAuthData()
+	session.key = key
+	session.user = user
+	session.host = host
+	session.database = database
+	session.passwordExpired = expired
+	session.collation = hsr.CharacterSet
+	for _, attr := range hsr.Attributes {
+		session.attrs[attr.Key] = attr.Val
 	}
+	return session, nil
+}
+
+// authenticate verifies authRes computed by client plugin with authData, and switches to
+// the authentication method of matched account if client plugin is different.
+// It return whether password is expired, and it's shared by connection phase and COM_CHANGE_USER.
+func (s *Server) authenticate(conn mysql.Conn, user, host, database string,
+	clientPlugin auth.Method, authRes, authData []byte) (string, bool, error) {
 
 	errAccessDenied := myerrors.AccessDenied.Build(user, host, "YES")
 
 	key, err := s.config.UserProvider.Key(user, host)
 	if err != nil {
 		if err == ErrAccessDenied {
-			return errAccessDenied
+			return "", false, errAccessDenied
 		}
-		return err
+		return "", false, err
 	}
 
 	method, err := s.config.UserProvider.AuthenticationMethod(key)
 	if err != nil {
 		if err == ErrAccessDenied {
-			return errAccessDenied
+			return "", false, errAccessDenied
 		}
-		return err
+		return "", false, err
 	}
 
-	if hsr.AuthPlugin != method {
+	if clientPlugin != method {
 		authData, err = s.writeAuthSwitchRequestPacket(conn, method)
 		if err != nil {
-			return err
+			return "", false, err
 		}
 		authRes, err = s.handleAuthSwitchResponsePacket(conn)
 		if err != nil {
-			return err
+			return "", false, err
 		}
 	}
 
-	if err := s.authentication(conn, method, key, authRes, authData, errAccessDenied); err != nil {
-		return err
+	if err := s.authentication(conn, method, key, user, host, authRes, authData, errAccessDenied); err != nil {
+		if err == errAccessDenied {
+			if blockedErr := s.loginFailed(key, user, host); blockedErr != nil {
+				return "", false, blockedErr
+			}
+		}
+		return "", false, err
+	}
+
+	expired, err := s.checkAccount(conn, key, user, host)
+	if err != nil {
+		return "", false, err
 	}
 
 	err = s.config.UserProvider.Authorization(key, &AuthorizationRequest{
-		Database: func() string {
-			if conn.Capabilities()&flag.ClientConnectWithDB != 0 {
-				return hsr.GetDatabase()
-			}
-			return ""
-		}(),
-		TLSed: conn.TLSed(),
+		Database: database,
+		TLSed:    conn.TLSed(),
 	})
 	if err != nil {
 		if err == ErrAccessDenied {
-			return errAccessDenied
+			return "", false, errAccessDenied
 		}
-		return err
+		return "", false, err
 	}
 
-	return nil
+	if database != "" {
+		if err := s.checkPrivileges(key, user, host, &privilegeRequest{database: database}); err != nil {
+			return "", false, err
+		}
+	}
+	return key, expired, nil
+}
+
+// clientHost return host used to match account, it's localhost for Unix socket.
+func clientHost(conn mysql.Conn) string {
+	if mysql.IsUnixSocket(conn) {
+		return "localhost"
+	}
+	if v, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return v.IP.String()
+	}
+	return ""
 }
 
 func (s *Server) writeAuthSwitchRequestPacket(conn mysql.Conn, method auth.Method) ([]byte, error) {
@@ -117,178 +158,87 @@ func (s *Server) handleAuthSwitchResponsePacket(conn mysql.Conn) ([]byte, error)
 	return packet.ParseAuthSwitchResponse(data)
 }
 
-func (s *Server) authentication(conn mysql.Conn, method auth.Method, key string,
+func (s *Server) authentication(conn mysql.Conn, method auth.Method, key, user, host string,
 	authRes, salt []byte, errAccessDenied error) error {
 
-	switch method {
-	case auth.MySQLNativePassword:
-		as, err := s.config.UserProvider.AuthenticationString(key)
-		if err != nil {
-			if err == ErrAccessDenied {
-				return errAccessDenied
-			}
-			return err
-		}
-		if len(as) != 41 {
-			return ErrInvalidAuthenticationStringFormat
-		}
-		challengeData, err := hex.DecodeString(string(bytes.ToLower(as[1:])))
-		if err != nil {
-			return err
-		}
-
-		if err := method.ChallengeResponse(challengeData, authRes, salt); err != nil {
-			if err == auth.ErrMismatch {
-				return errAccessDenied
-			}
-			return err
-		}
-
-	case auth.SHA256Password:
-		as, err := s.config.UserProvider.AuthenticationString(key)
-		if err != nil {
-			if err == ErrAccessDenied {
-				return errAccessDenied
-			}
-			return err
-		}
-
-		password, err := s.sha256Password(conn, authRes, salt)
-		if err != nil {
-			return err
-		}
-
-		if err := method.ReAscertainPassword(as, password); err != nil {
-			if err == auth.ErrMismatch {
-				return errAccessDenied
-			}
-			return err
-		}
-
-	// https://dev.mysql.com/blog-archive/preparing-your-community-connector-for-mysql-8-part-2-sha256/
-	// https://dev.mysql.com/doc/dev/mysql-server/latest/page_caching_sha2_authentication_exchanges.html
-	case auth.CachingSha2Password:
-		challengeData := s.config.SHA2Cache.Get(key)
-		if challengeData != nil {
-			// fast authentication
-			if err := conn.WritePacket(packet.NewAuthMoreData([]byte{0x03})); err != nil {
-				return err
-			}
-
-			if err := method.ChallengeResponse(challengeData, authRes, salt); err != nil {
-				if err == auth.ErrMismatch {
-					return conn.WriteError(errAccessDenied)
-				}
-				return err
-			}
-		} else {
-			// full authentication
-			if err := conn.WritePacket(packet.NewAuthMoreData([]byte{0x04})); err != nil {
-				return err
-			}
-
-			password, err := s.cachingSHA2Password(conn, salt)
-			if err != nil {
-				return err
-			}
-			as, err := s.config.UserProvider.AuthenticationString(key)
-			if err != nil {
-				return err
-			}
-			if err := method.ReAscertainPassword(as, password); err != nil {
-				if err == auth.ErrMismatch {
-					return errAccessDenied
-				}
-				return err
-			}
-
-			challengeData, err := method.GenerateChallengeData(password)
-			if err != nil {
-				return err
-			}
-			s.config.SHA2Cache.Put(key, challengeData)
-		}
-
-	default:
-		return auth.ErrUnsupportedAuthenticationMethod
+	plugin, err := method.Plugin()
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
-
-func (s *Server) writePublicKeyPacket(conn mysql.Conn, publicKeyBytes []byte) error {
-	if len(publicKeyBytes) == 0 {
+	err = plugin.Authenticate(&authConn{
+		Conn:   conn,
+		s:      s,
+		key:    key,
+		user:   user,
+		host:   host,
+		method: method,
+	}, authRes, salt)
+	switch err {
+	case nil:
+		return nil
+	case auth.ErrMismatch, ErrAccessDenied:
+		return errAccessDenied
+	case auth.ErrPublicKeyNotFound:
 		return myerrors.NewServer(code.ErrSendToClient, "public key not setting")
+	default:
+		return err
 	}
-	return conn.WritePacket(packet.NewAuthMoreData(publicKeyBytes))
 }
 
-func (s *Server) sha256Password(conn mysql.Conn, authRes, salt []byte) ([]byte, error) {
-	if conn.TLSed() {
-		if len(authRes) == 0 {
-			return nil, nil
-		}
-		return authRes[:len(authRes)-1], nil
-	}
-
-	if len(authRes) != 1 || authRes[0] != 0x01 {
-		return nil, packet.ErrPacketData
-	}
-	if err := s.writePublicKeyPacket(conn, s.sha256PasswordPublicKeyBytes); err != nil {
-		return nil, err
-	}
-
-	return s.plaintextPassword(conn, s.sha256PasswordPrivateKey, salt)
+// authConn implements auth.ServerConn.
+type authConn struct {
+	mysql.Conn
+	s      *Server
+	key    string
+	user   string
+	host   string
+	method auth.Method
 }
 
-func (s *Server) cachingSHA2Password(conn mysql.Conn, salt []byte) ([]byte, error) {
-	data, err := conn.ReadPacket()
-	if err != nil {
-		return nil, err
-	}
-
-	if conn.TLSed() {
-		authRes := data[4:]
-		if len(authRes) == 0 {
-			return nil, nil
-		}
-		return authRes[:len(authRes)-1], nil
-	} else {
-		if !packet.IsRequestPublicKey(data) {
-			return nil, packet.ErrPacketData
-		}
+func (c *authConn) Key() string {
+	return c.key
+}
 
-		if err := s.writePublicKeyPacket(conn, s.cachingSHA2PasswordPublicKeyBytes); err != nil {
-			return nil, err
-		}
+func (c *authConn) SecureTransport() bool {
+	return mysql.IsSecureTransport(c.Conn)
+}
 
-		return s.plaintextPassword(conn, s.cachingSHA2PasswordPrivateKey, salt)
-	}
+func (c *authConn) WriteMoreData(data []byte) error {
+	return c.WritePacket(packet.NewAuthMoreData(data))
 }
 
-func (s *Server) plaintextPassword(conn mysql.Conn, privateKey *rsa.PrivateKey, salt []byte) ([]byte, error) {
-	data, err := conn.ReadPacket()
-	if err != nil {
-		return nil, err
-	}
+func (c *authConn) AuthenticationString() ([]byte, error) {
+	return c.s.config.UserProvider.AuthenticationString(c.key)
+}
 
-	if s.privateKey == nil {
-		return nil, ErrPrivateKeyNotFond
-	}
-	plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, privateKey, data, nil)
-	if err != nil {
-		return nil, err
+func (c *authConn) RSAKeyPair() (*rsa.PrivateKey, []byte) {
+	switch c.method {
+	case auth.SHA256Password:
+		return c.s.sha256PasswordPrivateKey, c.s.sha256PasswordPublicKeyBytes
+	case auth.CachingSha2Password:
+		return c.s.cachingSHA2PasswordPrivateKey, c.s.cachingSHA2PasswordPublicKeyBytes
+	default:
+		return c.s.privateKey, c.s.publicKeyBytes
 	}
+}
 
-	for i := range plain {
-		j := i % len(salt)
-		plain[i] ^= salt[j]
-	}
-	if len(plain) == 0 {
-		return nil, packet.ErrPacketData
-	}
+func (c *authConn) Cache() auth.Cache {
+	return c.s.config.SHA2Cache
+}
 
-	return plain[:len(plain)-1], nil
+func (c *authConn) VerifyPassword(password []byte) error {
+	if c.s.config.PasswordVerifier == nil {
+		c.s.config.Logger.Warn(fmt.Errorf("%s requires PasswordVerifier, access denied", c.method))
+		return ErrAccessDenied
+	}
+	return c.s.config.PasswordVerifier.Verify(&PasswordVerifyRequest{
+		Key:      c.key,
+		User:     c.user,
+		Host:     c.host,
+		Password: password,
+		TLSed:    c.TLSed(),
+	})
 }
 
 func (s *Server) writeHandshakePacket(conn mysql.Conn) (*packet.Handshake, error) {
@@ -314,10 +264,11 @@ func (s *Server) writeHandshakePacket(conn mysql.Conn) (*packet.Handshake, error
 	switch s.config.DefaultAuthMethod {
 	case auth.MySQLNativePassword:
 		hs.Salt2 = auth.Bytes(13)
-	case auth.CachingSha2Password, auth.SHA256Password:
-		hs.Salt2 = append(auth.Bytes(12), 0x00)
 	default:
-		return nil, auth.ErrUnsupportedAuthenticationMethod
+		if _, err := s.config.DefaultAuthMethod.Plugin(); err != nil {
+			return nil, err
+		}
+		hs.Salt2 = append(auth.Bytes(12), 0x00)
 	}
 
 	return hs, conn.WritePacket(hs)
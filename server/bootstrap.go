@@ -0,0 +1,410 @@
+package server
+
+import (
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/opcode"
+	"github.com/vczyh/mysql-protocol/charset"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"strconv"
+	"strings"
+)
+
+// SystemQuery answers statements sent by connectors and clients when connecting, it returns
+// handled false for other statements, which should be performed by Handler. Supported statements:
+//
+//	SELECT @@version_comment LIMIT 1
+//	SELECT @@session.transaction_isolation, DATABASE(), USER(), CONNECTION_ID(), VERSION()
+//	SET NAMES utf8mb4 [COLLATE utf8mb4_general_ci]
+//	SET autocommit=1, @@session.sql_mode=DEFAULT, GLOBAL wait_timeout=60
+//	SHOW [GLOBAL | SESSION] VARIABLES [LIKE 'pattern' | WHERE expr]
+//
+// Variables are read from and written to the session and SystemVariables of server.
+// DefaultHandler calls it for every query, custom Handler can call it before its own logic.
+func SystemQuery(session *Session, query string) (result interface{}, handled bool, err error) {
+	stmtNode, err := parser.New().ParseOneStmt(query, "", "")
+	if err != nil {
+		return nil, false, nil
+	}
+	return SystemStatement(session, stmtNode)
+}
+
+// SystemStatement is like SystemQuery, but takes statement parsed by Handler.
+func SystemStatement(session *Session, stmtNode ast.StmtNode) (result interface{}, handled bool, err error) {
+	switch v := stmtNode.(type) {
+	case *ast.SelectStmt:
+		return selectSystem(session, v)
+	case *ast.SetStmt:
+		return setSystem(session, v)
+	case *ast.ShowStmt:
+		if v.Tp == ast.ShowVariables {
+			return showVariables(session, v)
+		}
+	}
+	return nil, false, nil
+}
+
+// selectSystem handles SELECT without table whose fields are system variables or session functions.
+func selectSystem(session *Session, stmt *ast.SelectStmt) (interface{}, bool, error) {
+	if stmt.From != nil || stmt.Where != nil || stmt.GroupBy != nil || stmt.Having != nil || stmt.Fields == nil {
+		return nil, false, nil
+	}
+
+	var names []string
+	var values []interface{}
+	for _, field := range stmt.Fields.Fields {
+		if field.WildCard != nil {
+			return nil, false, nil
+		}
+		value, ok, err := evalSystemExpr(session, field.Expr)
+		if !ok || err != nil {
+			return nil, ok, err
+		}
+		name := field.AsName.O
+		if name == "" {
+			name = field.Text()
+		}
+		names = append(names, name)
+		values = append(values, value)
+	}
+
+	rs, err := NewSimpleResultSet(names, [][]interface{}{values})
+	if err != nil {
+		return nil, true, err
+	}
+	if limit := stmt.Limit; limit != nil {
+		count, ok := valueOf(limit.Count)
+		if !ok {
+			return nil, false, nil
+		}
+		offset := interface{}(int64(0))
+		if limit.Offset != nil {
+			if offset, ok = valueOf(limit.Offset); !ok {
+				return nil, false, nil
+			}
+		}
+		if valueString(count) == "0" || valueString(offset) != "0" {
+			rs.rows = nil
+		}
+	}
+	return rs, true, nil
+}
+
+func evalSystemExpr(session *Session, expr ast.ExprNode) (interface{}, bool, error) {
+	switch v := expr.(type) {
+	case *ast.VariableExpr:
+		if !v.IsSystem {
+			return nil, false, nil
+		}
+		value, err := selectSystemVariable(session, v)
+		return value, true, err
+
+	case *ast.FuncCallExpr:
+		if len(v.Args) != 0 {
+			return nil, false, nil
+		}
+		switch v.FnName.L {
+		case "database", "schema":
+			if database := session.Database(); database != "" {
+				return database, true, nil
+			}
+			return nil, true, nil
+		case "user", "session_user", "system_user":
+			return session.User() + "@" + session.Host(), true, nil
+		case "current_user":
+			return session.Key(), true, nil
+		case "connection_id":
+			return int64(session.ConnectionId()), true, nil
+		case "version":
+			version, _ := session.sysVars.Global("version")
+			return version, true, nil
+		}
+
+	case ast.ValueExpr:
+		val, ok := valueOf(v)
+		return val, ok, nil
+	}
+	return nil, false, nil
+}
+
+// selectSystemVariable return value of @@[global.|session.]name, boolean variable is 1 or 0,
+// numeric variable is integer.
+func selectSystemVariable(session *Session, expr *ast.VariableExpr) (interface{}, error) {
+	variable, ok := session.sysVars.Lookup(expr.Name)
+	if !ok {
+		return nil, myerrors.UnknownSystemVariable.Build(expr.Name)
+	}
+
+	var value string
+	switch {
+	case expr.IsGlobal:
+		if variable.Scope&ScopeGlobal == 0 {
+			return nil, myerrors.IncorrectGlobalLocalVar.Build(expr.Name, "SESSION")
+		}
+		value, _ = session.sysVars.Global(expr.Name)
+	case expr.ExplicitScope && variable.Scope&ScopeSession == 0:
+		return nil, myerrors.IncorrectGlobalLocalVar.Build(expr.Name, "GLOBAL")
+	default:
+		value, _ = session.SystemVariable(expr.Name)
+	}
+
+	if variable.Boolean {
+		if value == "ON" {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return n, nil
+	}
+	return value, nil
+}
+
+// setSystem handles SET of system variables and SET NAMES, assignments are applied in order.
+func setSystem(session *Session, stmt *ast.SetStmt) (interface{}, bool, error) {
+	for _, assignment := range stmt.Variables {
+		if assignment.Name != ast.SetNames && !assignment.IsSystem {
+			return nil, false, nil
+		}
+		switch assignment.Value.(type) {
+		case ast.ValueExpr, *ast.DefaultExpr, *ast.ColumnNameExpr, *ast.VariableExpr:
+		default:
+			return nil, false, nil
+		}
+	}
+
+	for _, assignment := range stmt.Variables {
+		if assignment.Name == ast.SetNames {
+			if err := setNames(session, assignment); err != nil {
+				return nil, true, err
+			}
+			continue
+		}
+
+		var value string
+		switch v := assignment.Value.(type) {
+		case *ast.DefaultExpr:
+			variable, ok := session.sysVars.Lookup(assignment.Name)
+			if !ok {
+				return nil, true, myerrors.UnknownSystemVariable.Build(assignment.Name)
+			}
+			if !assignment.IsGlobal {
+				if err := session.resetSystemVariable(variable.Name); err != nil {
+					return nil, true, err
+				}
+				continue
+			}
+			value = variable.Default
+		case *ast.ColumnNameExpr:
+			value = v.Name.Name.O
+		default:
+			val, ok, err := evalSystemExpr(session, v)
+			if !ok || err != nil {
+				return nil, ok, err
+			}
+			value = valueString(val)
+		}
+
+		var err error
+		if assignment.IsGlobal {
+			err = session.sysVars.SetGlobal(assignment.Name, value)
+		} else {
+			err = session.SetSystemVariable(assignment.Name, value)
+		}
+		if err != nil {
+			return nil, true, err
+		}
+	}
+	return &mysql.Result{}, true, nil
+}
+
+// setNames handles SET NAMES charset [COLLATE collation] and SET CHARACTER SET charset.
+func setNames(session *Session, assignment *ast.VariableAssignment) error {
+	name := charset.UTF8MB4
+	if v, ok := assignment.Value.(ast.ValueExpr); ok {
+		name = strings.ToLower(v.GetString())
+	}
+	cs, err := charset.Get(name)
+	if err != nil {
+		return myerrors.UnknownCharacterSet.Build(name)
+	}
+
+	collation := cs.DefaultCollation()
+	if assignment.ExtendValue != nil {
+		collationName := strings.ToLower(assignment.ExtendValue.GetString())
+		if collation, err = charset.GetCollationByName(collationName); err != nil {
+			return myerrors.UnknownCollation.Build(collationName)
+		}
+	}
+	session.setCharset(collation)
+	return nil
+}
+
+// showVariables handles SHOW VARIABLES, WHERE supports AND OR = != LIKE IN on Variable_name and Value.
+func showVariables(session *Session, stmt *ast.ShowStmt) (interface{}, bool, error) {
+	var rows [][]interface{}
+	for _, name := range session.sysVars.Names() {
+		variable, _ := session.sysVars.Lookup(name)
+		var value string
+		if stmt.GlobalScope {
+			if variable.Scope&ScopeGlobal == 0 {
+				continue
+			}
+			value, _ = session.sysVars.Global(name)
+		} else {
+			value, _ = session.SystemVariable(name)
+		}
+
+		if stmt.Pattern != nil {
+			pattern, ok := valueOf(stmt.Pattern.Pattern)
+			if !ok {
+				return nil, false, nil
+			}
+			if LikeMatch(name, valueString(pattern), stmt.Pattern.Escape) == stmt.Pattern.Not {
+				continue
+			}
+		}
+		if stmt.Where != nil {
+			match, ok := evalShowFilter(stmt.Where, name, value)
+			if !ok {
+				return nil, false, nil
+			}
+			if b, _ := match.(bool); !b {
+				continue
+			}
+		}
+		rows = append(rows, []interface{}{name, value})
+	}
+
+	names := []string{"Variable_name", "Value"}
+	if len(rows) == 0 {
+		rs, err := NewSimpleResultSet(names, [][]interface{}{{"", ""}})
+		if err != nil {
+			return nil, true, err
+		}
+		rs.rows = nil
+		return rs, true, nil
+	}
+	rs, err := NewSimpleResultSet(names, rows)
+	return rs, true, err
+}
+
+// evalShowFilter evaluates WHERE of SHOW VARIABLES on a row, it return false if expr isn't supported.
+func evalShowFilter(expr ast.ExprNode, name, value string) (interface{}, bool) {
+	switch v := expr.(type) {
+	case *ast.ParenthesesExpr:
+		return evalShowFilter(v.Expr, name, value)
+
+	case *ast.ColumnNameExpr:
+		switch v.Name.Name.L {
+		case "variable_name":
+			return name, true
+		case "value":
+			return value, true
+		}
+
+	case ast.ValueExpr:
+		val, ok := valueOf(v)
+		return valueString(val), ok
+
+	case *ast.BinaryOperationExpr:
+		l, ok := evalShowFilter(v.L, name, value)
+		if !ok {
+			return nil, false
+		}
+		r, ok := evalShowFilter(v.R, name, value)
+		if !ok {
+			return nil, false
+		}
+		switch v.Op {
+		case opcode.LogicAnd, opcode.LogicOr:
+			lb, lok := l.(bool)
+			rb, rok := r.(bool)
+			if !lok || !rok {
+				return nil, false
+			}
+			if v.Op == opcode.LogicAnd {
+				return lb && rb, true
+			}
+			return lb || rb, true
+		case opcode.EQ, opcode.NE:
+			ls, lok := l.(string)
+			rs, rok := r.(string)
+			if !lok || !rok {
+				return nil, false
+			}
+			return strings.EqualFold(ls, rs) == (v.Op == opcode.EQ), true
+		}
+
+	case *ast.PatternLikeExpr:
+		s, ok := evalShowFilter(v.Expr, name, value)
+		if !ok {
+			return nil, false
+		}
+		pattern, ok := evalShowFilter(v.Pattern, name, value)
+		if !ok {
+			return nil, false
+		}
+		ss, sok := s.(string)
+		ps, pok := pattern.(string)
+		if !sok || !pok {
+			return nil, false
+		}
+		return LikeMatch(ss, ps, v.Escape) != v.Not, true
+
+	case *ast.PatternInExpr:
+		if v.Sel != nil {
+			return nil, false
+		}
+		s, ok := evalShowFilter(v.Expr, name, value)
+		ss, sok := s.(string)
+		if !ok || !sok {
+			return nil, false
+		}
+		for _, item := range v.List {
+			val, ok := evalShowFilter(item, name, value)
+			vs, vok := val.(string)
+			if !ok || !vok {
+				return nil, false
+			}
+			if strings.EqualFold(ss, vs) {
+				return !v.Not, true
+			}
+		}
+		return v.Not, true
+	}
+	return nil, false
+}
+
+// valueOf return value of literal, it only supports integer, float, string and NULL.
+func valueOf(expr ast.ExprNode) (interface{}, bool) {
+	v, ok := expr.(ast.ValueExpr)
+	if !ok {
+		return nil, false
+	}
+	switch val := v.GetValue().(type) {
+	case nil, int64, uint64, float64, string:
+		return val, true
+	case []byte:
+		return string(val), true
+	}
+	return nil, false
+}
+
+func valueString(val interface{}) string {
+	switch v := val.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return ""
+}
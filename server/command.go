@@ -0,0 +1,259 @@
+package server
+
+import (
+	"bytes"
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"sync/atomic"
+	"time"
+)
+
+// The following interfaces are optionally implemented by Handler to customize
+// built-in handling of standard commands.
+// https://dev.mysql.com/doc/internals/en/text-protocol.html
+
+// InitDBCommand is called for COM_INIT_DB before current database is changed,
+// returning error rejects it, such as unknown database.
+type InitDBCommand interface {
+	InitDB(session *Session, database string) error
+}
+
+// FieldListCommand return columns of table for COM_FIELD_LIST, wildcard is column name pattern.
+// Without it, empty column list is sent.
+type FieldListCommand interface {
+	FieldList(session *Session, table, wildcard string) ([]mysql.Column, error)
+}
+
+// ChangeUserCommand is called for COM_CHANGE_USER after the new user is authenticated
+// and session is reset, returning error closes the connection.
+type ChangeUserCommand interface {
+	ChangeUser(session *Session) error
+}
+
+// ResetConnectionCommand is called for COM_RESET_CONNECTION after session is reset.
+type ResetConnectionCommand interface {
+	ResetConnection(session *Session) error
+}
+
+// SetOptionCommand is called for COM_SET_OPTION before the option is applied,
+// returning error rejects it, such as handler doesn't support multi statements.
+type SetOptionCommand interface {
+	SetOption(session *Session, option flag.SetOption) error
+}
+
+// StatisticsCommand return status of server for COM_STATISTICS.
+// Without it or returning nil, statistics of server are sent.
+type StatisticsCommand interface {
+	Statistics(session *Session) (*packet.Statistics, error)
+}
+
+// KillCommand checks whether session can kill the connection by COM_PROCESS_KILL,
+// returning error rejects it. Without it, only connections of the same user can be killed.
+type KillCommand interface {
+	Kill(session *Session, target *Session) error
+}
+
+func (s *Server) handleInitDB(session *Session, data []byte) error {
+	database := string(data[1:])
+	if err := s.checkPrivileges(session.Key(), session.User(), session.Host(),
+		&privilegeRequest{database: database}); err != nil {
+		return session.writeError(err)
+	}
+	if h, ok := s.config.Handler.(InitDBCommand); ok {
+		if err := h.InitDB(session, database); err != nil {
+			return session.writeError(err)
+		}
+	}
+	session.SetDatabase(database)
+	return session.writeOK(&mysql.Result{})
+}
+
+func (s *Server) handleFieldList(session *Session, data []byte) error {
+	var columns []mysql.Column
+	if h, ok := s.config.Handler.(FieldListCommand); ok {
+		table, wildcard := data[1:], []byte(nil)
+		if i := bytes.IndexByte(table, 0x00); i >= 0 {
+			table, wildcard = table[:i], table[i+1:]
+		}
+		if err := s.checkPrivileges(session.Key(), session.User(), session.Host(), &privilegeRequest{
+			privileges: SelectPriv, database: session.Database(), table: string(table), allColumns: true,
+		}); err != nil {
+			return session.writeError(err)
+		}
+
+		var err error
+		if columns, err = h.FieldList(session, string(table), string(wildcard)); err != nil {
+			return session.writeError(err)
+		}
+	}
+
+	conn := session.conn
+	for _, column := range columnDefinitionPackets(columns) {
+		column.FieldList = true
+		if err := conn.WritePacket(column); err != nil {
+			return err
+		}
+	}
+	// TODO  CLIENT_DEPRECATE_EOF
+	return conn.WritePacket(packet.NewEOF(0, session.Status()))
+}
+
+// handleChangeUser re-authenticates with the new user, session keeps unchanged if authentication fails.
+// https://dev.mysql.com/doc/internals/en/com-change-user.html
+func (s *Server) handleChangeUser(session *Session, data []byte) error {
+	conn := session.conn
+	pkt, err := packet.ParseChangeUser(data, conn.Capabilities())
+	if err != nil {
+		return session.writeError(myerrors.MalformedPacket.Build())
+	}
+
+	clientPlugin := pkt.AuthPlugin
+	if conn.Capabilities()&flag.ClientPluginAuth == 0 {
+		clientPlugin = auth.MySQLNativePassword
+	}
+	user := string(pkt.Username)
+	host := clientHost(conn)
+	database := string(pkt.Database)
+	key, expired, err := s.authenticate(conn, user, host, database, clientPlugin, pkt.AuthRes, session.salt)
+	if err != nil {
+		return session.writeError(err)
+	}
+	if key != session.Key() {
+		if err := s.acquireUserConnection(key, user); err != nil {
+			return session.writeError(err)
+		}
+		s.releaseUserConnection(session.Key())
+	}
+
+	s.closeStmts(session)
+	session.reset()
+	session.mu.Lock()
+	session.key = key
+	session.user = user
+	session.host = host
+	session.database = database
+	session.passwordExpired = expired
+	if pkt.CharacterSet != nil {
+		session.collation = pkt.CharacterSet
+	}
+	session.attrs = make(map[string]string)
+	for _, attr := range pkt.Attributes {
+		session.attrs[attr.Key] = attr.Val
+	}
+	session.mu.Unlock()
+
+	if h, ok := s.config.Handler.(ChangeUserCommand); ok {
+		if err := h.ChangeUser(session); err != nil {
+			session.writeError(err)
+			return err
+		}
+	}
+	return session.writeOK(&mysql.Result{})
+}
+
+// handleResetConnection closes prepared statements and resets session state,
+// current user and database are kept.
+func (s *Server) handleResetConnection(session *Session) error {
+	s.closeStmts(session)
+	session.reset()
+	if h, ok := s.config.Handler.(ResetConnectionCommand); ok {
+		if err := h.ResetConnection(session); err != nil {
+			return session.writeError(err)
+		}
+	}
+	return session.writeOK(&mysql.Result{})
+}
+
+func (s *Server) handleSetOption(session *Session, data []byte) error {
+	if len(data) < 3 {
+		return session.writeError(myerrors.MalformedPacket.Build())
+	}
+	option := flag.SetOption(packet.FixedLengthInteger.Get(data[1:3]))
+	if option != flag.MultiStatementsOn && option != flag.MultiStatementsOff {
+		return session.writeError(myerrors.UnknownCom.Build())
+	}
+
+	if h, ok := s.config.Handler.(SetOptionCommand); ok {
+		if err := h.SetOption(session, option); err != nil {
+			return session.writeError(err)
+		}
+	}
+
+	conn := session.conn
+	switch option {
+	case flag.MultiStatementsOn:
+		conn.SetCapabilities(conn.Capabilities() | flag.ClientMultiStatements)
+	case flag.MultiStatementsOff:
+		conn.SetCapabilities(conn.Capabilities() &^ flag.ClientMultiStatements)
+	}
+	// TODO  CLIENT_DEPRECATE_EOF
+	return conn.WritePacket(packet.NewEOF(0, session.Status()))
+}
+
+func (s *Server) handleStatistics(session *Session) error {
+	if h, ok := s.config.Handler.(StatisticsCommand); ok {
+		stats, err := h.Statistics(session)
+		if err != nil {
+			return session.writeError(err)
+		}
+		if stats != nil {
+			return session.conn.WritePacket(stats)
+		}
+	}
+	return session.conn.WritePacket(s.statistics())
+}
+
+func (s *Server) statistics() *packet.Statistics {
+	s.mu.Lock()
+	threads := len(s.conns)
+	s.mu.Unlock()
+
+	uptime := time.Since(s.startTime)
+	questions := atomic.LoadUint64(&s.questions)
+	stats := &packet.Statistics{
+		Uptime:    uint64(uptime.Seconds()),
+		Threads:   uint64(threads),
+		Questions: questions,
+	}
+	if uptime >= time.Second {
+		stats.QueriesPerSecondAvg = float64(questions) / uptime.Seconds()
+	}
+	return stats
+}
+
+func (s *Server) handleKill(session *Session, data []byte) error {
+	if len(data) < 5 {
+		return session.writeError(myerrors.MalformedPacket.Build())
+	}
+	connId := uint32(packet.FixedLengthInteger.Get(data[1:5]))
+
+	s.mu.Lock()
+	tc := s.conns[connId]
+	s.mu.Unlock()
+	var target *Session
+	if tc != nil {
+		tc.mu.Lock()
+		target = tc.session
+		tc.mu.Unlock()
+	}
+	if target == nil {
+		return session.writeError(myerrors.NoSuchThread.Build(connId))
+	}
+
+	if h, ok := s.config.Handler.(KillCommand); ok {
+		if err := h.Kill(session, target); err != nil {
+			return session.writeError(err)
+		}
+	} else if target.User() != session.User() {
+		return session.writeError(myerrors.KillDenied.Build(connId))
+	}
+
+	if err := session.writeOK(&mysql.Result{}); err != nil {
+		return err
+	}
+	tc.close()
+	return nil
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/charset"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"testing"
+)
+
+type commandHandler struct {
+	DefaultHandler
+	changed chan string
+}
+
+func (h *commandHandler) InitDB(session *Session, database string) error {
+	if database == "missing" {
+		return myerrors.NewServer(code.ErrSendToClient, "unknown database")
+	}
+	return nil
+}
+
+func (h *commandHandler) FieldList(session *Session, table, wildcard string) ([]mysql.Column, error) {
+	return []mysql.Column{
+		{Table: table, Name: "id", Type: flag.MySQLTypeLong},
+		{Table: table, Name: "name", Type: flag.MySQLTypeVarString},
+	}, nil
+}
+
+func (h *commandHandler) ChangeUser(session *Session) error {
+	h.changed <- session.User()
+	return nil
+}
+
+func TestCommands(t *testing.T) {
+	userProvider := newUserProvider(t,
+		&CreateUserRequest{User: "root", Host: "%", Method: auth.MySQLNativePassword},
+		&CreateUserRequest{User: "app", Host: "%", Method: auth.MySQLNativePassword},
+		&CreateUserRequest{User: "secret", Host: "%", Password: "123456", Method: auth.MySQLNativePassword})
+	h := &commandHandler{changed: make(chan string, 1)}
+	srv := newTestServer(userProvider, h)
+
+	connect := func(user string) *client.Conn {
+		conn, err := client.CreateConnection(client.WithDialer(pipeDialer(srv)), client.WithUser(user))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	conn := connect("root")
+	defer conn.Close()
+
+	session := func(conn *client.Conn) *Session {
+		for _, s := range srv.Sessions() {
+			if s.ConnectionId() == conn.ConnectionId() {
+				return s
+			}
+		}
+		t.Fatalf("session %d not found", conn.ConnectionId())
+		return nil
+	}
+	command := func(cmd packet.Command, data []byte) []byte {
+		if err := conn.WriteCommandPacket(packet.NewCmd(cmd, data)); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := conn.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	expectErr := func(data []byte, c code.Err) {
+		t.Helper()
+		if !packet.IsErr(data) {
+			t.Fatalf("expected ERR packet, got %x", data)
+		}
+		errPkt, err := packet.ParseERR(data, conn.Capabilities())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errPkt.ErrorCode != c {
+			t.Fatalf("expected error %d, got %d", c, errPkt.ErrorCode)
+		}
+	}
+
+	t.Run("InitDB", func(t *testing.T) {
+		expectErr(command(packet.ComInitDB, []byte("missing")), code.ErrSendToClient)
+		if resp := command(packet.ComInitDB, []byte("db1")); !packet.IsOK(resp) {
+			t.Fatalf("expected OK packet, got %x", resp)
+		}
+		if db := session(conn).Database(); db != "db1" {
+			t.Fatalf("expected database db1, got %q", db)
+		}
+	})
+
+	t.Run("FieldList", func(t *testing.T) {
+		data := command(packet.ComFieldList, append([]byte("t1"), 0x00))
+		var names []string
+		for !packet.IsEOF(data) {
+			column, err := packet.ParseColumnDefinition(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if column.Table != "t1" {
+				t.Fatalf("expected table t1, got %s", column.Table)
+			}
+			names = append(names, column.Name)
+			if data, err = conn.ReadPacket(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(names) != 2 || names[0] != "id" || names[1] != "name" {
+			t.Fatalf("unexpected columns: %v", names)
+		}
+	})
+
+	t.Run("SetOption", func(t *testing.T) {
+		if err := conn.SetOption(flag.MultiStatementsOn); err != nil {
+			t.Fatal(err)
+		}
+		if session(conn).Capabilities()&flag.ClientMultiStatements == 0 {
+			t.Fatal("expected multi statements on")
+		}
+		expectErr(command(packet.ComSetOption, []byte{0x09, 0x00}), code.ErrUnknownComError)
+	})
+
+	t.Run("Statistics", func(t *testing.T) {
+		stats, err := conn.Statistics()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Threads != 1 {
+			t.Fatalf("expected 1 thread, got %d", stats.Threads)
+		}
+	})
+
+	t.Run("ResetConnection", func(t *testing.T) {
+		session(conn).Set("k", "v")
+		if resp := command(packet.ComResetConnection, nil); !packet.IsOK(resp) {
+			t.Fatalf("expected OK packet, got %x", resp)
+		}
+		if session(conn).Value("k") != nil || session(conn).Database() != "db1" {
+			t.Fatal("unexpected session state after reset")
+		}
+	})
+
+	t.Run("ChangeUser", func(t *testing.T) {
+		collation, err := charset.GetCollationByName(charset.UTF8MB4GeneralCi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changeUser := func(user string, authRes []byte) []byte {
+			if err := conn.WriteCommandPacket(&packet.ChangeUser{
+				ComChangeUser: packet.ComChangeUser.Byte(),
+				Username:      []byte(user),
+				AuthRes:       authRes,
+				Database:      []byte("db2"),
+				CharacterSet:  collation,
+				AuthPlugin:    auth.MySQLNativePassword,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			resp, err := conn.ReadPacket()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return resp
+		}
+
+		expectErr(changeUser("secret", make([]byte, 20)), code.ErrAccessDeniedError)
+		if s := session(conn); s.User() != "root" || s.Database() != "db1" {
+			t.Fatalf("session changed after failed COM_CHANGE_USER: %s %s", s.User(), s.Database())
+		}
+
+		if resp := changeUser("app", nil); !packet.IsOK(resp) {
+			t.Fatalf("expected OK packet, got %x", resp)
+		}
+		if user := <-h.changed; user != "app" {
+			t.Fatalf("expected user app, got %s", user)
+		}
+		if s := session(conn); s.Key() != "app@%" || s.Database() != "db2" {
+			t.Fatalf("unexpected session: %s %s", s.Key(), s.Database())
+		}
+	})
+
+	t.Run("Kill", func(t *testing.T) {
+		other := connect("app")
+		defer other.Close()
+		stranger := connect("root")
+		defer stranger.Close()
+
+		expectErr(command(packet.ComProcessKill, packet.FixedLengthInteger.Dump(uint64(stranger.ConnectionId()), 4)),
+			code.ErrKillDeniedError)
+		expectErr(command(packet.ComProcessKill, []byte{0x00, 0x00, 0x00, 0x00}), code.ErrNoSuchThread)
+
+		if err := conn.Kill(other.ConnectionId()); err != nil {
+			t.Fatal(err)
+		}
+		if err := other.Ping(); err == nil {
+			t.Fatal("expected error on killed connection")
+		}
+	})
+}
+
+type resultHandler struct {
+	DefaultHandler
+}
+
+func (h *resultHandler) Query(session *Session, query string) (interface{}, error) {
+	if query == "nil" {
+		return nil, nil
+	}
+	return query, nil
+}
+
+func TestQueryResult(t *testing.T) {
+	srv := newTestServer(newUserProvider(t), new(resultHandler))
+	conn, err := client.CreateConnection(client.WithDialer(pipeDialer(srv)), client.WithUser("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Exec("nil"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("unexpected"); errorCode(err) != code.ErrInternalError {
+		t.Fatalf("expected internal error, got %v", err)
+	}
+	if err := conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,9 +2,11 @@ package server
 
 import (
 	"github.com/vczyh/mysql-protocol/auth"
+	"time"
 )
 
 type Config struct {
+	Host              string
 	Port              int
 	Version           string
 	DefaultAuthMethod auth.Method
@@ -12,6 +14,9 @@ type Config struct {
 	UserProvider UserProvider
 	SHA2Cache    SHA2Cache
 
+	// PasswordVerifier verifies password of mysql_clear_password accounts.
+	PasswordVerifier PasswordVerifier
+
 	CertsDir string
 
 	UseSSL  bool
@@ -28,6 +33,26 @@ type Config struct {
 	CachingSHA2PasswordPrivateKeyPath string
 	CachingSHA2PasswordPublicKeyPath  string
 
+	// MaxConnections is max number of connections, 0 means no limit.
+	MaxConnections int
+	// MaxUserConnections is max number of connections of each account, 0 means no limit.
+	MaxUserConnections int
+	// WaitTimeout and InteractiveTimeout close idle sessions, 0 means no timeout.
+	WaitTimeout        time.Duration
+	InteractiveTimeout time.Duration
+	// ConnectTimeout limits time of connection phase.
+	ConnectTimeout   time.Duration
+	MaxAllowedPacket int
+
+	// Variables is registry of system variables used by DefaultHandler.
+	Variables *SystemVariables
+
+	// CheckPrivileges checks privileges of statements by PrivilegeProvider.
+	CheckPrivileges bool
+
+	// ProxyProtocolNetworks are sources trusted to send PROXY protocol header.
+	ProxyProtocolNetworks []string
+
 	Handler Handler
 	Logger  Logger
 }
@@ -0,0 +1,78 @@
+package firewall
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Allow-list file has a statement per line, which is digest and normalized statement
+// separated by a space. Empty lines and lines starting with # are ignored.
+
+// LoadAllowList return rule allowing statements of allow-list file, the file is usually
+// recorded by learning mode. Use it with WithDefaultAction(ActionBlock) to block other statements.
+func LoadAllowList(path string) (Rule, error) {
+	digests, err := readAllowList(path)
+	if err != nil {
+		return Rule{}, err
+	}
+	return Rule{Name: "allow-list", Digests: digests, Action: ActionAllow}, nil
+}
+
+func readAllowList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var digests []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		digest := line
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			digest = line[:i]
+		}
+		digests = append(digests, digest)
+	}
+	return digests, scanner.Err()
+}
+
+func (h *Handler) loadLearned() {
+	h.learned = make(map[string]bool)
+	digests, err := readAllowList(h.learnPath)
+	if err != nil && !os.IsNotExist(err) {
+		h.logger.Error(fmt.Errorf("firewall load allow-list %s failed: %v", h.learnPath, err))
+	}
+	for _, digest := range digests {
+		h.learned[digest] = true
+	}
+}
+
+// learn appends statement to allow-list file if its digest isn't recorded.
+func (h *Handler) learn(stmt *statement) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.learned[stmt.digest] {
+		return
+	}
+
+	f, err := os.OpenFile(h.learnPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err == nil {
+		_, err = fmt.Fprintf(f, "%s %s\n", stmt.digest, strings.Join(strings.Fields(stmt.normalized), " "))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		h.logger.Error(fmt.Errorf("firewall record allow-list %s failed: %v", h.learnPath, err))
+		return
+	}
+	h.learned[stmt.digest] = true
+}
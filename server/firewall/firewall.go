@@ -0,0 +1,284 @@
+// Package firewall provides a Handler middleware that allows or blocks statements by rules.
+package firewall
+
+import (
+	"fmt"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/server"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrBlocked is sent to client when statement is blocked by default.
+	ErrBlocked = myerrors.NewServerWithSQLState(code.ErrAccessDeniedError, "28000", "Statement was blocked by Firewall")
+)
+
+type Action uint8
+
+const (
+	ActionAllow Action = iota
+	ActionBlock
+	// ActionLog allows statement and logs it
+	ActionLog
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionAllow:
+		return "allow"
+	case ActionBlock:
+		return "block"
+	case ActionLog:
+		return "log"
+	default:
+		return "unknown action"
+	}
+}
+
+// Rule matches statement if all of its non-empty conditions match,
+// and condition matches if any of its values matches.
+type Rule struct {
+	Name string
+
+	// Users are user names of session.
+	Users []string
+
+	// StatementTypes are upper case types, such as SELECT, INSERT and CREATE TABLE.
+	StatementTypes []string
+
+	// Schemas are databases of tables, table without schema is in current database.
+	// Databases of USE, CREATE DATABASE and DROP DATABASE are also matched.
+	Schemas []string
+
+	// Tables are table or schema.table.
+	Tables []string
+
+	// Functions are names of functions called by statement, such as SLEEP and COUNT.
+	Functions []string
+
+	// Digests are digests or normalized statements, see parser.NormalizeDigest,
+	// lists of literals or parameter markers are normalized to "...".
+	// Digest of prepared statement is the same as statement with literals.
+	Digests []string
+
+	Action Action
+
+	// Err is sent to client when statement is blocked, default is ErrBlocked or WithBlockError.
+	Err error
+}
+
+type rule struct {
+	*Rule
+	users, types, schemas, tables, functions, digests map[string]bool
+}
+
+func newRule(r Rule) *rule {
+	set := func(values []string, fold bool) map[string]bool {
+		if len(values) == 0 {
+			return nil
+		}
+		m := make(map[string]bool, len(values))
+		for _, v := range values {
+			if fold {
+				v = strings.ToLower(v)
+			}
+			m[v] = true
+		}
+		return m
+	}
+	return &rule{
+		Rule:      &r,
+		users:     set(r.Users, false),
+		types:     set(r.StatementTypes, true),
+		schemas:   set(r.Schemas, false),
+		tables:    set(r.Tables, false),
+		functions: set(r.Functions, true),
+		digests:   set(r.Digests, false),
+	}
+}
+
+func (r *rule) match(session *server.Session, stmt *statement) bool {
+	if r.users != nil && !r.users[session.User()] {
+		return false
+	}
+	if r.types != nil && !r.types[strings.ToLower(stmt.tp)] {
+		return false
+	}
+	if r.schemas != nil && !matchAny(r.schemas, stmt.schemas, false) {
+		return false
+	}
+	if r.tables != nil && !r.matchTable(stmt.tables) {
+		return false
+	}
+	if r.functions != nil && !matchAny(r.functions, stmt.functions, true) {
+		return false
+	}
+	if r.digests != nil && !r.digests[stmt.digest] && !r.digests[stmt.normalized] {
+		return false
+	}
+	return true
+}
+
+func (r *rule) matchTable(tables []string) bool {
+	for _, t := range tables {
+		if r.tables[t] {
+			return true
+		}
+		if i := strings.IndexByte(t, '.'); i >= 0 && r.tables[t[i+1:]] {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(set map[string]bool, values []string, fold bool) bool {
+	for _, v := range values {
+		if fold {
+			v = strings.ToLower(v)
+		}
+		if set[v] {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler checks queries and prepared statements by rules before Next performs them,
+// the first matched rule decides action, and statement not matching any rule takes the default action.
+// Every statement of multiple statements must be allowed, and so must statement prepared by PREPARE.
+//
+// Statement that can't be parsed and PREPARE from user variable are matched only by rules with Digests,
+// they are blocked if no such rule matches.
+type Handler struct {
+	server.Wrapper
+
+	rules         []*rule
+	defaultAction Action
+	blockErr      error
+	logger        server.Logger
+
+	// learning mode records digests of statements to allow-list file
+	learnPath string
+	mu        sync.Mutex
+	learned   map[string]bool
+}
+
+func NewHandler(next server.Handler, rules []Rule, opts ...Option) *Handler {
+	h := &Handler{
+		Wrapper:  server.Wrapper{Next: next},
+		blockErr: ErrBlocked,
+	}
+	for _, r := range rules {
+		h.rules = append(h.rules, newRule(r))
+	}
+	for _, opt := range opts {
+		opt.apply(h)
+	}
+	if h.logger == nil {
+		h.logger = server.NewDefaultLogger(server.SystemLevel, os.Stdout)
+	}
+	if h.learnPath != "" {
+		h.loadLearned()
+	}
+	return h
+}
+
+func (h *Handler) Query(session *server.Session, query string) (interface{}, error) {
+	if err := h.check(session, query); err != nil {
+		return nil, err
+	}
+	return h.Wrapper.Query(session, query)
+}
+
+func (h *Handler) Prepare(session *server.Session, query string) (int, []mysql.Column, error) {
+	if err := h.check(session, query); err != nil {
+		return 0, nil, err
+	}
+	return h.Wrapper.Prepare(session, query)
+}
+
+func (h *Handler) check(session *server.Session, query string) error {
+	for _, stmt := range parse(session, query) {
+		if h.learnPath != "" {
+			h.learn(stmt)
+			continue
+		}
+
+		action, r := h.defaultAction, (*rule)(nil)
+		if stmt.unknown {
+			action = ActionBlock
+		}
+		for _, candidate := range h.rules {
+			if stmt.unknown && candidate.digests == nil {
+				continue
+			}
+			if candidate.match(session, stmt) {
+				action, r = candidate.Action, candidate
+				break
+			}
+		}
+
+		switch action {
+		case ActionBlock:
+			h.logger.Warn(h.logError(session, stmt, action, r))
+			if r != nil && r.Err != nil {
+				return r.Err
+			}
+			return h.blockErr
+		case ActionLog:
+			h.logger.Info(h.logError(session, stmt, action, r))
+		}
+	}
+	return nil
+}
+
+func (h *Handler) logError(session *server.Session, stmt *statement, action Action, r *rule) error {
+	name := "default"
+	if r != nil {
+		name = r.Name
+	}
+	return fmt.Errorf("firewall %s by rule %q: user '%s'@'%s' digest %s statement: %s",
+		action, name, session.User(), session.Host(), stmt.digest, stmt.text)
+}
+
+// WithDefaultAction sets action of statement not matching any rule, default is ActionAllow.
+func WithDefaultAction(action Action) Option {
+	return optionFun(func(h *Handler) {
+		h.defaultAction = action
+	})
+}
+
+// WithBlockError sets error of blocked statement for rules without Err.
+func WithBlockError(err error) Option {
+	return optionFun(func(h *Handler) {
+		h.blockErr = err
+	})
+}
+
+func WithLogger(logger server.Logger) Option {
+	return optionFun(func(h *Handler) {
+		h.logger = logger
+	})
+}
+
+// WithLearning enables learning mode, all statements are allowed and their digests are
+// appended to allow-list file at path, which is loaded by LoadAllowList.
+func WithLearning(path string) Option {
+	return optionFun(func(h *Handler) {
+		h.learnPath = path
+	})
+}
+
+type Option interface {
+	apply(*Handler)
+}
+
+type optionFun func(*Handler)
+
+func (f optionFun) apply(h *Handler) {
+	f(h)
+}
@@ -0,0 +1,183 @@
+package firewall
+
+import (
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/packet"
+	"github.com/vczyh/mysql-protocol/server"
+	"github.com/vczyh/mysql-protocol/server/memengine"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func connect(t *testing.T, handler server.Handler, user string) *client.Conn {
+	t.Helper()
+	srv := servertest.NewServer(handler, []*server.CreateUserRequest{
+		{User: "admin", Host: "%", Method: auth.MySQLNativePassword},
+		{User: "app", Host: "%", Method: auth.MySQLNativePassword},
+	})
+	conn, err := client.CreateConnection(client.WithUser(user), client.WithDialer(servertest.Dialer(srv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func setup(t *testing.T, engine *memengine.Handler) {
+	t.Helper()
+	conn := connect(t, engine, "admin")
+	defer conn.Close()
+	for _, query := range []string{
+		"CREATE DATABASE app",
+		"CREATE DATABASE secret",
+		"CREATE TABLE app.users (id INT PRIMARY KEY, name VARCHAR(16))",
+		"CREATE TABLE secret.keys (id INT)",
+		"INSERT INTO app.users VALUES (1, 'a'), (2, 'b')",
+	} {
+		if _, err := conn.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+}
+
+func expectErr(t *testing.T, err error, c code.Err) {
+	t.Helper()
+	errPkt, ok := err.(*packet.ERR)
+	if !ok || errPkt.ErrorCode != c {
+		t.Fatalf("expected error %d, got %v", c, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	engine := memengine.NewHandler()
+	setup(t, engine)
+
+	logger := server.NewDefaultLogger(server.ErrorLevel, ioutil.Discard)
+	handler := NewHandler(engine, []Rule{
+		{Name: "admin", Users: []string{"admin"}, Action: ActionAllow},
+		{Name: "no-ddl", StatementTypes: []string{"drop table", "CREATE TABLE"}, Action: ActionBlock,
+			Err: myerrors.NotSupportedYet.Build("DDL")},
+		{Name: "secret", Schemas: []string{"secret"}, Action: ActionBlock},
+		{Name: "sleep", Functions: []string{"SLEEP"}, Action: ActionBlock},
+		{Name: "delete", Tables: []string{"app.users"}, StatementTypes: []string{"DELETE"}, Action: ActionBlock},
+		{Name: "audit", Tables: []string{"users"}, Action: ActionLog},
+	}, WithLogger(logger))
+
+	conn := connect(t, handler, "app")
+	defer conn.Close()
+	if err := conn.InitDB("app"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		"SELECT name FROM users WHERE id = 1",
+		"UPDATE users SET name = 'c' WHERE id = 2",
+		"SELECT @@version_comment LIMIT 1",
+	} {
+		if _, err := conn.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	_, err := conn.Exec("DROP TABLE users")
+	expectErr(t, err, code.ErrNotSupportedYet)
+	_, err = conn.Exec("SELECT * FROM secret.keys")
+	expectErr(t, err, code.ErrAccessDeniedError)
+	_, err = conn.Exec("USE secret")
+	expectErr(t, err, code.ErrAccessDeniedError)
+	_, err = conn.Exec("SELECT SLEEP(1)")
+	expectErr(t, err, code.ErrAccessDeniedError)
+	_, err = conn.Exec("DELETE FROM users")
+	expectErr(t, err, code.ErrAccessDeniedError)
+	_, err = conn.Prepare("DELETE FROM users WHERE id = ?")
+	expectErr(t, err, code.ErrAccessDeniedError)
+
+	// statement prepared by PREPARE is checked
+	_, err = conn.Exec("PREPARE s FROM 'DROP TABLE users'")
+	expectErr(t, err, code.ErrNotSupportedYet)
+	_, err = conn.Exec("PREPARE s FROM 'DELETE FROM users WHERE id = ?'")
+	expectErr(t, err, code.ErrAccessDeniedError)
+	_, err = conn.Exec("PREPARE s FROM @query")
+	expectErr(t, err, code.ErrAccessDeniedError)
+	// statement that can't be parsed is blocked
+	_, err = conn.Exec("SELECT FROM WHERE")
+	expectErr(t, err, code.ErrAccessDeniedError)
+
+	// USE of multi-statement query changes database of the following statements
+	multi := connect(t, handler, "app")
+	defer multi.Close()
+	if err := multi.SetOption(flag.MultiStatementsOn); err != nil {
+		t.Fatal(err)
+	}
+	_, err = multi.Exec("USE app; DELETE FROM users")
+	expectErr(t, err, code.ErrAccessDeniedError)
+
+	admin := connect(t, handler, "admin")
+	defer admin.Close()
+	if _, err := admin.Exec("SELECT * FROM secret.keys"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = admin.Exec("SELECT FROM WHERE")
+	expectErr(t, err, code.ErrAccessDeniedError)
+
+	// statement that can't be parsed is allowed by digest
+	normalized, _ := normalizeDigest("SELECT FROM WHERE")
+	digest := connect(t, NewHandler(engine, []Rule{{Digests: []string{normalized}, Action: ActionAllow}},
+		WithLogger(logger)), "app")
+	defer digest.Close()
+	_, err = digest.Exec("SELECT FROM WHERE")
+	expectErr(t, err, code.ErrParseError)
+}
+
+func TestLearning(t *testing.T) {
+	engine := memengine.NewHandler()
+	setup(t, engine)
+	path := filepath.Join(t.TempDir(), "allow-list")
+
+	learning := connect(t, NewHandler(engine, nil, WithLearning(path)), "app")
+	for _, query := range []string{
+		"SELECT name FROM app.users WHERE id = 1",
+		"SELECT name FROM app.users WHERE id = 2",
+		"UPDATE app.users SET name = 'x' WHERE id = 1",
+	} {
+		if _, err := learning.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	stmt, err := learning.Prepare("INSERT INTO app.users VALUES (?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt.Close()
+	learning.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 {
+		t.Fatalf("expected 3 digests, got %q", lines)
+	}
+
+	allowList, err := LoadAllowList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := connect(t, NewHandler(engine, []Rule{allowList}, WithDefaultAction(ActionBlock),
+		WithLogger(server.NewDefaultLogger(server.ErrorLevel, ioutil.Discard))), "app")
+	defer conn.Close()
+	if _, err := conn.Exec("SELECT name FROM app.users WHERE id = 3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("INSERT INTO app.users VALUES (3, 'c')"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec("SELECT id FROM app.users")
+	expectErr(t, err, code.ErrAccessDeniedError)
+}
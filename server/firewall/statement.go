@@ -0,0 +1,213 @@
+package firewall
+
+import (
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/vczyh/mysql-protocol/server"
+	"regexp"
+	"strings"
+)
+
+// markerList matches list of parameter markers in normalized statement,
+// digester reduces list of literals to "..." but not list of markers.
+var markerList = regexp.MustCompile(`\? , \?( , \?)*`)
+
+// statement is what rules match of a statement.
+type statement struct {
+	text string
+	// upper case type, such as SELECT and CREATE TABLE, empty if statement can't be parsed
+	tp string
+	// unknown is true if what statement does isn't known, such as statement that can't be parsed
+	// and PREPARE from user variable
+	unknown bool
+	// schemas of tables and databases referenced by statement
+	schemas []string
+	// tables are schema.table
+	tables []string
+	// lower case function names
+	functions  []string
+	normalized string
+	digest     string
+}
+
+// parse return statements of query, query that can't be parsed is an unknown statement
+// only having digest. Statement prepared by PREPARE follows PREPARE.
+func parse(session *server.Session, query string) []*statement {
+	database := session.Database()
+	return parseQuery(&database, query)
+}
+
+// parseQuery parses query, database is current database which is changed by USE.
+func parseQuery(database *string, query string) []*statement {
+	stmtNodes, _, err := parser.New().Parse(query, "", "")
+	if err != nil || len(stmtNodes) == 0 {
+		stmt := &statement{text: query, unknown: true}
+		stmt.normalized, stmt.digest = normalizeDigest(query)
+		return []*statement{stmt}
+	}
+
+	var stmts []*statement
+	for _, stmtNode := range stmtNodes {
+		text := stmtNode.Text()
+		if text == "" {
+			text = query
+		}
+		stmt := &statement{text: text, tp: statementType(stmtNode)}
+		stmt.normalized, stmt.digest = normalizeDigest(text)
+
+		c := &collector{stmt: stmt, database: *database}
+		switch v := stmtNode.(type) {
+		case *ast.UseStmt:
+			c.addSchema(v.DBName)
+		case *ast.CreateDatabaseStmt:
+			c.addSchema(v.Name)
+		case *ast.DropDatabaseStmt:
+			c.addSchema(v.Name)
+		}
+		stmtNode.Accept(c)
+		stmts = append(stmts, stmt)
+
+		switch v := stmtNode.(type) {
+		case *ast.UseStmt:
+			// USE changes database of the following statements
+			*database = v.DBName
+		case *ast.PrepareStmt:
+			// EXECUTE performs statement prepared by PREPARE, so it's checked when it's prepared
+			if v.SQLVar != nil {
+				stmt.unknown = true
+				continue
+			}
+			prepared := *database
+			stmts = append(stmts, parseQuery(&prepared, v.SQLText)...)
+		}
+	}
+	return stmts
+}
+
+// normalizeDigest is parser.NormalizeDigest, except that statement with parameter markers
+// has the same digest as statement with literals.
+func normalizeDigest(text string) (string, string) {
+	normalized := markerList.ReplaceAllString(parser.Normalize(text), "...")
+	return normalized, parser.DigestNormalized(normalized)
+}
+
+// collector collects tables and functions of statement.
+type collector struct {
+	stmt *statement
+	// current database of session, it's schema of table without schema
+	database string
+}
+
+func (c *collector) Enter(n ast.Node) (ast.Node, bool) {
+	switch v := n.(type) {
+	case *ast.TableName:
+		schema := v.Schema.O
+		if schema == "" {
+			schema = c.database
+		}
+		c.addSchema(schema)
+		c.stmt.tables = append(c.stmt.tables, schema+"."+v.Name.O)
+	case *ast.FuncCallExpr:
+		c.stmt.functions = append(c.stmt.functions, v.FnName.L)
+	case *ast.AggregateFuncExpr:
+		c.stmt.functions = append(c.stmt.functions, strings.ToLower(v.F))
+	case *ast.WindowFuncExpr:
+		c.stmt.functions = append(c.stmt.functions, strings.ToLower(v.F))
+	}
+	return n, false
+}
+
+func (c *collector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+func (c *collector) addSchema(schema string) {
+	if schema == "" {
+		return
+	}
+	for _, s := range c.stmt.schemas {
+		if s == schema {
+			return
+		}
+	}
+	c.stmt.schemas = append(c.stmt.schemas, schema)
+}
+
+func statementType(stmtNode ast.StmtNode) string {
+	switch v := stmtNode.(type) {
+	case *ast.SelectStmt, *ast.UnionStmt:
+		return "SELECT"
+	case *ast.InsertStmt:
+		if v.IsReplace {
+			return "REPLACE"
+		}
+		return "INSERT"
+	case *ast.UpdateStmt:
+		return "UPDATE"
+	case *ast.DeleteStmt:
+		return "DELETE"
+	case *ast.LoadDataStmt:
+		return "LOAD DATA"
+	case *ast.CreateDatabaseStmt:
+		return "CREATE DATABASE"
+	case *ast.DropDatabaseStmt:
+		return "DROP DATABASE"
+	case *ast.AlterDatabaseStmt:
+		return "ALTER DATABASE"
+	case *ast.CreateTableStmt:
+		return "CREATE TABLE"
+	case *ast.DropTableStmt:
+		if v.IsView {
+			return "DROP VIEW"
+		}
+		return "DROP TABLE"
+	case *ast.AlterTableStmt:
+		return "ALTER TABLE"
+	case *ast.TruncateTableStmt:
+		return "TRUNCATE TABLE"
+	case *ast.RenameTableStmt:
+		return "RENAME TABLE"
+	case *ast.CreateIndexStmt:
+		return "CREATE INDEX"
+	case *ast.DropIndexStmt:
+		return "DROP INDEX"
+	case *ast.CreateViewStmt:
+		return "CREATE VIEW"
+	case *ast.UseStmt:
+		return "USE"
+	case *ast.SetStmt:
+		return "SET"
+	case *ast.ShowStmt:
+		return "SHOW"
+	case *ast.ExplainStmt:
+		return "EXPLAIN"
+	case *ast.BeginStmt:
+		return "BEGIN"
+	case *ast.CommitStmt:
+		return "COMMIT"
+	case *ast.RollbackStmt:
+		return "ROLLBACK"
+	case *ast.PrepareStmt:
+		return "PREPARE"
+	case *ast.ExecuteStmt:
+		return "EXECUTE"
+	case *ast.DeallocateStmt:
+		return "DEALLOCATE"
+	case *ast.CreateUserStmt:
+		return "CREATE USER"
+	case *ast.AlterUserStmt:
+		return "ALTER USER"
+	case *ast.DropUserStmt:
+		return "DROP USER"
+	case *ast.GrantStmt:
+		return "GRANT"
+	case *ast.RevokeStmt:
+		return "REVOKE"
+	case *ast.KillStmt:
+		return "KILL"
+	case *ast.FlushStmt:
+		return "FLUSH"
+	default:
+		return "OTHER"
+	}
+}
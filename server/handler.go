@@ -16,17 +16,27 @@ type Handler interface {
 	Listener
 }
 
+// Command handles commands of an authenticated session.
+// Handler can read and change session state by session, such as current database and status flags.
 type Command interface {
 	// Ping should return nil when server is healthy.
-	Ping() error
+	Ping(session *Session) error
 
 	// Query performs INSERT UPDATE DELETE CREATE DROP and should return *mysql.Result.
-	// Query performs SELECT and should return *ResultSet.
-	Query(query string) (interface{}, error)
+	// Query performs SELECT and should return *ResultSet, or RowIterator to stream large result.
+	// Query can also return Response to write response packets by itself.
+	// nil result is responded with OK, and result of other types with ERR.
+	Query(session *Session, query string) (interface{}, error)
 
-	// Other performs other commands.
+	// Other performs other commands, response should be written to session.Conn().
 	// data is complete command data, does not include packet header.
-	Other(data []byte, conn mysql.Conn)
+	Other(session *Session, data []byte)
+}
+
+// Response writes a complete response of command to session.Conn() by itself instead of server,
+// such as relaying response packets of another server. Returning error closes the connection.
+type Response interface {
+	WriteResponse(session *Session) error
 }
 
 type Listener interface {
@@ -40,17 +50,22 @@ func NewDefaultHandler() Handler {
 	return &DefaultHandler{}
 }
 
-func (*DefaultHandler) Ping() error {
+func (*DefaultHandler) Ping(session *Session) error {
 	return nil
 }
 
-func (*DefaultHandler) Query(query string) (interface{}, error) {
+// Query answers statements sent by connectors when connecting, see SystemQuery.
+func (*DefaultHandler) Query(session *Session, query string) (interface{}, error) {
 	p := parser.New()
 	stmtNode, err := p.ParseOneStmt(query, "", "")
 	if err != nil {
 		return nil, myerrors.NewServer(code.ErrSendToClient, err.Error())
 	}
 
+	if rs, handled, err := SystemStatement(session, stmtNode); handled {
+		return rs, err
+	}
+
 	switch v := stmtNode.(type) {
 	case *ast.SelectStmt:
 		rs, err := NewSimpleResultSet(
@@ -64,6 +79,10 @@ func (*DefaultHandler) Query(query string) (interface{}, error) {
 		}
 		return rs, nil
 
+	case *ast.UseStmt:
+		session.SetDatabase(v.DBName)
+		return &mysql.Result{}, nil
+
 	case *ast.CreateDatabaseStmt:
 		return &mysql.Result{
 			AffectedRows: 1,
@@ -76,10 +95,53 @@ func (*DefaultHandler) Query(query string) (interface{}, error) {
 	}
 }
 
+// Prepare counts parameter markers, the statement is performed by Query when executing,
+// so statement with parameters can't be executed.
+func (*DefaultHandler) Prepare(session *Session, query string) (int, []mysql.Column, error) {
+	p := parser.New()
+	stmtNode, err := p.ParseOneStmt(query, "", "")
+	if err != nil {
+		return 0, nil, myerrors.NewServer(code.ErrSendToClient, err.Error())
+	}
+
+	counter := new(paramMarkerCounter)
+	stmtNode.Accept(counter)
+
+	var columns []mysql.Column
+	if v, ok := stmtNode.(*ast.SelectStmt); ok {
+		columns = []mysql.Column{{Name: v.Fields.Fields[0].Text(), Type: flag.MySQLTypeVarString}}
+	}
+	return counter.count, columns, nil
+}
+
+func (h *DefaultHandler) Execute(session *Session, stmt *Stmt, args []interface{}) (interface{}, error) {
+	if stmt.ParamCount > 0 {
+		return nil, myerrors.NotSupportedYet.Build("parameters of prepared statement")
+	}
+	return h.Query(session, stmt.Query)
+}
+
+func (*DefaultHandler) CloseStmt(session *Session, stmt *Stmt) {}
+
+type paramMarkerCounter struct {
+	count int
+}
+
+func (c *paramMarkerCounter) Enter(n ast.Node) (ast.Node, bool) {
+	if _, ok := n.(ast.ParamMarkerExpr); ok {
+		c.count++
+	}
+	return n, false
+}
+
+func (c *paramMarkerCounter) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
 func (*DefaultHandler) Quit() {}
 
-func (*DefaultHandler) Other(data []byte, conn mysql.Conn) {
-	if err := conn.WriteError(myerrors.NewServer(code.ErrSendToClient, "unsupported command")); err != nil {
+func (*DefaultHandler) Other(session *Session, data []byte) {
+	if err := session.Conn().WriteError(myerrors.NewServer(code.ErrSendToClient, "unsupported command")); err != nil {
 		log.Printf("write packet error: %v\n", err)
 	}
 }
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultResolveTimeout = 5 * time.Second
+)
+
+// HostResolver resolves host names of client IP for matching accounts whose host is host name,
+// host name is used only if it resolves back to the client IP. *net.Resolver implements it.
+type HostResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// host kinds ordered from the most specific to the least specific
+const (
+	hostLiteral = iota
+	hostWildcard
+	hostAny
+	hostEmpty
+)
+
+// hostPattern is host part of account, which is host name, IP, IP/netmask, CIDR,
+// or pattern containing % and _ wildcards.
+type hostPattern struct {
+	raw  string
+	kind int
+	// order of patterns of the same kind, greater is more specific
+	order int
+
+	ip    net.IP
+	ipNet *net.IPNet
+}
+
+func parseHostPattern(host string) *hostPattern {
+	p := &hostPattern{raw: host}
+	switch {
+	case host == "":
+		p.kind = hostEmpty
+	case host == "%":
+		p.kind = hostAny
+	case strings.ContainsAny(host, "%_"):
+		p.kind = hostWildcard
+		p.order = strings.IndexAny(host, "%_")
+	default:
+		p.kind = hostLiteral
+		// literal IP is more specific than host name, which is more specific than IP/netmask,
+		// and IP/netmask with more mask bits is more specific
+		if ip := net.ParseIP(host); ip != nil {
+			p.ip, p.order = ip, 130
+		} else if ipNet := parseNetmask(host); ipNet != nil {
+			ones, _ := ipNet.Mask.Size()
+			p.ipNet, p.order = ipNet, ones
+		} else {
+			p.order = 129
+		}
+	}
+	return p
+}
+
+// parseNetmask parses IP/netmask such as 10.0.0.0/255.255.255.0 and CIDR such as 10.0.0.0/24.
+func parseNetmask(host string) *net.IPNet {
+	i := strings.IndexByte(host, '/')
+	if i < 0 {
+		return nil
+	}
+	if _, ipNet, err := net.ParseCIDR(host); err == nil {
+		return ipNet
+	}
+
+	ip, mask := net.ParseIP(host[:i]).To4(), net.ParseIP(host[i+1:]).To4()
+	if ip == nil || mask == nil {
+		return nil
+	}
+	ipMask := net.IPMask(mask)
+	// netmask must be contiguous
+	if _, bits := ipMask.Size(); bits == 0 {
+		return nil
+	}
+	return &net.IPNet{IP: ip.Mask(ipMask), Mask: ipMask}
+}
+
+// moreSpecific reports whether p is sorted before other.
+func (p *hostPattern) moreSpecific(other *hostPattern) bool {
+	if p.kind != other.kind {
+		return p.kind < other.kind
+	}
+	return p.order > other.order
+}
+
+// match reports whether client matches pattern, names return host names of client.
+func (p *hostPattern) match(host string, ip net.IP, names func() []string) bool {
+	switch {
+	case p.kind == hostAny || p.kind == hostEmpty:
+		return true
+	case p.ip != nil:
+		return ip != nil && p.ip.Equal(ip)
+	case p.ipNet != nil:
+		return ip != nil && p.ipNet.Contains(ip)
+	case p.kind == hostLiteral:
+		if ip == nil {
+			return strings.EqualFold(p.raw, host)
+		}
+		for _, name := range names() {
+			if strings.EqualFold(p.raw, name) {
+				return true
+			}
+		}
+		return false
+	default:
+		if LikeMatch(host, p.raw, '\\') {
+			return true
+		}
+		if ip == nil {
+			return false
+		}
+		for _, name := range names() {
+			if LikeMatch(name, p.raw, '\\') {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// resolveHostNames return host names of ip that resolve back to ip, like MySQL does.
+func resolveHostNames(resolver HostResolver, ip net.IP) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultResolveTimeout)
+	defer cancel()
+
+	names, err := resolver.LookupAddr(ctx, ip.String())
+	if err != nil {
+		return nil
+	}
+	var confirmed []string
+	for _, name := range names {
+		name = strings.TrimSuffix(name, ".")
+		if ipLikeName(name) {
+			continue
+		}
+		addrs, err := resolver.LookupHost(ctx, name)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addrIP := net.ParseIP(addr); addrIP != nil && addrIP.Equal(ip) {
+				confirmed = append(confirmed, name)
+				break
+			}
+		}
+	}
+	return confirmed
+}
+
+// ipLikeName reports whether host name starts with digits and dot, such as 10.0.0.1.example.com,
+// which could match IP patterns of accounts. MySQL rejects such names too.
+func ipLikeName(name string) bool {
+	i := 0
+	for i < len(name) && name[i] >= '0' && name[i] <= '9' {
+		i++
+	}
+	return i > 0 && i < len(name) && name[i] == '.'
+}
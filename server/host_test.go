@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/vczyh/mysql-protocol/auth"
+	"net"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	names map[string][]string
+	addrs map[string][]string
+}
+
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if names, ok := r.names[addr]; ok {
+		return names, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r.addrs[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestMemoryUserProviderKey(t *testing.T) {
+	resolver := &fakeResolver{
+		names: map[string][]string{
+			"10.0.0.1":    {"app1.example.com."},
+			"192.168.0.9": {"db.internal."},
+			// doesn't resolve back to 10.9.9.9
+			"10.9.9.9": {"spoofed.example.com."},
+		},
+		addrs: map[string][]string{
+			"app1.example.com":    {"10.0.0.1"},
+			"db.internal":         {"192.168.0.9"},
+			"spoofed.example.com": {"10.0.1.1"},
+		},
+	}
+
+	mp := NewMemoryUserProvider(WithHostResolver(resolver))
+	for _, host := range []string{"%", "", "10.%", "10.0.0.0/255.255.255.0", "10.0.0.0/16", "10.0.0.1",
+		"%.example.com", "app_.example.com", "db.internal", "localhost"} {
+		if err := mp.Create(&CreateUserRequest{User: "app", Host: host, Method: auth.MySQLNativePassword}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, host := range []string{"localhost", "172.16.%"} {
+		if err := mp.Create(&CreateUserRequest{User: "", Host: host, Method: auth.MySQLNativePassword}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mp.Create(&CreateUserRequest{User: "app", Host: "10.%", Method: auth.MySQLNativePassword}); err != ErrUserExisted {
+		t.Fatalf("expected ErrUserExisted, got %v", err)
+	}
+
+	cases := []struct {
+		user, host, key string
+	}{
+		{"app", "10.0.0.1", "app@10.0.0.1"},
+		{"app", "10.0.0.2", "app@10.0.0.0/255.255.255.0"},
+		{"app", "10.0.1.1", "app@10.0.0.0/16"},
+		{"app", "10.1.0.1", "app@10.%"},
+		{"app", "192.168.0.9", "app@db.internal"},
+		{"app", "10.9.9.9", "app@10.%"},
+		// anonymous user of more specific host is matched before app@%
+		{"app", "172.16.0.1", "@172.16.%"},
+		{"app", "172.17.0.1", "app@%"},
+		{"app", "localhost", "app@localhost"},
+		{"app", "", "app@%"},
+		{"other", "localhost", "@localhost"},
+		{"other", "172.16.0.1", "@172.16.%"},
+	}
+	for _, c := range cases {
+		key, err := mp.Key(c.user, c.host)
+		if err != nil {
+			t.Errorf("Key(%q, %q): %v", c.user, c.host, err)
+			continue
+		}
+		if key != c.key {
+			t.Errorf("Key(%q, %q) = %q, expected %q", c.user, c.host, key, c.key)
+		}
+	}
+	if _, err := mp.Key("other", "10.0.0.1"); err != ErrAccessDenied {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+
+	// host names are matched after resolving
+	mp = NewMemoryUserProvider(WithHostResolver(resolver))
+	for _, host := range []string{"%.example.com", "app_.example.com", "spoofed.example.com"} {
+		if err := mp.Create(&CreateUserRequest{User: "app", Host: host, Method: auth.MySQLNativePassword}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for host, key := range map[string]string{"10.0.0.1": "app@app_.example.com", "10.9.9.9": ""} {
+		got, _ := mp.Key("app", host)
+		if got != key {
+			t.Errorf("Key(app, %q) = %q, expected %q", host, got, key)
+		}
+	}
+
+	// resolving is disabled
+	mp = NewMemoryUserProvider(WithHostResolver(nil))
+	if err := mp.Create(&CreateUserRequest{User: "app", Host: "db.internal", Method: auth.MySQLNativePassword}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mp.Key("app", "192.168.0.9"); err != ErrAccessDenied {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+}
+
+func TestResolveHostNames(t *testing.T) {
+	resolver := &fakeResolver{
+		names: map[string][]string{
+			"10.0.0.5": {"10.0.0.5.evil.com.", "1.2.3.4.", "db5.example.com.", "3com.example.com."},
+			"6.6.6.6":  {"10.0.0.5.evil.com."},
+		},
+		addrs: map[string][]string{
+			"10.0.0.5.evil.com": {"10.0.0.5", "6.6.6.6"},
+			"1.2.3.4":           {"10.0.0.5"},
+			"db5.example.com":   {"10.0.0.5"},
+			"3com.example.com":  {"10.0.0.5"},
+		},
+	}
+	names := resolveHostNames(resolver, net.ParseIP("10.0.0.5"))
+	if len(names) != 2 || names[0] != "db5.example.com" || names[1] != "3com.example.com" {
+		t.Fatalf("unexpected host names: %v", names)
+	}
+
+	// name looking like IP doesn't match IP pattern
+	mp := NewMemoryUserProvider(WithHostResolver(resolver))
+	if err := mp.Create(&CreateUserRequest{User: "app", Host: "10.0.0.%", Method: auth.MySQLNativePassword}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mp.Key("app", "6.6.6.6"); err != ErrAccessDenied {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+}
+
+type blockingResolver struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	close(r.entered)
+	<-r.release
+	return nil, errors.New("not found")
+}
+
+func (r *blockingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, errors.New("not found")
+}
+
+func TestResolveWithoutLock(t *testing.T) {
+	resolver := &blockingResolver{entered: make(chan struct{}), release: make(chan struct{})}
+	mp := NewMemoryUserProvider(WithHostResolver(resolver))
+	for _, host := range []string{"%.example.com", "%"} {
+		if err := mp.Create(&CreateUserRequest{User: "app", Host: host, Method: auth.MySQLNativePassword}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keyDone := make(chan string)
+	go func() {
+		key, _ := mp.Key("app", "10.0.0.7")
+		keyDone <- key
+	}()
+	<-resolver.entered
+
+	// accounts can be changed while host name is being resolved
+	changed := make(chan error)
+	go func() {
+		_, err := mp.LoginFailed("app@%")
+		changed <- err
+	}()
+	select {
+	case err := <-changed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("LoginFailed is blocked by resolving host name")
+	}
+
+	close(resolver.release)
+	if key := <-keyDone; key != "app@%" {
+		t.Fatalf("unexpected key: %s", key)
+	}
+}
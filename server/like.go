@@ -0,0 +1,35 @@
+package server
+
+import "strings"
+
+// LikeMatch reports whether s matches pattern of LIKE case-insensitively, % matches any number
+// of characters, _ matches exactly one character and escape makes the following character literal.
+// Host and database patterns of accounts and grants are matched by it with escape \.
+func LikeMatch(s, pattern string, escape byte) bool {
+	str, pat, esc := []rune(strings.ToLower(s)), []rune(strings.ToLower(pattern)), rune(escape)
+	// position to retry from when the last % matches one more character
+	star, retry := -1, 0
+	i, j := 0, 0
+	for j < len(str) {
+		switch {
+		case i < len(pat) && pat[i] == '%':
+			star, retry = i, j
+			i++
+		case i+1 < len(pat) && pat[i] == esc && pat[i+1] == str[j]:
+			i += 2
+			j++
+		case i < len(pat) && (pat[i] == '_' || pat[i] == str[j]) && (pat[i] != esc || i+1 == len(pat)):
+			i++
+			j++
+		case star >= 0:
+			retry++
+			i, j = star+1, retry
+		default:
+			return false
+		}
+	}
+	for i < len(pat) && pat[i] == '%' {
+		i++
+	}
+	return i == len(pat)
+}
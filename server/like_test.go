@@ -0,0 +1,37 @@
+package server
+
+import "testing"
+
+func TestLikeMatch(t *testing.T) {
+	cases := []struct {
+		s, pattern string
+		escape     byte
+		match      bool
+	}{
+		{"", "%", '\\', true},
+		{"10.0.0.1", "10.%", '\\', true},
+		{"100.0.0.1", "10.%", '\\', false},
+		{"db.EXAMPLE.com", "%.example.com", '\\', true},
+		{"example.com", "%.example.com", '\\', false},
+		{"192.168.1.5", "192.168.1._", '\\', true},
+		{"192.168.1.50", "192.168.1._", '\\', false},
+		{"aXbYbZc", "a%b%c", '\\', true},
+		{"aXbYbZ", "a%b%c", '\\', false},
+		{"tmp_1", `tmp\_%`, '\\', true},
+		{"tmpx1", `tmp\_%`, '\\', false},
+		{"100%", `100\%`, '\\', true},
+		{"1000", `100\%`, '\\', false},
+		{"a_b", "a|_b", '|', true},
+		{"axb", "a|_b", '|', false},
+		{`a\`, `a\`, '\\', true},
+		// _ matches a character rather than a byte
+		{"日本", "_本", '\\', true},
+		{"ÄBC", "äb%", '\\', true},
+		{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaab", "%a%a%a%a%a%a%a%a%a%c", '\\', false},
+	}
+	for _, c := range cases {
+		if got := LikeMatch(c.s, c.pattern, c.escape); got != c.match {
+			t.Errorf("LikeMatch(%q, %q, %q) = %v", c.s, c.pattern, c.escape, got)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"net"
+	"time"
+)
+
+const (
+	defaultConnectTimeout   = 10 * time.Second
+	defaultMaxAllowedPacket = 64 << 20
+)
+
+// UserConnectionLimiter is optionally implemented by UserProvider to limit connections of account,
+// like max_user_connections column of mysql.user table.
+type UserConnectionLimiter interface {
+	// MaxUserConnections return max connections of account, 0 means the limit
+	// set by WithMaxUserConnections is used.
+	MaxUserConnections(key string) (int, error)
+}
+
+// acquireUserConnection counts connection of account, it returns error 1203
+// if account has max connections.
+func (s *Server) acquireUserConnection(key, user string) error {
+	limit := s.config.MaxUserConnections
+	if limiter, ok := s.config.UserProvider.(UserConnectionLimiter); ok {
+		n, err := limiter.MaxUserConnections(key)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			limit = n
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit > 0 && s.userConns[key] >= limit {
+		return myerrors.TooManyUserConnections.Build(user)
+	}
+	if s.userConns == nil {
+		s.userConns = make(map[string]int)
+	}
+	s.userConns[key]++
+	return nil
+}
+
+func (s *Server) releaseUserConnection(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userConns[key]--; s.userConns[key] <= 0 {
+		delete(s.userConns, key)
+	}
+}
+
+// idleTimeout return wait_timeout, or interactive_timeout for interactive client.
+func (s *Server) idleTimeout(conn mysql.Conn) time.Duration {
+	if conn.Capabilities()&flag.ClientInteractive != 0 {
+		return s.config.InteractiveTimeout
+	}
+	return s.config.WaitTimeout
+}
+
+// readError converts error of reading command to error sent to client before closing connection,
+// it returns nil if nothing should be sent.
+func readError(err error) error {
+	if err == mysql.ErrPacketTooLarge {
+		return myerrors.NetPacketTooLarge.Build()
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return myerrors.ClientInteractionTimeout.Build()
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	userProvider := newUserProvider(t,
+		&CreateUserRequest{User: "root", Host: "%", Method: auth.MySQLNativePassword},
+		&CreateUserRequest{User: "app", Host: "%", Method: auth.MySQLNativePassword, MaxUserConnections: 1})
+
+	serve := func(t *testing.T, opts ...Option) string {
+		srv := newTestServer(userProvider, NewDefaultHandler(), opts...)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go srv.Serve(l)
+		t.Cleanup(func() { srv.Close() })
+		return l.Addr().String()
+	}
+	connect := func(addr, user string) (*client.Conn, error) {
+		host, port, _ := net.SplitHostPort(addr)
+		p, _ := net.LookupPort("tcp", port)
+		return client.CreateConnection(client.WithHost(host), client.WithPort(p), client.WithUser(user))
+	}
+	expectCode := func(t *testing.T, err error, c code.Err) {
+		t.Helper()
+		errPkt, ok := err.(*packet.ERR)
+		if !ok {
+			t.Fatalf("expected error %d, got %v", c, err)
+		}
+		if errPkt.ErrorCode != c {
+			t.Fatalf("expected error %d, got %d", c, errPkt.ErrorCode)
+		}
+	}
+
+	t.Run("MaxConnections", func(t *testing.T) {
+		addr := serve(t, WithMaxConnections(1))
+		conn, err := connect(addr, "root")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = connect(addr, "root")
+		expectCode(t, err, code.ErrConCountError)
+
+		conn.Close()
+		// wait for server closing the connection
+		time.Sleep(100 * time.Millisecond)
+		conn, err = connect(addr, "root")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+
+	t.Run("MaxUserConnections", func(t *testing.T) {
+		addr := serve(t, WithMaxUserConnections(2))
+		for _, user := range []string{"root", "app"} {
+			limit := 2
+			if user == "app" {
+				limit = 1
+			}
+			for i := 0; i < limit; i++ {
+				conn, err := connect(addr, user)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+			}
+			_, err := connect(addr, user)
+			expectCode(t, err, code.ErrTooManyUserConnections)
+		}
+	})
+
+	t.Run("InteractiveTimeout", func(t *testing.T) {
+		// client sets CLIENT_INTERACTIVE
+		addr := serve(t, WithWaitTimeout(time.Hour), WithInteractiveTimeout(100*time.Millisecond))
+		conn, err := connect(addr, "root")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if err := conn.Ping(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(300 * time.Millisecond)
+		expectCode(t, conn.Ping(), code.ErrClientInteractionTimeout)
+	})
+
+	t.Run("MaxAllowedPacket", func(t *testing.T) {
+		addr := serve(t, WithMaxAllowedPacket(1024))
+		conn, err := connect(addr, "root")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_, err = conn.Exec("SELECT '" + strings.Repeat("a", 2048) + "'")
+		expectCode(t, err, code.ErrNetPacketTooLarge)
+	})
+
+	t.Run("ConnectTimeout", func(t *testing.T) {
+		addr := serve(t, WithConnectTimeout(100*time.Millisecond))
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		// read handshake but never respond
+		if _, err := ioutil.ReadAll(conn); err != nil && err != io.EOF {
+			t.Fatalf("expected connection closed by server, got %v", err)
+		}
+	})
+}
+
+type lengthHandler struct {
+	DefaultHandler
+}
+
+func (h *lengthHandler) Query(session *Session, query string) (interface{}, error) {
+	return &mysql.Result{AffectedRows: uint64(len(query))}, nil
+}
+
+func TestLargePacket(t *testing.T) {
+	srv := newTestServer(newUserProvider(t), new(lengthHandler), WithMaxAllowedPacket(16<<20))
+	// use TCP rather than net.Pipe, because server stops reading packet too large
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	defer srv.Close()
+	conn, err := client.CreateConnection(client.WithUser("root"),
+		client.WithDialer(func(network, address string) (net.Conn, error) {
+			return net.Dial("tcp", l.Addr().String())
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// payload of COM_QUERY includes command byte, it's split into packets of 16MB-1 bytes
+	for _, n := range []int{16<<20 - 2, 16<<20 - 1} {
+		rs, err := conn.Exec(strings.Repeat("a", n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rs.AffectedRows != uint64(n) {
+			t.Fatalf("expected query of %d bytes, got %d", n, rs.AffectedRows)
+		}
+	}
+
+	_, err = conn.Exec(strings.Repeat("a", 16<<20))
+	if errorCode(err) != code.ErrNetPacketTooLarge {
+		t.Fatalf("expected packet too large, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/client"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServeMultipleListeners(t *testing.T) {
+	userProvider := newUserProvider(t,
+		&CreateUserRequest{User: "app", Host: "localhost", Password: "socket", Method: auth.CachingSha2Password},
+		&CreateUserRequest{User: "app", Host: "127.0.0.1", Password: "tcp", Method: auth.MySQLNativePassword})
+	h := &sessionHandler{sessions: make(chan *Session, 1)}
+	srv := newTestServer(userProvider, h)
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "mysql.sock")
+	unixListener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serveErr := make(chan error, 2)
+	for _, l := range []net.Listener{tcpListener, unixListener} {
+		go func(l net.Listener) {
+			serveErr <- srv.Serve(l)
+		}(l)
+	}
+
+	tests := []struct {
+		name     string
+		opts     []client.Option
+		password string
+		key      string
+	}{
+		{
+			name: "tcp",
+			opts: []client.Option{
+				client.WithHost("127.0.0.1"),
+				client.WithPort(tcpListener.Addr().(*net.TCPAddr).Port),
+			},
+			password: "tcp",
+			key:      "app@127.0.0.1",
+		},
+		{
+			name: "unix",
+			opts: []client.Option{
+				client.WithDialer(func(network, address string) (net.Conn, error) {
+					return net.Dial("unix", socket)
+				}),
+			},
+			password: "socket",
+			key:      "app@localhost",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := append(test.opts, client.WithUser("app"), client.WithPassword(test.password))
+			conn, err := client.CreateConnection(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			if _, err := conn.Exec("USE db1"); err != nil {
+				t.Fatal(err)
+			}
+			if session := <-h.sessions; session.Key() != test.key {
+				t.Fatalf("expected key %s, got %s", test.key, session.Key())
+			}
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-serveErr; err != ErrServerClosed {
+			t.Fatalf("expected ErrServerClosed, got %v", err)
+		}
+	}
+}
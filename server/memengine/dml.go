@@ -0,0 +1,290 @@
+package memengine
+
+import (
+	"github.com/pingcap/parser/ast"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/server"
+	"sort"
+)
+
+// insert performs INSERT and REPLACE, rows are applied only if all of them succeed.
+func (h *Handler) insert(session *server.Session, stmt *ast.InsertStmt) (interface{}, error) {
+	if stmt.Select != nil {
+		return nil, myerrors.NotSupportedYet.Build("INSERT ... SELECT")
+	}
+	if len(stmt.OnDuplicate) > 0 {
+		return nil, myerrors.NotSupportedYet.Build("ON DUPLICATE KEY UPDATE")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, alias, err := h.sourceTable(session, stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := stmt.Columns
+	lists := stmt.Lists
+	if stmt.Setlist != nil {
+		columns = make([]*ast.ColumnName, len(stmt.Setlist))
+		list := make([]ast.ExprNode, len(stmt.Setlist))
+		for i, assignment := range stmt.Setlist {
+			columns[i], list[i] = assignment.Column, assignment.Expr
+		}
+		lists = [][]ast.ExprNode{list}
+	}
+
+	indexes := make([]int, len(columns))
+	for i, name := range columns {
+		if indexes[i] = t.columnIndex(name.Name.O); indexes[i] < 0 {
+			return nil, myerrors.BadField.Build(name.Name.O, "field list")
+		}
+	}
+	if len(columns) == 0 {
+		for i := range t.columns {
+			indexes = append(indexes, i)
+		}
+	}
+
+	rows := append([][]interface{}(nil), t.rows...)
+	autoIncrement := t.autoIncrement
+	var affected, lastInsertId uint64
+	base := newScope(session)
+	base.table, base.alias = t, alias
+	for n, list := range lists {
+		rowNum := n + 1
+		if len(list) != len(indexes) {
+			return nil, myerrors.WrongValueCountOnRow.Build(rowNum)
+		}
+
+		row := make([]interface{}, len(t.columns))
+		assigned := make([]bool, len(t.columns))
+		for i, c := range t.columns {
+			row[i] = c.defaultValue
+		}
+		for i, expr := range list {
+			c := t.columns[indexes[i]]
+			val := c.defaultValue
+			if _, ok := expr.(*ast.DefaultExpr); !ok {
+				if val, err = base.with(row).eval(expr); err != nil {
+					return nil, err
+				}
+				if val, err = convert(c, val, rowNum); err != nil {
+					return nil, err
+				}
+			}
+			row[indexes[i]], assigned[indexes[i]] = val, true
+		}
+
+		for i, c := range t.columns {
+			if c.autoIncrement {
+				if n, ok := row[i].(int64); ok && n != 0 {
+					if n > autoIncrement {
+						autoIncrement = n
+					}
+				} else {
+					autoIncrement++
+					row[i] = autoIncrement
+					if lastInsertId == 0 {
+						lastInsertId = uint64(autoIncrement)
+					}
+				}
+				continue
+			}
+			if row[i] == nil && c.flags&flag.NotNullFlag != 0 {
+				if assigned[i] || c.hasDefault {
+					return nil, myerrors.BadNull.Build(c.name)
+				}
+				return nil, myerrors.NoDefaultForField.Build(c.name)
+			}
+		}
+
+		k, dup := t.findDuplicate(rows, row, -1)
+		for k != nil && stmt.IsReplace {
+			rows = append(rows[:dup], rows[dup+1:]...)
+			affected++
+			k, dup = t.findDuplicate(rows, row, -1)
+		}
+		if k != nil {
+			if stmt.IgnoreErr {
+				continue
+			}
+			return nil, t.duplicateError(k, row)
+		}
+		rows = append(rows, row)
+		affected++
+	}
+
+	t.rows = rows
+	t.autoIncrement = autoIncrement
+	return &mysql.Result{AffectedRows: affected, LastInsertId: lastInsertId}, nil
+}
+
+// update performs single-table UPDATE, assignments can reference columns assigned before.
+// Affected rows are changed rows, or matched rows if client sets CLIENT_FOUND_ROWS.
+func (h *Handler) update(session *server.Session, stmt *ast.UpdateStmt) (interface{}, error) {
+	if stmt.MultipleTable {
+		return nil, myerrors.NotSupportedYet.Build("multiple-table UPDATE")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, alias, err := h.sourceTable(session, stmt.TableRefs)
+	if err != nil {
+		return nil, err
+	}
+	base := newScope(session)
+	base.table, base.alias = t, alias
+
+	indexes := make([]int, len(stmt.List))
+	for i, assignment := range stmt.List {
+		name := assignment.Column
+		if name.Table.O != "" && name.Table.O != alias {
+			return nil, myerrors.BadField.Build(name.Table.O+"."+name.Name.O, "field list")
+		}
+		if indexes[i] = t.columnIndex(name.Name.O); indexes[i] < 0 {
+			return nil, myerrors.BadField.Build(name.Name.O, "field list")
+		}
+	}
+
+	matched, err := matchRows(base, t.rows, stmt.Where, stmt.Order, stmt.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := append([][]interface{}(nil), t.rows...)
+	var changed uint64
+	for n, i := range matched {
+		row := append([]interface{}(nil), rows[i]...)
+		for j, assignment := range stmt.List {
+			c := t.columns[indexes[j]]
+			val := c.defaultValue
+			if _, ok := assignment.Expr.(*ast.DefaultExpr); !ok {
+				if val, err = base.with(row).eval(assignment.Expr); err != nil {
+					return nil, err
+				}
+				if val, err = convert(c, val, n+1); err != nil {
+					return nil, err
+				}
+			}
+			if val == nil && c.flags&flag.NotNullFlag != 0 {
+				return nil, myerrors.BadNull.Build(c.name)
+			}
+			row[indexes[j]] = val
+		}
+		if valueKey(row) == valueKey(rows[i]) {
+			continue
+		}
+		if k, _ := t.findDuplicate(rows, row, i); k != nil {
+			if stmt.IgnoreErr {
+				continue
+			}
+			return nil, t.duplicateError(k, row)
+		}
+		rows[i] = row
+		changed++
+	}
+
+	t.rows = rows
+	affected := changed
+	if session.Capabilities()&flag.ClientFoundRows != 0 {
+		affected = uint64(len(matched))
+	}
+	return &mysql.Result{AffectedRows: affected}, nil
+}
+
+// delete performs single-table DELETE.
+func (h *Handler) delete(session *server.Session, stmt *ast.DeleteStmt) (interface{}, error) {
+	if stmt.IsMultiTable {
+		return nil, myerrors.NotSupportedYet.Build("multiple-table DELETE")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, alias, err := h.sourceTable(session, stmt.TableRefs)
+	if err != nil {
+		return nil, err
+	}
+	base := newScope(session)
+	base.table, base.alias = t, alias
+
+	matched, err := matchRows(base, t.rows, stmt.Where, stmt.Order, stmt.Limit)
+	if err != nil {
+		return nil, err
+	}
+	deleted := make(map[int]bool, len(matched))
+	for _, i := range matched {
+		deleted[i] = true
+	}
+	var rows [][]interface{}
+	for i, row := range t.rows {
+		if !deleted[i] {
+			rows = append(rows, row)
+		}
+	}
+	t.rows = rows
+	return &mysql.Result{AffectedRows: uint64(len(matched))}, nil
+}
+
+// matchRows return indexes of rows matching WHERE, sorted by ORDER BY and limited by LIMIT.
+func matchRows(base *scope, rows [][]interface{}, where ast.ExprNode, order *ast.OrderByClause,
+	limit *ast.Limit) ([]int, error) {
+
+	var matched []int
+	filter := *base
+	filter.clause = "where clause"
+	for i, row := range rows {
+		if where != nil {
+			val, err := filter.with(row).eval(where)
+			if err != nil {
+				return nil, err
+			}
+			if val == nil || !toBool(val) {
+				continue
+			}
+		}
+		matched = append(matched, i)
+	}
+
+	if order != nil {
+		sorter := *base
+		sorter.clause = "order clause"
+		keys := make(map[int][]interface{}, len(matched))
+		for _, i := range matched {
+			values := make([]interface{}, len(order.Items))
+			for j, item := range order.Items {
+				val, err := sorter.with(rows[i]).eval(item.Expr)
+				if err != nil {
+					return nil, err
+				}
+				values[j] = val
+			}
+			keys[i] = values
+		}
+		sort.SliceStable(matched, func(a, b int) bool {
+			for j, item := range order.Items {
+				if c := compare(keys[matched[a]][j], keys[matched[b]][j]); c != 0 {
+					return (c < 0) != item.Desc
+				}
+			}
+			return false
+		})
+	}
+
+	if limit != nil {
+		count, offset, err := limitValues(base, limit)
+		if err != nil {
+			return nil, err
+		}
+		if offset >= len(matched) {
+			return nil, nil
+		}
+		matched = matched[offset:]
+		if count < len(matched) {
+			matched = matched[:count]
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,389 @@
+package memengine
+
+import (
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/opcode"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/server"
+	"math"
+	"strings"
+	"time"
+)
+
+// scope is context of evaluating expression on a row.
+type scope struct {
+	session *server.Session
+	table   *table
+	// alias qualifies columns, it's table name if alias isn't specified
+	alias string
+	row   []interface{}
+	// clause is used in error of unknown column
+	clause string
+	// values of aggregate functions of current group, nil if aggregate function isn't allowed
+	aggs map[*ast.AggregateFuncExpr]interface{}
+	// values of select fields referenced by alias in HAVING and ORDER BY
+	fields map[string]interface{}
+}
+
+func newScope(session *server.Session) *scope {
+	return &scope{session: session, clause: "field list"}
+}
+
+func (s *scope) with(row []interface{}) *scope {
+	ns := *s
+	ns.row = row
+	return &ns
+}
+
+func (s *scope) eval(expr ast.ExprNode) (interface{}, error) {
+	switch v := expr.(type) {
+	case ast.ValueExpr:
+		return normalize(v.GetValue()), nil
+
+	case *ast.ParenthesesExpr:
+		return s.eval(v.Expr)
+
+	case *ast.ColumnNameExpr:
+		return s.column(v.Name)
+
+	case *ast.UnaryOperationExpr:
+		val, err := s.eval(v.V)
+		if err != nil || val == nil {
+			return nil, err
+		}
+		switch v.Op {
+		case opcode.Not:
+			return boolValue(!toBool(val)), nil
+		case opcode.Minus:
+			if n, ok := val.(int64); ok {
+				return -n, nil
+			}
+			return -toFloat(val), nil
+		case opcode.Plus:
+			return val, nil
+		}
+
+	case *ast.BinaryOperationExpr:
+		return s.binary(v)
+
+	case *ast.IsNullExpr:
+		val, err := s.eval(v.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return boolValue((val == nil) != v.Not), nil
+
+	case *ast.IsTruthExpr:
+		val, err := s.eval(v.Expr)
+		if err != nil {
+			return nil, err
+		}
+		truth := val != nil && toBool(val) == (v.True != 0)
+		return boolValue(truth != v.Not), nil
+
+	case *ast.PatternLikeExpr:
+		val, err := s.eval(v.Expr)
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := s.eval(v.Pattern)
+		if err != nil || val == nil || pattern == nil {
+			return nil, err
+		}
+		return boolValue(server.LikeMatch(toString(val), toString(pattern), v.Escape) != v.Not), nil
+
+	case *ast.PatternInExpr:
+		if v.Sel != nil {
+			return nil, myerrors.NotSupportedYet.Build("subquery")
+		}
+		val, err := s.eval(v.Expr)
+		if err != nil || val == nil {
+			return nil, err
+		}
+		hasNull := false
+		for _, item := range v.List {
+			itemVal, err := s.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			if itemVal == nil {
+				hasNull = true
+			} else if compare(val, itemVal) == 0 {
+				return boolValue(!v.Not), nil
+			}
+		}
+		if hasNull {
+			return nil, nil
+		}
+		return boolValue(v.Not), nil
+
+	case *ast.BetweenExpr:
+		val, err := s.eval(v.Expr)
+		if err != nil {
+			return nil, err
+		}
+		left, err := s.eval(v.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := s.eval(v.Right)
+		if err != nil || val == nil || left == nil || right == nil {
+			return nil, err
+		}
+		between := compare(val, left) >= 0 && compare(val, right) <= 0
+		return boolValue(between != v.Not), nil
+
+	case *ast.FuncCallExpr:
+		return s.call(v)
+
+	case *ast.AggregateFuncExpr:
+		val, ok := s.aggs[v]
+		if !ok {
+			return nil, myerrors.InvalidGroupFuncUse.Build()
+		}
+		return val, nil
+
+	case *ast.SubqueryExpr, *ast.ExistsSubqueryExpr:
+		return nil, myerrors.NotSupportedYet.Build("subquery")
+	}
+	return nil, myerrors.NotSupportedYet.Build(exprText(expr))
+}
+
+// column return value of column in current row, or value of select field with the alias.
+func (s *scope) column(name *ast.ColumnName) (interface{}, error) {
+	if s.table != nil && (name.Table.O == "" || name.Table.O == s.alias) {
+		if i := s.table.columnIndex(name.Name.O); i >= 0 {
+			if s.row == nil {
+				return nil, nil
+			}
+			return s.row[i], nil
+		}
+	}
+	if name.Table.O == "" {
+		if val, ok := s.fields[name.Name.L]; ok {
+			return val, nil
+		}
+	}
+
+	column := name.Name.O
+	if name.Table.O != "" {
+		column = name.Table.O + "." + column
+	}
+	return nil, myerrors.BadField.Build(column, s.clause)
+}
+
+func (s *scope) binary(expr *ast.BinaryOperationExpr) (interface{}, error) {
+	l, err := s.eval(expr.L)
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit logic operators
+	switch expr.Op {
+	case opcode.LogicAnd:
+		if l != nil && !toBool(l) {
+			return int64(0), nil
+		}
+	case opcode.LogicOr:
+		if l != nil && toBool(l) {
+			return int64(1), nil
+		}
+	}
+
+	r, err := s.eval(expr.R)
+	if err != nil {
+		return nil, err
+	}
+
+	switch expr.Op {
+	case opcode.LogicAnd:
+		if r != nil && !toBool(r) {
+			return int64(0), nil
+		}
+		if l == nil || r == nil {
+			return nil, nil
+		}
+		return int64(1), nil
+	case opcode.LogicOr:
+		if r != nil && toBool(r) {
+			return int64(1), nil
+		}
+		if l == nil || r == nil {
+			return nil, nil
+		}
+		return int64(0), nil
+	case opcode.NullEQ:
+		return boolValue(compare(l, r) == 0), nil
+	}
+
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	switch expr.Op {
+	case opcode.LogicXor:
+		return boolValue(toBool(l) != toBool(r)), nil
+	case opcode.EQ:
+		return boolValue(compare(l, r) == 0), nil
+	case opcode.NE:
+		return boolValue(compare(l, r) != 0), nil
+	case opcode.LT:
+		return boolValue(compare(l, r) < 0), nil
+	case opcode.LE:
+		return boolValue(compare(l, r) <= 0), nil
+	case opcode.GT:
+		return boolValue(compare(l, r) > 0), nil
+	case opcode.GE:
+		return boolValue(compare(l, r) >= 0), nil
+	case opcode.Plus, opcode.Minus, opcode.Mul, opcode.Div, opcode.IntDiv, opcode.Mod:
+		return arithmetic(expr.Op, l, r), nil
+	}
+	return nil, myerrors.NotSupportedYet.Build("operator " + expr.Op.String())
+}
+
+// arithmetic computes integer result if both operands are integers, otherwise float.
+// Division by zero is NULL.
+func arithmetic(op opcode.Op, l, r interface{}) interface{} {
+	x, xok := l.(int64)
+	y, yok := r.(int64)
+	if xok && yok {
+		switch op {
+		case opcode.Plus:
+			return x + y
+		case opcode.Minus:
+			return x - y
+		case opcode.Mul:
+			return x * y
+		case opcode.IntDiv:
+			if y == 0 {
+				return nil
+			}
+			return x / y
+		case opcode.Mod:
+			if y == 0 {
+				return nil
+			}
+			return x % y
+		}
+	}
+
+	fx, fy := toFloat(l), toFloat(r)
+	switch op {
+	case opcode.Plus:
+		return fx + fy
+	case opcode.Minus:
+		return fx - fy
+	case opcode.Mul:
+		return fx * fy
+	case opcode.Div:
+		if fy == 0 {
+			return nil
+		}
+		return fx / fy
+	case opcode.IntDiv:
+		if fy == 0 {
+			return nil
+		}
+		return int64(fx / fy)
+	default:
+		if fy == 0 {
+			return nil
+		}
+		return math.Mod(fx, fy)
+	}
+}
+
+func (s *scope) call(expr *ast.FuncCallExpr) (interface{}, error) {
+	args := make([]interface{}, len(expr.Args))
+	for i, arg := range expr.Args {
+		val, err := s.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = val
+	}
+
+	argCount := func(n int) error {
+		if len(args) != n {
+			return myerrors.WrongParamCount.Build(expr.FnName.O)
+		}
+		return nil
+	}
+
+	switch expr.FnName.L {
+	case "lower", "lcase", "upper", "ucase", "length", "char_length", "character_length", "abs":
+		if err := argCount(1); err != nil || args[0] == nil {
+			return nil, err
+		}
+		switch expr.FnName.L {
+		case "lower", "lcase":
+			return strings.ToLower(toString(args[0])), nil
+		case "upper", "ucase":
+			return strings.ToUpper(toString(args[0])), nil
+		case "length":
+			return int64(len(toString(args[0]))), nil
+		case "char_length", "character_length":
+			return int64(len([]rune(toString(args[0])))), nil
+		default:
+			if n, ok := args[0].(int64); ok {
+				if n < 0 {
+					return -n, nil
+				}
+				return n, nil
+			}
+			return math.Abs(toFloat(args[0])), nil
+		}
+
+	case "concat":
+		var sb strings.Builder
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+			sb.WriteString(toString(arg))
+		}
+		return sb.String(), nil
+
+	case "coalesce":
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+
+	case "ifnull":
+		if err := argCount(2); err != nil {
+			return nil, err
+		}
+		if args[0] != nil {
+			return args[0], nil
+		}
+		return args[1], nil
+
+	case "if":
+		if err := argCount(3); err != nil {
+			return nil, err
+		}
+		if args[0] != nil && toBool(args[0]) {
+			return args[1], nil
+		}
+		return args[2], nil
+
+	case "now", "current_timestamp", "sysdate":
+		return time.Now().UTC().Truncate(time.Second), nil
+
+	case "database", "schema":
+		if s.session == nil || s.session.Database() == "" {
+			return nil, nil
+		}
+		return s.session.Database(), nil
+	}
+	return nil, myerrors.NotSupportedYet.Build("function " + expr.FnName.O)
+}
+
+func exprText(expr ast.ExprNode) string {
+	if text := expr.Text(); text != "" {
+		return text
+	}
+	return "expression"
+}
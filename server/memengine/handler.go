@@ -0,0 +1,360 @@
+// Package memengine provides an in-memory SQL engine implementing server.Handler,
+// it's intended for tests of clients and proxies rather than production.
+package memengine
+
+import (
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/test_driver"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/server"
+	"sort"
+	"sync"
+)
+
+// Handler performs CREATE DATABASE, CREATE TABLE, INSERT, UPDATE, DELETE and SELECT
+// on in-memory tables. SELECT supports single table with WHERE, GROUP BY, HAVING, ORDER BY,
+// LIMIT and COUNT SUM AVG MIN MAX.
+// Database and table names are case-sensitive, column names are case-insensitive.
+// Statements are applied immediately, BEGIN and COMMIT are accepted but ROLLBACK is rejected.
+type Handler struct {
+	server.DefaultHandler
+
+	mu        sync.RWMutex
+	databases map[string]*database
+}
+
+func NewHandler() *Handler {
+	return &Handler{databases: make(map[string]*database)}
+}
+
+func (h *Handler) Query(session *server.Session, query string) (interface{}, error) {
+	stmtNode, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return h.execute(session, stmtNode)
+}
+
+func parse(query string) (ast.StmtNode, error) {
+	stmtNode, err := parser.New().ParseOneStmt(query, "", "")
+	if err != nil {
+		return nil, myerrors.ParseError.Build(err.Error())
+	}
+	return stmtNode, nil
+}
+
+func (h *Handler) execute(session *server.Session, stmtNode ast.StmtNode) (interface{}, error) {
+	if rs, handled, err := server.SystemStatement(session, stmtNode); handled {
+		return rs, err
+	}
+
+	switch stmt := stmtNode.(type) {
+	case *ast.SelectStmt:
+		return h.selectStmt(session, stmt)
+	case *ast.InsertStmt:
+		return h.insert(session, stmt)
+	case *ast.UpdateStmt:
+		return h.update(session, stmt)
+	case *ast.DeleteStmt:
+		return h.delete(session, stmt)
+	case *ast.CreateDatabaseStmt:
+		return h.createDatabase(stmt)
+	case *ast.DropDatabaseStmt:
+		return h.dropDatabase(session, stmt)
+	case *ast.UseStmt:
+		if err := h.InitDB(session, stmt.DBName); err != nil {
+			return nil, err
+		}
+		session.SetDatabase(stmt.DBName)
+		return &mysql.Result{}, nil
+	case *ast.CreateTableStmt:
+		return h.createTable(session, stmt)
+	case *ast.DropTableStmt:
+		return h.dropTable(session, stmt)
+	case *ast.TruncateTableStmt:
+		return h.truncateTable(session, stmt)
+	case *ast.ShowStmt:
+		return h.show(session, stmt)
+	case *ast.BeginStmt, *ast.CommitStmt:
+		// statements are applied immediately, transaction isn't supported
+		return &mysql.Result{}, nil
+	case *ast.RollbackStmt:
+		// changes can't be undone, don't pretend to roll back
+		return nil, myerrors.NotSupportedYet.Build("ROLLBACK")
+	}
+	return nil, myerrors.NotSupportedYet.Build(stmtNode.Text())
+}
+
+// InitDB rejects unknown database.
+func (h *Handler) InitDB(session *server.Session, database string) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if _, ok := h.databases[database]; !ok {
+		return myerrors.BadDb.Build(database)
+	}
+	return nil
+}
+
+// FieldList return columns of table in current database.
+func (h *Handler) FieldList(session *server.Session, table, wildcard string) ([]mysql.Column, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	t, err := h.table(session, &ast.TableName{Name: model.NewCIStr(table)})
+	if err != nil {
+		return nil, err
+	}
+	var columns []mysql.Column
+	for _, c := range t.columns {
+		if wildcard == "" || server.LikeMatch(c.name, wildcard, '\\') {
+			columns = append(columns, c.mysqlColumn(t.database, t.name, t.name, c.name))
+		}
+	}
+	return columns, nil
+}
+
+// Prepare counts parameter markers, result set columns are sent when executing.
+func (h *Handler) Prepare(session *server.Session, query string) (int, []mysql.Column, error) {
+	stmtNode, err := parse(query)
+	if err != nil {
+		return 0, nil, err
+	}
+	return len(paramMarkers(stmtNode)), nil, nil
+}
+
+// Execute binds args to parameter markers and performs the statement.
+func (h *Handler) Execute(session *server.Session, stmt *server.Stmt, args []interface{}) (interface{}, error) {
+	stmtNode, err := parse(stmt.Query)
+	if err != nil {
+		return nil, err
+	}
+	markers := paramMarkers(stmtNode)
+	if len(markers) != len(args) {
+		return nil, myerrors.WrongArguments.Build("mysqld_stmt_execute")
+	}
+	for i, marker := range markers {
+		arg := args[i]
+		if b, ok := arg.([]byte); ok {
+			arg = string(b)
+		}
+		marker.SetValue(arg)
+	}
+	return h.execute(session, stmtNode)
+}
+
+type paramMarkerCollector struct {
+	markers []*test_driver.ParamMarkerExpr
+}
+
+func (c *paramMarkerCollector) Enter(n ast.Node) (ast.Node, bool) {
+	if v, ok := n.(*test_driver.ParamMarkerExpr); ok {
+		c.markers = append(c.markers, v)
+	}
+	return n, false
+}
+
+func (c *paramMarkerCollector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// paramMarkers return parameter markers of statement by their position in query.
+func paramMarkers(stmtNode ast.StmtNode) []*test_driver.ParamMarkerExpr {
+	c := new(paramMarkerCollector)
+	stmtNode.Accept(c)
+	sort.Slice(c.markers, func(i, j int) bool {
+		return c.markers[i].Order < c.markers[j].Order
+	})
+	return c.markers
+}
+
+// table return table of current database if schema isn't specified, h.mu must be held.
+func (h *Handler) table(session *server.Session, name *ast.TableName) (*table, error) {
+	schema := name.Schema.O
+	if schema == "" {
+		schema = session.Database()
+	}
+	if schema == "" {
+		return nil, myerrors.NoDb.Build()
+	}
+	db, ok := h.databases[schema]
+	if !ok {
+		return nil, myerrors.NoSuchTable.Build(schema, name.Name.O)
+	}
+	t, ok := db.tables[name.Name.O]
+	if !ok {
+		return nil, myerrors.NoSuchTable.Build(schema, name.Name.O)
+	}
+	return t, nil
+}
+
+// database return database of table name, h.mu must be held.
+func (h *Handler) database(session *server.Session, name *ast.TableName) (*database, error) {
+	schema := name.Schema.O
+	if schema == "" {
+		schema = session.Database()
+	}
+	if schema == "" {
+		return nil, myerrors.NoDb.Build()
+	}
+	db, ok := h.databases[schema]
+	if !ok {
+		return nil, myerrors.BadDb.Build(schema)
+	}
+	return db, nil
+}
+
+func (h *Handler) createDatabase(stmt *ast.CreateDatabaseStmt) (interface{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.databases[stmt.Name]; ok {
+		if stmt.IfNotExists {
+			return &mysql.Result{}, nil
+		}
+		return nil, myerrors.DbCreateExists.Build(stmt.Name)
+	}
+	h.databases[stmt.Name] = &database{name: stmt.Name, tables: make(map[string]*table)}
+	return &mysql.Result{AffectedRows: 1}, nil
+}
+
+func (h *Handler) dropDatabase(session *server.Session, stmt *ast.DropDatabaseStmt) (interface{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	db, ok := h.databases[stmt.Name]
+	if !ok {
+		if stmt.IfExists {
+			return &mysql.Result{}, nil
+		}
+		return nil, myerrors.DbDropExists.Build(stmt.Name)
+	}
+	delete(h.databases, stmt.Name)
+	if session.Database() == stmt.Name {
+		session.SetDatabase("")
+	}
+	return &mysql.Result{AffectedRows: uint64(len(db.tables))}, nil
+}
+
+func (h *Handler) createTable(session *server.Session, stmt *ast.CreateTableStmt) (interface{}, error) {
+	if stmt.Select != nil {
+		return nil, myerrors.NotSupportedYet.Build("CREATE TABLE ... SELECT")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	db, err := h.database(session, stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	name := stmt.Table.Name.O
+	if _, ok := db.tables[name]; ok {
+		if stmt.IfNotExists {
+			return &mysql.Result{}, nil
+		}
+		return nil, myerrors.TableExists.Build(name)
+	}
+
+	var t *table
+	if stmt.ReferTable != nil {
+		refer, err := h.table(session, stmt.ReferTable)
+		if err != nil {
+			return nil, err
+		}
+		t = refer.clone(db.name, name)
+	} else if t, err = newTable(db.name, stmt); err != nil {
+		return nil, err
+	}
+	db.tables[name] = t
+	return &mysql.Result{}, nil
+}
+
+func (h *Handler) dropTable(session *server.Session, stmt *ast.DropTableStmt) (interface{}, error) {
+	if stmt.IsView {
+		return nil, myerrors.NotSupportedYet.Build("VIEW")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// check all tables before dropping any of them
+	var tables []*table
+	for _, name := range stmt.Tables {
+		t, err := h.table(session, name)
+		if err == nil {
+			tables = append(tables, t)
+			continue
+		}
+		if name.Schema.O == "" && session.Database() == "" {
+			return nil, err
+		}
+		if !stmt.IfExists {
+			return nil, myerrors.BadTable.Build(name.Name.O)
+		}
+	}
+	for _, t := range tables {
+		delete(h.databases[t.database].tables, t.name)
+	}
+	return &mysql.Result{}, nil
+}
+
+func (h *Handler) truncateTable(session *server.Session, stmt *ast.TruncateTableStmt) (interface{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, err := h.table(session, stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	t.rows = nil
+	t.autoIncrement = 0
+	return &mysql.Result{}, nil
+}
+
+func (h *Handler) show(session *server.Session, stmt *ast.ShowStmt) (interface{}, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var column string
+	var names []string
+	switch stmt.Tp {
+	case ast.ShowDatabases:
+		column = "Database"
+		for name := range h.databases {
+			names = append(names, name)
+		}
+	case ast.ShowTables:
+		schema := stmt.DBName
+		if schema == "" {
+			schema = session.Database()
+		}
+		if schema == "" {
+			return nil, myerrors.NoDb.Build()
+		}
+		db, ok := h.databases[schema]
+		if !ok {
+			return nil, myerrors.BadDb.Build(schema)
+		}
+		column = "Tables_in_" + schema
+		for name := range db.tables {
+			names = append(names, name)
+		}
+	default:
+		return nil, myerrors.NotSupportedYet.Build(stmt.Text())
+	}
+	sort.Strings(names)
+
+	var rows []mysql.Row
+	for _, name := range names {
+		if stmt.Pattern != nil {
+			pattern, err := newScope(session).eval(stmt.Pattern.Pattern)
+			if err != nil {
+				return nil, err
+			}
+			if !server.LikeMatch(name, toString(pattern), stmt.Pattern.Escape) {
+				continue
+			}
+		}
+		rows = append(rows, mysql.Row{mysql.NewColumnValue(name)})
+	}
+	columns := []mysql.Column{{Name: column, Type: flag.MySQLTypeVarString, Length: 256}}
+	return server.NewResultSet(columns, rows)
+}
@@ -0,0 +1,228 @@
+package memengine
+
+import (
+	"fmt"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	srv := servertest.NewServer(NewHandler(), nil)
+	conn, err := client.CreateConnection(client.WithUser("root"), client.WithDialer(servertest.Dialer(srv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	exec := func(query string) mysql.Result {
+		t.Helper()
+		res, err := conn.Exec(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return res
+	}
+	read := func(rows *client.Rows) [][]string {
+		t.Helper()
+		var res [][]string
+		for {
+			row, err := rows.Next()
+			if err == io.EOF {
+				return res
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values []string
+			for _, v := range row {
+				if v.IsNull() {
+					values = append(values, "NULL")
+				} else {
+					values = append(values, fmt.Sprintf("%s", v.Value()))
+				}
+			}
+			res = append(res, values)
+		}
+	}
+	query := func(query string) [][]string {
+		t.Helper()
+		rows, err := conn.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return read(rows)
+	}
+	expect := func(q string, want [][]string) {
+		t.Helper()
+		if res := query(q); !reflect.DeepEqual(res, want) {
+			t.Fatalf("%s: expected %v, got %v", q, want, res)
+		}
+	}
+	expectErr := func(query string, c code.Err) {
+		t.Helper()
+		_, err := conn.Exec(query)
+		errPkt, ok := err.(*packet.ERR)
+		if !ok || errPkt.ErrorCode != c {
+			t.Fatalf("%s: expected error %d, got %v", query, c, err)
+		}
+	}
+
+	t.Run("DDL", func(t *testing.T) {
+		expectErr("CREATE TABLE t (id INT)", code.ErrNoDbError)
+		exec("CREATE DATABASE test")
+		expectErr("CREATE DATABASE test", code.ErrDbCreateExists)
+		expectErr("USE unknown", code.ErrBadDbError)
+		exec("USE test")
+		exec(`CREATE TABLE users (
+			id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(32) NOT NULL,
+			age INT,
+			score DECIMAL(5,2) DEFAULT 0,
+			created DATETIME,
+			UNIQUE KEY uk_name (name))`)
+		expectErr("CREATE TABLE users (id INT)", code.ErrTableExistsError)
+		expectErr("CREATE TABLE t (id INT, ID INT)", code.ErrDupFieldName)
+		exec("CREATE TABLE tmp LIKE users")
+		expect("SHOW TABLES", [][]string{{"tmp"}, {"users"}})
+		exec("DROP TABLE tmp")
+		expectErr("DROP TABLE tmp", code.ErrBadTableError)
+		exec("DROP TABLE IF EXISTS tmp")
+	})
+
+	t.Run("Insert", func(t *testing.T) {
+		res := exec("INSERT INTO users (name, age, score, created) VALUES " +
+			"('alice', 30, 90.5, '2021-01-02 03:04:05'), ('bob', 25, 80, NULL), ('carol', NULL, DEFAULT, NULL)")
+		if res.AffectedRows != 3 || res.LastInsertId != 1 {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+		res = exec("INSERT INTO users SET name = 'dave', age = 25")
+		if res.LastInsertId != 4 {
+			t.Fatalf("expected last insert id 4, got %d", res.LastInsertId)
+		}
+		expectErr("INSERT INTO users (name) VALUES ('alice')", code.ErrDupEntry)
+		expectErr("INSERT INTO users (name) VALUES (NULL)", code.ErrBadNullError)
+		expectErr("INSERT INTO users (age) VALUES (1)", code.ErrNoDefaultForField)
+		expectErr("INSERT INTO users (name, age) VALUES ('eve')", code.ErrWrongValueCountOnRow)
+		expectErr("INSERT INTO users (name, age) VALUES ('eve', 'abc')", code.ErrTruncatedWrongValue)
+		expectErr("INSERT INTO users (nick) VALUES ('eve')", code.ErrBadFieldError)
+		expectErr("INSERT INTO unknown VALUES (1)", code.ErrNoSuchTable)
+		if res = exec("INSERT IGNORE INTO users (name) VALUES ('alice')"); res.AffectedRows != 0 {
+			t.Fatalf("expected duplicate ignored, got %+v", res)
+		}
+		expect("SELECT COUNT(*) FROM users", [][]string{{"4"}})
+	})
+
+	t.Run("Select", func(t *testing.T) {
+		expect("SELECT id, name FROM users WHERE age > 24 AND name LIKE '%o%' ORDER BY id",
+			[][]string{{"2", "bob"}})
+		expect("SELECT name, score FROM users WHERE age IS NULL", [][]string{{"carol", "0.00"}})
+		expect("SELECT name FROM users ORDER BY age DESC, name LIMIT 1, 2", [][]string{{"bob"}, {"dave"}})
+		expect("SELECT u.name AS n FROM users u WHERE u.id IN (1, 3) ORDER BY n DESC",
+			[][]string{{"carol"}, {"alice"}})
+		expect("SELECT created FROM users WHERE id = 1", [][]string{{"2021-01-02 03:04:05.000000"}})
+		expect("SELECT COUNT(*), COUNT(age), SUM(age), MIN(name), MAX(age) FROM users",
+			[][]string{{"4", "3", "80", "alice", "30"}})
+		expect("SELECT age, COUNT(*) AS c FROM users GROUP BY age HAVING c > 1", [][]string{{"25", "2"}})
+		expect("SELECT DISTINCT age FROM users WHERE age IS NOT NULL ORDER BY 1", [][]string{{"25"}, {"30"}})
+		expect("SELECT 1 + 2, CONCAT('a', 'b')", [][]string{{"3", "ab"}})
+		expect("SELECT @@version_comment LIMIT 1", [][]string{{"mysql-protocol"}})
+
+		expectErr("SELECT nick FROM users", code.ErrBadFieldError)
+		expectErr("SELECT id FROM users WHERE COUNT(*) > 1", code.ErrInvalidGroupFuncUse)
+		expectErr("SELECT * FROM a JOIN b", code.ErrNotSupportedYet)
+		expectErr("SELEC 1", code.ErrParseError)
+
+		rows, err := conn.Query("SELECT id, name, score FROM users LIMIT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		read(rows)
+		columns := rows.Columns()
+		if columns[0].Table != "users" || columns[1].Name != "name" || columns[2].Decimals != 2 {
+			t.Fatalf("unexpected columns: %v", columns)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		if res := exec("UPDATE users SET age = age + 1 WHERE age = 25"); res.AffectedRows != 2 {
+			t.Fatalf("expected 2 rows updated, got %d", res.AffectedRows)
+		}
+		if res := exec("UPDATE users SET age = 26 WHERE age = 26"); res.AffectedRows != 0 {
+			t.Fatalf("expected no rows changed, got %d", res.AffectedRows)
+		}
+		exec("UPDATE users SET age = 40, score = age / 2 ORDER BY id DESC LIMIT 1")
+		expect("SELECT name, age, score FROM users WHERE id = 4", [][]string{{"dave", "40", "20.00"}})
+		expectErr("UPDATE users SET name = 'alice' WHERE id = 2", code.ErrDupEntry)
+		expectErr("UPDATE users SET name = NULL", code.ErrBadNullError)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if res := exec("DELETE FROM users WHERE age IS NULL OR age > 35"); res.AffectedRows != 2 {
+			t.Fatalf("expected 2 rows deleted, got %d", res.AffectedRows)
+		}
+		expect("SELECT name FROM users ORDER BY name", [][]string{{"alice"}, {"bob"}})
+		exec("REPLACE INTO users (id, name) VALUES (1, 'bob')")
+		expect("SELECT id, name, age FROM users", [][]string{{"1", "bob", "NULL"}})
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		exec("BEGIN")
+		exec("DELETE FROM users")
+		expectErr("ROLLBACK", code.ErrNotSupportedYet)
+		exec("COMMIT")
+		expect("SELECT name FROM users", nil)
+		exec("INSERT INTO users (id, name) VALUES (1, 'bob')")
+	})
+
+	t.Run("Prepare", func(t *testing.T) {
+		stmt, err := conn.Prepare("INSERT INTO users (name, age) VALUES (?, ?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stmt.ParamCount() != 2 {
+			t.Fatalf("expected 2 params, got %d", stmt.ParamCount())
+		}
+		for i, name := range []string{"x", "y", "z"} {
+			if _, err := stmt.Exec(name, i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stmt.Close()
+
+		stmt, err = conn.Prepare("SELECT name, age FROM users WHERE age >= ? ORDER BY age LIMIT ?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		rows, err := stmt.Query(1, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for {
+			row, err := rows.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, fmt.Sprintf("%s", row[0].Value()))
+		}
+		if !reflect.DeepEqual(names, []string{"y", "z"}) {
+			t.Fatalf("unexpected rows: %v", names)
+		}
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		exec("DROP DATABASE test")
+		expectErr("SELECT * FROM users", code.ErrNoDbError)
+		expectErr("DROP DATABASE test", code.ErrDbDropExists)
+	})
+}
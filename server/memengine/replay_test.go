@@ -0,0 +1,155 @@
+package memengine
+
+import (
+	"flag"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/mysqltest"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+var recordSessions = flag.Bool("record", false, "record sessions into testdata from in-process server")
+
+// replay replays testdata/<test name>.json as fake client against a new server,
+// so the server must respond exactly as recorded, run isn't called.
+//
+// With -record, run performs the session by client and it's saved, the checked-in sessions
+// are recorded from this package, they catch changes of responses rather than differences from MySQL.
+func replay(t *testing.T, run func(t *testing.T, conn *client.Conn)) {
+	t.Helper()
+	name := filepath.Join("testdata", t.Name()+".json")
+
+	if *recordSessions {
+		srv := servertest.NewServer(NewHandler(), nil)
+		var rec *mysqltest.Conn
+		conn, err := client.CreateConnection(client.WithUser("root"), client.WithDialer(func(network, address string) (net.Conn, error) {
+			conn, err := servertest.Dialer(srv)(network, address)
+			if err != nil {
+				return nil, err
+			}
+			rec = mysqltest.Record(conn, mysqltest.ClientToServer)
+			return rec, nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		run(t, conn)
+		if err := conn.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.Session().WriteFile(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	session, err := mysqltest.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := mysqltest.NewPlayer(session)
+	srv := servertest.NewServer(NewHandler(), nil)
+	go srv.ServeConn(p.FakeClient())
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayStatements(t *testing.T) {
+	replay(t, func(t *testing.T, conn *client.Conn) {
+		for _, query := range []string{
+			"CREATE DATABASE app",
+			"USE app",
+			"CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(16), age INT)",
+			"INSERT INTO users VALUES (1, 'a', 20), (2, 'b', NULL), (3, 'c', 30)",
+			"UPDATE users SET age = age + 1 WHERE id = 1",
+			"DELETE FROM users WHERE id = 3",
+		} {
+			if _, err := conn.Exec(query); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+		}
+		readAll(t, conn, "SELECT id, name, age FROM users ORDER BY id")
+		readAll(t, conn, "SELECT COUNT(*), MAX(age) FROM users WHERE name LIKE '%'")
+	})
+}
+
+func TestReplayErrors(t *testing.T) {
+	replay(t, func(t *testing.T, conn *client.Conn) {
+		for _, query := range []string{
+			"SELECT * FROM missing.users",
+			"CREATE DATABASE app",
+			"CREATE DATABASE app",
+			"CREATE TABLE app.users (id INT PRIMARY KEY)",
+			"INSERT INTO app.users VALUES (1)",
+			"INSERT INTO app.users VALUES (1)",
+			"SELECT unknown FROM app.users",
+		} {
+			conn.Exec(query)
+		}
+		if err := conn.Ping(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestReplayPreparedStatement(t *testing.T) {
+	replay(t, func(t *testing.T, conn *client.Conn) {
+		for _, query := range []string{
+			"CREATE DATABASE app",
+			"CREATE TABLE app.users (id INT PRIMARY KEY, name VARCHAR(16))",
+		} {
+			if _, err := conn.Exec(query); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+		}
+		insert, err := conn.Prepare("INSERT INTO app.users VALUES (?, ?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, name := range []interface{}{"a", nil} {
+			if _, err := insert.Exec(int64(i+1), name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := insert.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		query, err := conn.Prepare("SELECT id, name FROM app.users WHERE id >= ?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := query.Query(int64(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			if _, err := rows.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := query.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func readAll(t *testing.T, conn *client.Conn, query string) {
+	t.Helper()
+	rows, err := conn.Query(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	for {
+		if _, err := rows.Next(); err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package memengine
+
+import (
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/opcode"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/server"
+	"sort"
+	"strings"
+	"time"
+)
+
+// output is a field of select result.
+type output struct {
+	name string
+	expr ast.ExprNode
+	// column of table if field is column reference, nil otherwise
+	column *column
+	index  int
+}
+
+// record is a result row before ordering and limiting.
+type record struct {
+	scope  *scope
+	values []interface{}
+}
+
+func (h *Handler) selectStmt(session *server.Session, stmt *ast.SelectStmt) (interface{}, error) {
+	if stmt.SelectIntoOpt != nil {
+		return nil, myerrors.NotSupportedYet.Build("SELECT INTO")
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	base := newScope(session)
+	rows := [][]interface{}{nil}
+	if stmt.From != nil {
+		t, alias, err := h.sourceTable(session, stmt.From)
+		if err != nil {
+			return nil, err
+		}
+		base.table, base.alias, rows = t, alias, t.rows
+	}
+
+	outputs, err := selectOutputs(base, stmt.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.Where != nil {
+		where := *base
+		where.clause = "where clause"
+		if rows, err = filter(&where, rows, stmt.Where); err != nil {
+			return nil, err
+		}
+	}
+
+	aggs := collectAggregates(stmt)
+	var records []*record
+	if len(aggs) > 0 || stmt.GroupBy != nil {
+		records, err = groupRecords(base, rows, outputs, aggs, stmt.GroupBy)
+	} else {
+		records, err = plainRecords(base, rows, outputs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.Having != nil {
+		kept := records[:0]
+		for _, r := range records {
+			having := *r.scope
+			having.clause = "having clause"
+			val, err := having.eval(stmt.Having.Expr)
+			if err != nil {
+				return nil, err
+			}
+			if val != nil && toBool(val) {
+				kept = append(kept, r)
+			}
+		}
+		records = kept
+	}
+
+	if stmt.Distinct {
+		seen := make(map[string]bool)
+		kept := records[:0]
+		for _, r := range records {
+			if k := valueKey(r.values); !seen[k] {
+				seen[k] = true
+				kept = append(kept, r)
+			}
+		}
+		records = kept
+	}
+
+	if stmt.OrderBy != nil {
+		if err := sortRecords(records, outputs, stmt.OrderBy.Items); err != nil {
+			return nil, err
+		}
+	}
+	if records, err = limitRecords(base, records, stmt.Limit); err != nil {
+		return nil, err
+	}
+
+	columns := make([]mysql.Column, len(outputs))
+	for i, o := range outputs {
+		if o.column != nil {
+			columns[i] = o.column.mysqlColumn(base.table.database, base.table.name, base.alias, o.name)
+		} else {
+			columns[i] = expressionColumn(o.name, records, i)
+		}
+	}
+	resultRows := make([]mysql.Row, len(records))
+	for i, r := range records {
+		row := make(mysql.Row, len(r.values))
+		for j, val := range r.values {
+			row[j] = mysql.NewColumnValue(val)
+		}
+		resultRows[i] = row
+	}
+	return server.NewResultSet(columns, resultRows)
+}
+
+// sourceTable return the only table of FROM clause, joins are not supported.
+func (h *Handler) sourceTable(session *server.Session, from *ast.TableRefsClause) (*table, string, error) {
+	join := from.TableRefs
+	if join == nil || join.Right != nil {
+		return nil, "", myerrors.NotSupportedYet.Build("JOIN")
+	}
+	ts, ok := join.Left.(*ast.TableSource)
+	if !ok {
+		return nil, "", myerrors.NotSupportedYet.Build("JOIN")
+	}
+	tn, ok := ts.Source.(*ast.TableName)
+	if !ok {
+		return nil, "", myerrors.NotSupportedYet.Build("derived table")
+	}
+	t, err := h.table(session, tn)
+	if err != nil {
+		return nil, "", err
+	}
+	alias := ts.AsName.O
+	if alias == "" {
+		alias = t.name
+	}
+	return t, alias, nil
+}
+
+func selectOutputs(s *scope, fields *ast.FieldList) ([]*output, error) {
+	var outputs []*output
+	for _, field := range fields.Fields {
+		if field.WildCard != nil {
+			if s.table == nil {
+				return nil, myerrors.NoTablesUsed.Build()
+			}
+			if name := field.WildCard.Table.O; name != "" && name != s.alias {
+				return nil, myerrors.BadTable.Build(name)
+			}
+			for i, c := range s.table.columns {
+				outputs = append(outputs, &output{name: c.name, column: c, index: i})
+			}
+			continue
+		}
+
+		o := &output{name: field.AsName.O, expr: field.Expr, index: -1}
+		if cn, ok := field.Expr.(*ast.ColumnNameExpr); ok && s.table != nil {
+			if cn.Name.Table.O == "" || cn.Name.Table.O == s.alias {
+				if i := s.table.columnIndex(cn.Name.Name.O); i >= 0 {
+					o.column, o.index = s.table.columns[i], i
+					if o.name == "" {
+						o.name = s.table.columns[i].name
+					}
+				}
+			}
+		}
+		if o.name == "" {
+			o.name = exprText(field.Expr)
+		}
+		outputs = append(outputs, o)
+	}
+	return outputs, nil
+}
+
+func filter(s *scope, rows [][]interface{}, where ast.ExprNode) ([][]interface{}, error) {
+	var matched [][]interface{}
+	for _, row := range rows {
+		val, err := s.with(row).eval(where)
+		if err != nil {
+			return nil, err
+		}
+		if val != nil && toBool(val) {
+			matched = append(matched, row)
+		}
+	}
+	return matched, nil
+}
+
+func (o *output) eval(s *scope) (interface{}, error) {
+	if o.index >= 0 {
+		if s.row == nil {
+			return nil, nil
+		}
+		return s.row[o.index], nil
+	}
+	return s.eval(o.expr)
+}
+
+func evalOutputs(s *scope, outputs []*output) ([]interface{}, error) {
+	values := make([]interface{}, len(outputs))
+	s.fields = make(map[string]interface{}, len(outputs))
+	for i, o := range outputs {
+		val, err := o.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+		s.fields[strings.ToLower(o.name)] = val
+	}
+	return values, nil
+}
+
+func plainRecords(base *scope, rows [][]interface{}, outputs []*output) ([]*record, error) {
+	records := make([]*record, 0, len(rows))
+	for _, row := range rows {
+		s := base.with(row)
+		values, err := evalOutputs(s, outputs)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &record{scope: s, values: values})
+	}
+	return records, nil
+}
+
+// groupRecords groups rows by GROUP BY, all rows are a group without GROUP BY.
+// Non-aggregated fields are values of the first row in the group.
+func groupRecords(base *scope, rows [][]interface{}, outputs []*output, aggs []*ast.AggregateFuncExpr,
+	groupBy *ast.GroupByClause) ([]*record, error) {
+
+	var keys []string
+	groups := make(map[string][][]interface{})
+	if groupBy == nil {
+		keys = []string{""}
+		groups[""] = rows
+	} else {
+		group := *base
+		group.clause = "group statement"
+		for _, row := range rows {
+			s := group.with(row)
+			values := make([]interface{}, len(groupBy.Items))
+			for i, item := range groupBy.Items {
+				val, err := orderValue(s, outputs, nil, item.Expr)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = val
+			}
+			k := valueKey(values)
+			if _, ok := groups[k]; !ok {
+				keys = append(keys, k)
+			}
+			groups[k] = append(groups[k], row)
+		}
+	}
+
+	records := make([]*record, 0, len(keys))
+	for _, k := range keys {
+		groupRows := groups[k]
+		s := base.with(nil)
+		if len(groupRows) > 0 {
+			s.row = groupRows[0]
+		}
+		s.aggs = make(map[*ast.AggregateFuncExpr]interface{}, len(aggs))
+		for _, agg := range aggs {
+			val, err := aggregate(base, agg, groupRows)
+			if err != nil {
+				return nil, err
+			}
+			s.aggs[agg] = val
+		}
+		values, err := evalOutputs(s, outputs)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &record{scope: s, values: values})
+	}
+	return records, nil
+}
+
+type aggregateCollector struct {
+	aggs []*ast.AggregateFuncExpr
+}
+
+func (c *aggregateCollector) Enter(n ast.Node) (ast.Node, bool) {
+	switch v := n.(type) {
+	case *ast.AggregateFuncExpr:
+		c.aggs = append(c.aggs, v)
+		return n, true
+	case *ast.SubqueryExpr:
+		return n, true
+	}
+	return n, false
+}
+
+func (c *aggregateCollector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// collectAggregates return aggregate functions in fields, HAVING and ORDER BY.
+func collectAggregates(stmt *ast.SelectStmt) []*ast.AggregateFuncExpr {
+	c := new(aggregateCollector)
+	for _, field := range stmt.Fields.Fields {
+		if field.Expr != nil {
+			field.Expr.Accept(c)
+		}
+	}
+	if stmt.Having != nil {
+		stmt.Having.Expr.Accept(c)
+	}
+	if stmt.OrderBy != nil {
+		for _, item := range stmt.OrderBy.Items {
+			item.Expr.Accept(c)
+		}
+	}
+	return c.aggs
+}
+
+// aggregate computes COUNT SUM AVG MIN MAX of rows, NULL values are ignored.
+func aggregate(base *scope, agg *ast.AggregateFuncExpr, rows [][]interface{}) (interface{}, error) {
+	if len(agg.Args) != 1 {
+		return nil, myerrors.NotSupportedYet.Build(strings.ToUpper(agg.F) + " with multiple arguments")
+	}
+
+	var values []interface{}
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		val, err := base.with(row).eval(agg.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		if val == nil {
+			continue
+		}
+		if agg.Distinct {
+			k := valueKey([]interface{}{val})
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		values = append(values, val)
+	}
+
+	fn := strings.ToLower(agg.F)
+	switch fn {
+	case ast.AggFuncCount:
+		return int64(len(values)), nil
+
+	case ast.AggFuncSum, ast.AggFuncAvg:
+		if len(values) == 0 {
+			return nil, nil
+		}
+		var sum interface{} = int64(0)
+		for _, val := range values {
+			if _, ok := val.(string); ok {
+				val = toFloat(val)
+			}
+			sum = arithmetic(opcode.Plus, sum, val)
+		}
+		if fn == ast.AggFuncAvg {
+			return toFloat(sum) / float64(len(values)), nil
+		}
+		return sum, nil
+
+	case ast.AggFuncMin, ast.AggFuncMax:
+		var result interface{}
+		for _, val := range values {
+			c := compare(val, result)
+			if result == nil || fn == ast.AggFuncMin && c < 0 || fn == ast.AggFuncMax && c > 0 {
+				result = val
+			}
+		}
+		return result, nil
+	}
+	return nil, myerrors.NotSupportedYet.Build("function " + agg.F)
+}
+
+// orderValue return value of ORDER BY or GROUP BY item, it can be position or alias of field.
+func orderValue(s *scope, outputs []*output, values []interface{}, expr ast.ExprNode) (interface{}, error) {
+	switch v := expr.(type) {
+	case *ast.PositionExpr:
+		if v.P != nil || v.N < 1 || v.N > len(outputs) {
+			return nil, myerrors.BadField.Build(exprText(expr), s.clause)
+		}
+		if values != nil {
+			return values[v.N-1], nil
+		}
+		return outputs[v.N-1].eval(s)
+	case *ast.ColumnNameExpr:
+		if v.Name.Table.O == "" {
+			for i, o := range outputs {
+				if o.expr != nil && o.column == nil && strings.EqualFold(o.name, v.Name.Name.O) {
+					if values != nil {
+						return values[i], nil
+					}
+					return o.eval(s)
+				}
+			}
+		}
+	}
+	return s.eval(expr)
+}
+
+func sortRecords(records []*record, outputs []*output, items []*ast.ByItem) error {
+	keys := make([][]interface{}, len(records))
+	for i, r := range records {
+		order := *r.scope
+		order.clause = "order clause"
+		keys[i] = make([]interface{}, len(items))
+		for j, item := range items {
+			val, err := orderValue(&order, outputs, r.values, item.Expr)
+			if err != nil {
+				return err
+			}
+			keys[i][j] = val
+		}
+	}
+
+	index := make([]int, len(records))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(a, b int) bool {
+		for j, item := range items {
+			c := compare(keys[index[a]][j], keys[index[b]][j])
+			if c == 0 {
+				continue
+			}
+			return (c < 0) != item.Desc
+		}
+		return false
+	})
+
+	sorted := make([]*record, len(records))
+	for i, j := range index {
+		sorted[i] = records[j]
+	}
+	copy(records, sorted)
+	return nil
+}
+
+func limitRecords(s *scope, records []*record, limit *ast.Limit) ([]*record, error) {
+	if limit == nil {
+		return records, nil
+	}
+	count, offset, err := limitValues(s, limit)
+	if err != nil {
+		return nil, err
+	}
+	if offset >= len(records) {
+		return nil, nil
+	}
+	records = records[offset:]
+	if count < len(records) {
+		records = records[:count]
+	}
+	return records, nil
+}
+
+func limitValues(s *scope, limit *ast.Limit) (count, offset int, err error) {
+	value := func(expr ast.ExprNode) (int, error) {
+		val, err := s.eval(expr)
+		if err != nil {
+			return 0, err
+		}
+		n, ok := val.(int64)
+		if !ok || n < 0 {
+			return 0, myerrors.WrongArguments.Build("LIMIT")
+		}
+		return int(n), nil
+	}
+	if count, err = value(limit.Count); err != nil {
+		return 0, 0, err
+	}
+	if limit.Offset != nil {
+		if offset, err = value(limit.Offset); err != nil {
+			return 0, 0, err
+		}
+	}
+	return count, offset, nil
+}
+
+// expressionColumn infers column type of expression from its values.
+func expressionColumn(name string, records []*record, i int) mysql.Column {
+	column := mysql.Column{Name: name, Type: flag.MySQLTypeNull}
+	for _, r := range records {
+		switch r.values[i].(type) {
+		case int64:
+			column.Type, column.Length = flag.MySQLTypeLongLong, 21
+			column.Flags |= flag.BinaryFlag
+		case float64:
+			column.Type, column.Length, column.Decimals = flag.MySQLTypeDouble, 23, 0x1f
+			column.Flags |= flag.BinaryFlag
+		case time.Time:
+			column.Type, column.Length = flag.MySQLTypeDatetime, 19
+			column.Flags |= flag.BinaryFlag
+		case string:
+			column.Type = flag.MySQLTypeVarString
+		default:
+			continue
+		}
+		break
+	}
+	if column.Type == flag.MySQLTypeNull && len(records) == 0 {
+		column.Type = flag.MySQLTypeVarString
+	}
+	return column
+}
@@ -0,0 +1,292 @@
+package memengine
+
+import (
+	"github.com/pingcap/parser/ast"
+	pmysql "github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/types"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"strings"
+)
+
+type database struct {
+	name   string
+	tables map[string]*table
+}
+
+type table struct {
+	database string
+	name     string
+	columns  []*column
+	keys     []*key
+	// rows are stored by column order, values are nil, int64, float64, string or time.Time
+	rows          [][]interface{}
+	autoIncrement int64
+}
+
+// key is primary key or unique key.
+type key struct {
+	name    string
+	columns []int
+}
+
+type column struct {
+	name     string
+	tp       flag.TableColumnType
+	kind     valueKind
+	flags    flag.ColumnDefinition
+	length   uint32
+	decimals byte
+	// nil if the column doesn't have default value
+	defaultValue  interface{}
+	hasDefault    bool
+	autoIncrement bool
+}
+
+// columnIndex return index of column by name case-insensitively, -1 if not found.
+func (t *table) columnIndex(name string) int {
+	for i, c := range t.columns {
+		if strings.EqualFold(c.name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findDuplicate return key of rows[i] conflicting with row, rows[skip] is ignored.
+func (t *table) findDuplicate(rows [][]interface{}, row []interface{}, skip int) (*key, int) {
+	for _, k := range t.keys {
+		if hasNull(row, k.columns) {
+			continue
+		}
+		for i, other := range rows {
+			if i == skip || other == nil {
+				continue
+			}
+			equal := true
+			for _, c := range k.columns {
+				if compare(row[c], other[c]) != 0 {
+					equal = false
+					break
+				}
+			}
+			if equal {
+				return k, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+func (t *table) duplicateError(k *key, row []interface{}) error {
+	values := make([]string, len(k.columns))
+	for i, c := range k.columns {
+		values[i] = toString(row[c])
+	}
+	return myerrors.DupEntry.Build(strings.Join(values, "-"), t.name+"."+k.name)
+}
+
+func hasNull(row []interface{}, columns []int) bool {
+	for _, c := range columns {
+		if row[c] == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *column) mysqlColumn(database, table, alias, name string) mysql.Column {
+	return mysql.Column{
+		Database: database,
+		Table:    alias,
+		OrgTable: table,
+		Name:     name,
+		OrgName:  c.name,
+		Length:   c.length,
+		Type:     c.tp,
+		Flags:    c.flags,
+		Decimals: c.decimals,
+	}
+}
+
+func newTable(database string, stmt *ast.CreateTableStmt) (*table, error) {
+	t := &table{database: database, name: stmt.Table.Name.O}
+	for _, def := range stmt.Cols {
+		if t.columnIndex(def.Name.Name.O) >= 0 {
+			return nil, myerrors.DupFieldName.Build(def.Name.Name.O)
+		}
+		c := newColumn(def.Name.Name.O, def.Tp)
+		t.columns = append(t.columns, c)
+
+		for _, option := range def.Options {
+			switch option.Tp {
+			case ast.ColumnOptionNotNull:
+				c.flags |= flag.NotNullFlag
+			case ast.ColumnOptionNull:
+				c.flags &^= flag.NotNullFlag
+			case ast.ColumnOptionAutoIncrement:
+				c.autoIncrement = true
+				c.flags |= flag.AutoIncrementFlag
+			case ast.ColumnOptionPrimaryKey:
+				if err := t.addKey("PRIMARY", []int{len(t.columns) - 1}); err != nil {
+					return nil, err
+				}
+			case ast.ColumnOptionUniqKey:
+				if err := t.addKey(c.name, []int{len(t.columns) - 1}); err != nil {
+					return nil, err
+				}
+			case ast.ColumnOptionDefaultValue:
+				v, err := newScope(nil).eval(option.Expr)
+				if err != nil {
+					return nil, err
+				}
+				if c.defaultValue, err = convert(c, v, 0); err != nil {
+					return nil, err
+				}
+				c.hasDefault = true
+			}
+		}
+	}
+
+	for _, constraint := range stmt.Constraints {
+		var name string
+		switch constraint.Tp {
+		case ast.ConstraintPrimaryKey:
+			name = "PRIMARY"
+		case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+			name = constraint.Name
+		default:
+			continue
+		}
+		var columns []int
+		for _, part := range constraint.Keys {
+			if part.Column == nil {
+				return nil, myerrors.NotSupportedYet.Build("functional key part")
+			}
+			i := t.columnIndex(part.Column.Name.O)
+			if i < 0 {
+				return nil, myerrors.KeyColumnDoesNotExist.Build(part.Column.Name.O)
+			}
+			columns = append(columns, i)
+		}
+		if name == "" {
+			name = t.columns[columns[0]].name
+		}
+		if err := t.addKey(name, columns); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *table) addKey(name string, columns []int) error {
+	for _, k := range t.keys {
+		if strings.EqualFold(k.name, name) {
+			if name == "PRIMARY" {
+				return myerrors.MultiplePriKey.Build()
+			}
+			return myerrors.DupKeyName.Build(name)
+		}
+	}
+	for _, i := range columns {
+		c := t.columns[i]
+		if name == "PRIMARY" {
+			c.flags |= flag.PriKeyFlag | flag.NotNullFlag
+		} else if len(columns) == 1 {
+			c.flags |= flag.UniqueKeyFlag
+		} else {
+			c.flags |= flag.MultipleKeyFlag
+		}
+	}
+	t.keys = append(t.keys, &key{name: name, columns: columns})
+	return nil
+}
+
+// clone return empty table with the same definition, it's used by CREATE TABLE ... LIKE.
+func (t *table) clone(database, name string) *table {
+	nt := &table{database: database, name: name}
+	for _, c := range t.columns {
+		nc := *c
+		nt.columns = append(nt.columns, &nc)
+	}
+	for _, k := range t.keys {
+		nt.keys = append(nt.keys, &key{name: k.name, columns: k.columns})
+	}
+	return nt
+}
+
+// default display width of types when it's not specified.
+var defaultLength = map[byte]uint32{
+	pmysql.TypeTiny:       4,
+	pmysql.TypeShort:      6,
+	pmysql.TypeInt24:      9,
+	pmysql.TypeLong:       11,
+	pmysql.TypeLonglong:   20,
+	pmysql.TypeYear:       4,
+	pmysql.TypeFloat:      12,
+	pmysql.TypeDouble:     22,
+	pmysql.TypeNewDecimal: 10,
+	pmysql.TypeDate:       10,
+	pmysql.TypeDatetime:   19,
+	pmysql.TypeTimestamp:  19,
+	pmysql.TypeBlob:       65535,
+	pmysql.TypeTinyBlob:   255,
+	pmysql.TypeMediumBlob: 16777215,
+	pmysql.TypeLongBlob:   4294967295,
+}
+
+func newColumn(name string, ft *types.FieldType) *column {
+	c := &column{name: name}
+
+	length := defaultLength[ft.Tp]
+	if ft.Flen > 0 {
+		length = uint32(ft.Flen)
+	}
+	switch ft.Tp {
+	case pmysql.TypeTiny, pmysql.TypeShort, pmysql.TypeInt24, pmysql.TypeLong, pmysql.TypeLonglong, pmysql.TypeYear:
+		c.tp, c.kind = flag.TableColumnType(ft.Tp), kindInt
+		c.flags |= flag.BinaryFlag
+		if pmysql.HasUnsignedFlag(ft.Flag) {
+			c.flags |= flag.UnsignedFlag
+		}
+	case pmysql.TypeFloat, pmysql.TypeDouble:
+		c.tp, c.kind = flag.TableColumnType(ft.Tp), kindFloat
+		c.flags |= flag.BinaryFlag
+		if ft.Decimal > 0 {
+			c.decimals = byte(ft.Decimal)
+		} else {
+			c.decimals = 0x1f
+		}
+	case pmysql.TypeNewDecimal:
+		c.tp, c.kind = flag.MySQLTypeNewDecimal, kindDecimal
+		c.flags |= flag.BinaryFlag
+		if ft.Decimal > 0 {
+			c.decimals = byte(ft.Decimal)
+		}
+	case pmysql.TypeDate, pmysql.TypeDatetime, pmysql.TypeTimestamp:
+		c.tp, c.kind = flag.TableColumnType(ft.Tp), kindTime
+		c.flags |= flag.BinaryFlag
+	case pmysql.TypeBlob, pmysql.TypeTinyBlob, pmysql.TypeMediumBlob, pmysql.TypeLongBlob:
+		c.tp, c.kind = flag.MySQLTypeBlob, kindString
+		c.flags |= flag.BlobFlag
+	case pmysql.TypeString:
+		c.tp, c.kind = flag.MySQLTypeString, kindString
+		length *= 4
+	case pmysql.TypeEnum:
+		c.tp, c.kind = flag.MySQLTypeString, kindString
+		c.flags |= flag.EnumFlag
+	case pmysql.TypeSet:
+		c.tp, c.kind = flag.MySQLTypeString, kindString
+		c.flags |= flag.SetFlag
+	case pmysql.TypeJSON:
+		c.tp, c.kind = flag.MySQLTypeJson, kindString
+		c.flags |= flag.BlobFlag | flag.BinaryFlag
+	default:
+		// VARCHAR, TIME, BIT and others are stored as string
+		c.tp, c.kind = flag.MySQLTypeVarString, kindString
+		length *= 4
+	}
+	c.length = length
+	return c
+}
@@ -0,0 +1,296 @@
+package memengine
+
+import (
+	"fmt"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// valueKind is how values of column are stored.
+type valueKind uint8
+
+const (
+	kindString valueKind = iota
+	kindInt
+	kindFloat
+	// decimal is stored as string with fixed decimals
+	kindDecimal
+	kindTime
+)
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC3339Nano,
+}
+
+// normalize converts value of literal or statement argument to nil, int64, float64, string or time.Time.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, int64, float64, string, time.Time:
+		return x
+	case bool:
+		if x {
+			return int64(1)
+		}
+		return int64(0)
+	case int:
+		return int64(x)
+	case int8:
+		return int64(x)
+	case int16:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case uint:
+		return normalize(uint64(x))
+	case uint8:
+		return int64(x)
+	case uint16:
+		return int64(x)
+	case uint32:
+		return int64(x)
+	case uint64:
+		if x > math.MaxInt64 {
+			return float64(x)
+		}
+		return int64(x)
+	case float32:
+		return float64(x)
+	case []byte:
+		return string(x)
+	case fmt.Stringer:
+		// decimal literal
+		s := x.String()
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+		return s
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// convert converts value to storage type of column, row is used in error message.
+func convert(c *column, v interface{}, row int) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch c.kind {
+	case kindInt:
+		switch x := v.(type) {
+		case int64:
+			return x, nil
+		case float64:
+			return int64(math.Round(x)), nil
+		case string:
+			s := strings.TrimSpace(x)
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n, nil
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return int64(math.Round(f)), nil
+			}
+		}
+		return nil, myerrors.TruncatedWrongValue.Build("integer", toString(v), c.name, row)
+
+	case kindFloat:
+		if _, ok := v.(time.Time); !ok {
+			if f, ok := parseFloat(v); ok {
+				return f, nil
+			}
+		}
+		return nil, myerrors.TruncatedWrongValue.Build("double", toString(v), c.name, row)
+
+	case kindDecimal:
+		if _, ok := v.(time.Time); !ok {
+			if f, ok := parseFloat(v); ok {
+				return strconv.FormatFloat(f, 'f', int(c.decimals), 64), nil
+			}
+		}
+		return nil, myerrors.TruncatedWrongValue.Build("decimal", toString(v), c.name, row)
+
+	case kindTime:
+		t, ok := v.(time.Time)
+		if !ok {
+			var err error
+			if t, err = parseTime(toString(v)); err != nil {
+				return nil, myerrors.TruncatedWrongValue.Build("datetime", toString(v), c.name, row)
+			}
+		}
+		if c.tp == flag.MySQLTypeDate {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		return t, nil
+
+	default:
+		return toString(v), nil
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, strings.TrimSpace(s), time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseFloat converts value to float64, string must be a number.
+func parseFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toFloat converts value to number like MySQL, non-numeric string is 0.
+func toFloat(v interface{}) float64 {
+	switch x := v.(type) {
+	case int64:
+		return float64(x)
+	case float64:
+		return x
+	case string:
+		s := strings.TrimSpace(x)
+		// longest numeric prefix
+		for i := len(s); i > 0; i-- {
+			if f, err := strconv.ParseFloat(s[:i], 64); err == nil {
+				return f
+			}
+		}
+	case time.Time:
+		f, _ := strconv.ParseFloat(x.Format("20060102150405"), 64)
+		return f
+	}
+	return 0
+}
+
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case string:
+		return x
+	case time.Time:
+		if x.Nanosecond() != 0 {
+			return x.Format("2006-01-02 15:04:05.999999")
+		}
+		return x.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(v)
+}
+
+func toBool(v interface{}) bool {
+	return toFloat(v) != 0
+}
+
+func boolValue(b bool) interface{} {
+	if b {
+		return int64(1)
+	}
+	return int64(0)
+}
+
+// compare compares values like MySQL, string is compared case-insensitively,
+// string is converted to number or datetime when compared with them. NULL is less than any value.
+func compare(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case string:
+		switch y := b.(type) {
+		case string:
+			return strings.Compare(strings.ToLower(x), strings.ToLower(y))
+		case time.Time:
+			if t, err := parseTime(x); err == nil {
+				return compareTime(t, y)
+			}
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			return compareTime(x, y)
+		case string:
+			if t, err := parseTime(y); err == nil {
+				return compareTime(x, t)
+			}
+		}
+	}
+
+	x, y := toFloat(a), toFloat(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func compareTime(x, y time.Time) int {
+	switch {
+	case x.Before(y):
+		return -1
+	case x.After(y):
+		return 1
+	}
+	return 0
+}
+
+// valueKey return key identifying value, equal values have the same key.
+func valueKey(values []interface{}) string {
+	var sb strings.Builder
+	for _, v := range values {
+		switch x := v.(type) {
+		case nil:
+			sb.WriteString("N")
+		case string:
+			sb.WriteString("S" + strconv.Quote(strings.ToLower(x)))
+		case time.Time:
+			sb.WriteString("T" + x.Format(time.RFC3339Nano))
+		default:
+			sb.WriteString("F" + strconv.FormatFloat(toFloat(x), 'g', -1, 64))
+		}
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
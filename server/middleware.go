@@ -0,0 +1,237 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"regexp"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps next Handler to run code before or after it, returned Handler
+// usually embeds Wrapper and overrides commands it intercepts.
+type Middleware func(next Handler) Handler
+
+// Chain wraps handler with middlewares, the first middleware is the outermost.
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Logging logs statements of COM_QUERY, COM_STMT_PREPARE and COM_STMT_EXECUTE at info level,
+// and logs their errors at warn level. Like general log of MySQL, passwords of statements such as
+// CREATE USER, ALTER USER and SET PASSWORD are replaced with <secret>.
+func Logging(logger Logger) Middleware {
+	return func(next Handler) Handler {
+		return &logging{Wrapper: Wrapper{Next: next}, logger: logger}
+	}
+}
+
+type logging struct {
+	Wrapper
+	logger Logger
+}
+
+func (l *logging) Query(session *Session, query string) (interface{}, error) {
+	rs, err := l.Wrapper.Query(session, query)
+	l.log(session, packet.ComQuery, query, err)
+	return rs, err
+}
+
+func (l *logging) Prepare(session *Session, query string) (int, []mysql.Column, error) {
+	paramCount, columns, err := l.Wrapper.Prepare(session, query)
+	l.log(session, packet.ComStmtPrepare, query, err)
+	return paramCount, columns, err
+}
+
+func (l *logging) Execute(session *Session, stmt *Stmt, args []interface{}) (interface{}, error) {
+	rs, err := l.Wrapper.Execute(session, stmt, args)
+	l.log(session, packet.ComStmtExecute, stmt.Query, err)
+	return rs, err
+}
+
+func (l *logging) log(session *Session, command packet.Command, query string, err error) {
+	msg := fmt.Sprintf("%s '%s'@'%s' [%s]: %s", command, session.User(), session.Host(), session.Database(), redact(query))
+	if err != nil {
+		l.logger.Warn(fmt.Errorf("%s, error: %v", msg, err))
+		return
+	}
+	l.logger.Info(errors.New(msg))
+}
+
+const stringLiteral = `(?:'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*")`
+
+var (
+	// IDENTIFIED BY 'password', IDENTIFIED WITH plugin BY 'password', IDENTIFIED WITH plugin AS 'hash'
+	// and REPLACE 'current password'
+	identifiedPassword = regexp.MustCompile(`(?i)(\b(?:IDENTIFIED(?:\s+WITH\s+\S+)?\s+(?:BY|AS)|REPLACE)\s+)` + stringLiteral)
+	// SET PASSWORD [FOR user] = 'password' and SET PASSWORD = PASSWORD('password')
+	setPassword = regexp.MustCompile(`(?i)(\bSET\s+PASSWORD\b[^=]*=\s*)(?:PASSWORD\s*\(\s*` + stringLiteral + `\s*\)|` + stringLiteral + `)`)
+)
+
+// redact replaces passwords of query with <secret>.
+func redact(query string) string {
+	query = identifiedPassword.ReplaceAllString(query, "${1}<secret>")
+	return setPassword.ReplaceAllString(query, "${1}<secret>")
+}
+
+// Recovery recovers panics of handler, logs them with stack at error level and
+// sends ERR 1815 to client instead of crashing the process.
+// Panics when reading RowIterator returned by handler are not recovered.
+func Recovery(logger Logger) Middleware {
+	return func(next Handler) Handler {
+		return &recovery{Wrapper: Wrapper{Next: next}, logger: logger}
+	}
+}
+
+type recovery struct {
+	Wrapper
+	logger Logger
+}
+
+// recover must be deferred directly.
+func (r *recovery) recover(err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	r.logger.Error(fmt.Errorf("handler panic: %v\n%s", v, debug.Stack()))
+	if err != nil {
+		*err = myerrors.InternalError.Build(fmt.Sprint(v))
+	}
+}
+
+func (r *recovery) Ping(session *Session) (err error) {
+	defer r.recover(&err)
+	return r.Wrapper.Ping(session)
+}
+
+func (r *recovery) Query(session *Session, query string) (rs interface{}, err error) {
+	defer r.recover(&err)
+	return r.Wrapper.Query(session, query)
+}
+
+func (r *recovery) Other(session *Session, data []byte) {
+	var err error
+	defer func() {
+		if err != nil {
+			if werr := session.Conn().WriteError(err); werr != nil {
+				r.logger.Error(fmt.Errorf("write error packet failed: %v", werr))
+			}
+		}
+	}()
+	defer r.recover(&err)
+	r.Wrapper.Other(session, data)
+}
+
+func (r *recovery) OnConnect(connId uint32) {
+	defer r.recover(nil)
+	r.Wrapper.OnConnect(connId)
+}
+
+func (r *recovery) OnClose(connId uint32) {
+	defer r.recover(nil)
+	r.Wrapper.OnClose(connId)
+}
+
+func (r *recovery) Prepare(session *Session, query string) (paramCount int, columns []mysql.Column, err error) {
+	defer r.recover(&err)
+	return r.Wrapper.Prepare(session, query)
+}
+
+func (r *recovery) Execute(session *Session, stmt *Stmt, args []interface{}) (rs interface{}, err error) {
+	defer r.recover(&err)
+	return r.Wrapper.Execute(session, stmt, args)
+}
+
+func (r *recovery) CloseStmt(session *Session, stmt *Stmt) {
+	defer r.recover(nil)
+	r.Wrapper.CloseStmt(session, stmt)
+}
+
+func (r *recovery) InitDB(session *Session, database string) (err error) {
+	defer r.recover(&err)
+	return r.Wrapper.InitDB(session, database)
+}
+
+func (r *recovery) FieldList(session *Session, table, wildcard string) (columns []mysql.Column, err error) {
+	defer r.recover(&err)
+	return r.Wrapper.FieldList(session, table, wildcard)
+}
+
+func (r *recovery) ChangeUser(session *Session) (err error) {
+	defer r.recover(&err)
+	return r.Wrapper.ChangeUser(session)
+}
+
+func (r *recovery) ResetConnection(session *Session) (err error) {
+	defer r.recover(&err)
+	return r.Wrapper.ResetConnection(session)
+}
+
+func (r *recovery) SetOption(session *Session, option flag.SetOption) (err error) {
+	defer r.recover(&err)
+	return r.Wrapper.SetOption(session, option)
+}
+
+func (r *recovery) Kill(session *Session, target *Session) (err error) {
+	defer r.recover(&err)
+	return r.Wrapper.Kill(session, target)
+}
+
+// TimingFunc receives how long handler took to perform command, query is statement of
+// COM_QUERY COM_STMT_PREPARE and COM_STMT_EXECUTE, database of COM_INIT_DB and table of COM_FIELD_LIST.
+type TimingFunc func(session *Session, command packet.Command, query string, elapsed time.Duration)
+
+// Timing measures commands performed by handler, such as writing slow query log or metrics.
+// Time of writing result set to client is not included.
+func Timing(f TimingFunc) Middleware {
+	return func(next Handler) Handler {
+		return &timing{Wrapper: Wrapper{Next: next}, f: f}
+	}
+}
+
+type timing struct {
+	Wrapper
+	f TimingFunc
+}
+
+func (t *timing) Ping(session *Session) error {
+	defer t.observe(session, packet.ComPing, "", time.Now())
+	return t.Wrapper.Ping(session)
+}
+
+func (t *timing) Query(session *Session, query string) (interface{}, error) {
+	defer t.observe(session, packet.ComQuery, query, time.Now())
+	return t.Wrapper.Query(session, query)
+}
+
+func (t *timing) Prepare(session *Session, query string) (int, []mysql.Column, error) {
+	defer t.observe(session, packet.ComStmtPrepare, query, time.Now())
+	return t.Wrapper.Prepare(session, query)
+}
+
+func (t *timing) Execute(session *Session, stmt *Stmt, args []interface{}) (interface{}, error) {
+	defer t.observe(session, packet.ComStmtExecute, stmt.Query, time.Now())
+	return t.Wrapper.Execute(session, stmt, args)
+}
+
+func (t *timing) InitDB(session *Session, database string) error {
+	defer t.observe(session, packet.ComInitDB, database, time.Now())
+	return t.Wrapper.InitDB(session, database)
+}
+
+func (t *timing) FieldList(session *Session, table, wildcard string) ([]mysql.Column, error) {
+	defer t.observe(session, packet.ComFieldList, table, time.Now())
+	return t.Wrapper.FieldList(session, table, wildcard)
+}
+
+func (t *timing) observe(session *Session, command packet.Command, query string, start time.Time) {
+	t.f(session, command, query, time.Since(start))
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/packet"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type panicHandler struct {
+	DefaultHandler
+}
+
+func (h *panicHandler) Query(session *Session, query string) (interface{}, error) {
+	if query == "panic" {
+		panic("boom")
+	}
+	return h.DefaultHandler.Query(session, query)
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			order = append(order, name)
+			return next
+		}
+	}
+	Chain(NewDefaultHandler(), trace("outer"), trace("inner"))
+	if strings.Join(order, ",") != "inner,outer" {
+		t.Fatalf("unexpected wrapping order: %v", order)
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	out := new(bytes.Buffer)
+	logger := NewDefaultLogger(InfoLevel, out)
+	var (
+		mu       sync.Mutex
+		commands []packet.Command
+	)
+	handler := Chain(new(panicHandler),
+		Logging(logger),
+		Recovery(logger),
+		Timing(func(session *Session, command packet.Command, query string, elapsed time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			commands = append(commands, command)
+		}))
+	srv := newTestServer(newUserProvider(t), handler, WithLogger(logger))
+
+	conn, err := client.CreateConnection(client.WithDialer(pipeDialer(srv)), client.WithUser("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Exec("panic")
+	errPkt, ok := err.(*packet.ERR)
+	if !ok || errPkt.ErrorCode != code.ErrInternalError {
+		t.Fatalf("expected internal error, got %v", err)
+	}
+	if _, err := conn.Exec("CREATE DATABASE db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := out.String()
+	for _, s := range []string{"handler panic: boom", "COM_QUERY 'root'@", "panic, error: ", "CREATE DATABASE db"} {
+		if !strings.Contains(logs, s) {
+			t.Errorf("log doesn't contain %q:\n%s", s, logs)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// panicked query is measured too
+	if len(commands) != 3 || commands[1] != packet.ComQuery || commands[2] != packet.ComPing {
+		t.Fatalf("unexpected measured commands: %v", commands)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	for _, c := range []struct {
+		query string
+		want  string
+	}{
+		{"CREATE USER 'u'@'%' IDENTIFIED BY 'p''w'", "CREATE USER 'u'@'%' IDENTIFIED BY <secret>"},
+		{"create user u identified with caching_sha2_password by \"pw\" password expire",
+			"create user u identified with caching_sha2_password by <secret> password expire"},
+		{"CREATE USER u IDENTIFIED WITH mysql_native_password AS '*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9'",
+			"CREATE USER u IDENTIFIED WITH mysql_native_password AS <secret>"},
+		{"ALTER USER USER() IDENTIFIED BY 'new' REPLACE 'old'", "ALTER USER USER() IDENTIFIED BY <secret> REPLACE <secret>"},
+		{"SET PASSWORD FOR 'u'@'%' = 'pw'", "SET PASSWORD FOR 'u'@'%' = <secret>"},
+		{"set password = password('pw'); SELECT 1", "set password = <secret>; SELECT 1"},
+		{"SELECT REPLACE('a', 'b', 'c')", "SELECT REPLACE('a', 'b', 'c')"},
+	} {
+		if got := redact(c.query); got != c.want {
+			t.Errorf("redact(%q) = %q, want %q", c.query, got, c.want)
+		}
+	}
+}
+
+type statisticsHandler struct {
+	DefaultHandler
+}
+
+func (h *statisticsHandler) Statistics(session *Session) (*packet.Statistics, error) {
+	return &packet.Statistics{Uptime: 42}, nil
+}
+
+func TestWrapperStatistics(t *testing.T) {
+	logger := NewDefaultLogger(ErrorLevel, new(bytes.Buffer))
+	for _, c := range []struct {
+		name    string
+		handler Handler
+		check   func(stats *packet.Statistics) bool
+	}{
+		{"Forwarded", new(statisticsHandler), func(stats *packet.Statistics) bool { return stats.Uptime == 42 }},
+		{"Server", NewDefaultHandler(), func(stats *packet.Statistics) bool { return stats.Threads == 1 }},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			srv := newTestServer(newUserProvider(t), Chain(c.handler, Logging(logger)))
+			conn, err := client.CreateConnection(client.WithDialer(pipeDialer(srv)), client.WithUser("root"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			stats, err := conn.Statistics()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.check(stats) {
+				t.Fatalf("unexpected statistics: %+v", stats)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package server
+
+import (
+	"fmt"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"math/bits"
+	"strings"
+)
+
+// Privilege is a set of privileges like columns of mysql.user table.
+// https://dev.mysql.com/doc/refman/8.0/en/privileges-provided.html
+type Privilege uint32
+
+const (
+	SelectPriv Privilege = 1 << iota
+	InsertPriv
+	UpdatePriv
+	DeletePriv
+	CreatePriv
+	DropPriv
+	ReloadPriv
+	ShutdownPriv
+	ProcessPriv
+	FilePriv
+	GrantPriv
+	ReferencesPriv
+	IndexPriv
+	AlterPriv
+	ShowDBPriv
+	SuperPriv
+	CreateTmpTablePriv
+	LockTablesPriv
+	ExecutePriv
+	ReplSlavePriv
+	ReplClientPriv
+	CreateViewPriv
+	ShowViewPriv
+	CreateRoutinePriv
+	AlterRoutinePriv
+	CreateUserPriv
+	EventPriv
+	TriggerPriv
+	CreateTablespacePriv
+
+	// AllPrivileges is ALL PRIVILEGES, which doesn't include GRANT OPTION.
+	AllPrivileges = (CreateTablespacePriv<<1 - 1) &^ GrantPriv
+
+	// databasePrivileges can be granted to databases, global privileges not in it don't give access to databases.
+	databasePrivileges = SelectPriv | InsertPriv | UpdatePriv | DeletePriv | CreatePriv | DropPriv | GrantPriv |
+		ReferencesPriv | IndexPriv | AlterPriv | CreateTmpTablePriv | LockTablesPriv | ExecutePriv |
+		CreateViewPriv | ShowViewPriv | CreateRoutinePriv | AlterRoutinePriv | EventPriv | TriggerPriv
+
+	// columnPrivileges can be granted to columns.
+	columnPrivileges = SelectPriv | InsertPriv | UpdatePriv | ReferencesPriv
+)
+
+var privilegeNames = []string{
+	"SELECT",
+	"INSERT",
+	"UPDATE",
+	"DELETE",
+	"CREATE",
+	"DROP",
+	"RELOAD",
+	"SHUTDOWN",
+	"PROCESS",
+	"FILE",
+	"GRANT OPTION",
+	"REFERENCES",
+	"INDEX",
+	"ALTER",
+	"SHOW DATABASES",
+	"SUPER",
+	"CREATE TEMPORARY TABLES",
+	"LOCK TABLES",
+	"EXECUTE",
+	"REPLICATION SLAVE",
+	"REPLICATION CLIENT",
+	"CREATE VIEW",
+	"SHOW VIEW",
+	"CREATE ROUTINE",
+	"ALTER ROUTINE",
+	"CREATE USER",
+	"EVENT",
+	"TRIGGER",
+	"CREATE TABLESPACE",
+}
+
+// ParsePrivilege parses privilege name of GRANT statement case-insensitively,
+// such as SELECT, ALL PRIVILEGES and USAGE.
+func ParsePrivilege(name string) (Privilege, error) {
+	name = strings.ToUpper(strings.Join(strings.Fields(name), " "))
+	switch name {
+	case "ALL", "ALL PRIVILEGES":
+		return AllPrivileges, nil
+	case "USAGE":
+		return 0, nil
+	case "GRANT":
+		return GrantPriv, nil
+	}
+	for i, privName := range privilegeNames {
+		if privName == name {
+			return 1 << i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown privilege: %s", name)
+}
+
+// String return names of privileges separated by comma.
+func (p Privilege) String() string {
+	if p == 0 {
+		return "USAGE"
+	}
+	var names []string
+	for i, name := range privilegeNames {
+		if p&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// first return the lowest privilege of p.
+func (p Privilege) first() Privilege {
+	return 1 << bits.TrailingZeros32(uint32(p))
+}
+
+// Grant is privileges granted to account on an object, like a GRANT statement.
+type Grant struct {
+	Privileges Privilege
+
+	// Database is empty or * for global privileges, it can contain % and _ wildcards like MySQL,
+	// which are escaped by \.
+	Database string
+
+	// Table is empty or * for database privileges.
+	Table string
+
+	// Columns are columns of column privileges, only SELECT INSERT UPDATE and REFERENCES are column privileges.
+	Columns []string
+}
+
+func (g *Grant) global() bool {
+	return g.Database == "" || g.Database == "*"
+}
+
+func (g *Grant) matchDatabase(database string) bool {
+	if g.global() {
+		return true
+	}
+	if strings.ContainsAny(g.Database, "%_\\") {
+		return LikeMatch(database, g.Database, '\\')
+	}
+	return g.Database == database
+}
+
+// applies reports whether privileges of g are granted on column of table of database,
+// table is empty for database privileges, column is empty for table privileges.
+func (g *Grant) applies(database, table, column string) bool {
+	if g.global() {
+		return true
+	}
+	if database == "" || !g.matchDatabase(database) {
+		return false
+	}
+	if g.Table == "" || g.Table == "*" {
+		return true
+	}
+	if table == "" || g.Table != table {
+		return false
+	}
+	if len(g.Columns) == 0 {
+		return true
+	}
+	for _, c := range g.Columns {
+		if column != "" && strings.EqualFold(c, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrivilegeProvider is optionally implemented by UserProvider to check privileges of statements
+// when WithCheckPrivileges is true, like mysql.user mysql.db mysql.tables_priv and mysql.columns_priv tables.
+type PrivilegeProvider interface {
+	// Grants return privileges granted to account, like SHOW GRANTS.
+	Grants(key string) ([]Grant, error)
+}
+
+type grants []Grant
+
+func (gs grants) privileges(database, table, column string) Privilege {
+	var p Privilege
+	for i := range gs {
+		if gs[i].applies(database, table, column) {
+			p |= gs[i].Privileges
+		}
+	}
+	return p
+}
+
+// canAccessDatabase reports whether account has any privilege on database or its tables,
+// which is required by USE and COM_INIT_DB.
+func (gs grants) canAccessDatabase(database string) bool {
+	for i := range gs {
+		g := &gs[i]
+		if g.Privileges&databasePrivileges&^GrantPriv != 0 && g.matchDatabase(database) {
+			return true
+		}
+	}
+	return false
+}
+
+// columnPrivileges return privileges granted to any column of table.
+func (gs grants) columnPrivileges(database, table string) Privilege {
+	var p Privilege
+	for i := range gs {
+		g := &gs[i]
+		if len(g.Columns) > 0 && !g.global() && g.matchDatabase(database) && g.Table == table {
+			p |= g.Privileges
+		}
+	}
+	return p
+}
+
+// privilegeRequest is privileges required by statement on an object.
+type privilegeRequest struct {
+	privileges Privilege
+	// database and table are empty for global privileges, table is empty for database privileges,
+	// and privileges is 0 if any privilege on database is required.
+	database string
+	table    string
+
+	// columns are checked by column privileges if table privileges are missing,
+	// allColumns means columns are unknown, such as SELECT *.
+	columns    []string
+	allColumns bool
+}
+
+// check return error 1227 1044 1142 or 1143 if privileges are missing,
+// and error 1046 if table is requested without database.
+func (r *privilegeRequest) check(gs grants, user, host string) error {
+	switch {
+	case r.database == "" && r.table != "":
+		return myerrors.NoDb.Build()
+	case r.database == "":
+		if missing := r.privileges &^ gs.privileges("", "", ""); missing != 0 {
+			return myerrors.SpecificAccessDenied.Build(missing.first())
+		}
+	case r.table == "":
+		if r.privileges == 0 {
+			if !gs.canAccessDatabase(r.database) {
+				return myerrors.DbAccessDenied.Build(user, host, r.database)
+			}
+			return nil
+		}
+		if missing := r.privileges &^ gs.privileges(r.database, "", ""); missing != 0 {
+			return myerrors.DbAccessDenied.Build(user, host, r.database)
+		}
+	default:
+		missing := r.privileges &^ gs.privileges(r.database, r.table, "")
+		if missing == 0 {
+			return nil
+		}
+		// columns are checked only if table has column privileges, like MySQL
+		if missing&^gs.columnPrivileges(r.database, r.table) != 0 || r.allColumns || len(r.columns) == 0 {
+			return myerrors.TableAccessDenied.Build(missing.first(), user, host, r.table)
+		}
+		for _, column := range r.columns {
+			if columnMissing := missing &^ gs.privileges(r.database, r.table, column); columnMissing != 0 {
+				return myerrors.ColumnAccessDenied.Build(columnMissing.first(), user, host, column, r.table)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) privilegeProvider() (PrivilegeProvider, bool) {
+	if !s.config.CheckPrivileges {
+		return nil, false
+	}
+	provider, ok := s.config.UserProvider.(PrivilegeProvider)
+	return provider, ok
+}
+
+// checkPrivileges checks privileges of account of key if checking is enabled.
+func (s *Server) checkPrivileges(key, user, host string, requests ...*privilegeRequest) error {
+	provider, ok := s.privilegeProvider()
+	if !ok {
+		return nil
+	}
+	gs, err := provider.Grants(key)
+	if err != nil {
+		return err
+	}
+	for _, r := range requests {
+		if err := r.check(gs, user, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkQueryPrivileges checks privileges required by every statement of query,
+// query that can't be parsed gets error 1064 if checking is enabled.
+func (s *Server) checkQueryPrivileges(session *Session, query string) error {
+	if _, ok := s.privilegeProvider(); !ok {
+		return nil
+	}
+	stmtNodes, _, err := parser.New().Parse(query, "", "")
+	if err != nil {
+		return myerrors.ParseError.Build(err.Error())
+	}
+
+	var requests []*privilegeRequest
+	database := session.Database()
+	for _, stmtNode := range stmtNodes {
+		stmtRequests, err := privilegeRequests(database, stmtNode)
+		if err != nil {
+			return err
+		}
+		requests = append(requests, stmtRequests...)
+		// USE changes database of the following statements
+		if v, ok := stmtNode.(*ast.UseStmt); ok {
+			database = v.DBName
+		}
+	}
+	return s.checkPrivileges(session.Key(), session.User(), session.Host(), requests...)
+}
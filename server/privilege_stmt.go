@@ -0,0 +1,385 @@
+package server
+
+import (
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/vczyh/mysql-protocol/myerrors"
+)
+
+// privilegeCollector works out privileges required by statement.
+type privilegeCollector struct {
+	// current database of session, it's database of table without database
+	database string
+	requests []*privilegeRequest
+	err      error
+}
+
+// privilegeRequests return privileges required by statement, statements not listed require SUPER,
+// so that statements whose privileges are unknown are denied to ordinary accounts.
+// It return error 1046 if table without database is used and no database is selected.
+func privilegeRequests(database string, stmtNode ast.StmtNode) ([]*privilegeRequest, error) {
+	c := &privilegeCollector{database: database}
+	c.statement(stmtNode)
+	return c.requests, c.err
+}
+
+func (c *privilegeCollector) statement(stmtNode ast.StmtNode) {
+	switch v := stmtNode.(type) {
+	case *ast.SelectStmt, *ast.UnionStmt:
+		c.read(nil, v)
+
+	case *ast.InsertStmt:
+		var table *ast.TableName
+		if v.Table != nil {
+			tables := new(tableCollector)
+			v.Table.Accept(tables)
+			if len(tables.tables) > 0 {
+				table = tables.tables[0]
+			}
+		}
+		if table == nil {
+			return
+		}
+		privileges := InsertPriv
+		if v.IsReplace {
+			privileges |= DeletePriv
+		}
+		var columns []string
+		for _, column := range v.Columns {
+			columns = append(columns, column.Name.O)
+		}
+		for _, assignment := range v.Setlist {
+			columns = append(columns, assignment.Column.Name.O)
+		}
+		c.table(privileges, table, columns, len(columns) == 0)
+		if len(v.OnDuplicate) > 0 {
+			columns = nil
+			for _, assignment := range v.OnDuplicate {
+				columns = append(columns, assignment.Column.Name.O)
+			}
+			c.table(UpdatePriv, table, columns, false)
+		}
+		if v.Select != nil {
+			c.read(nil, v.Select)
+		}
+
+	case *ast.UpdateStmt:
+		tables := new(tableCollector)
+		v.TableRefs.Accept(tables)
+		for _, table := range tables.tables {
+			var columns []string
+			for _, assignment := range v.List {
+				if tables.owns(table, assignment.Column.Table.L) {
+					columns = append(columns, assignment.Column.Name.O)
+				}
+			}
+			if len(columns) > 0 {
+				c.table(UpdatePriv, table, columns, false)
+			}
+		}
+		c.read(tables.set(), v)
+
+	case *ast.DeleteStmt:
+		tables := new(tableCollector)
+		v.TableRefs.Accept(tables)
+		targets := tables.tables
+		if v.IsMultiTable && v.Tables != nil {
+			targets = nil
+			for _, t := range v.Tables.Tables {
+				if table := tables.aliases[t.Name.L]; table != nil {
+					targets = append(targets, table)
+				} else {
+					targets = append(targets, t)
+				}
+			}
+		}
+		for _, table := range targets {
+			c.table(DeletePriv, table, nil, false)
+		}
+		// tables of multi-table DELETE are aliases, so they are not read
+		if v.Where != nil {
+			c.read(tables.set(), v.TableRefs, v.Where)
+		} else {
+			c.read(tables.set(), v.TableRefs)
+		}
+
+	case *ast.LockTablesStmt:
+		for _, lock := range v.TableLocks {
+			c.table(LockTablesPriv|SelectPriv, lock.Table, nil, false)
+		}
+
+	case *ast.LoadDataStmt:
+		c.table(InsertPriv, v.Table, nil, false)
+		if !v.IsLocal {
+			c.global(FilePriv)
+		}
+
+	case *ast.CreateDatabaseStmt:
+		c.db(CreatePriv, v.Name)
+	case *ast.DropDatabaseStmt:
+		c.db(DropPriv, v.Name)
+	case *ast.AlterDatabaseStmt:
+		c.db(AlterPriv, v.Name)
+	case *ast.UseStmt:
+		c.db(0, v.DBName)
+
+	case *ast.CreateTableStmt:
+		c.table(CreatePriv, v.Table, nil, false)
+		if v.ReferTable != nil {
+			c.table(SelectPriv, v.ReferTable, nil, true)
+		}
+		if v.Select != nil {
+			c.table(InsertPriv, v.Table, nil, false)
+			c.read(nil, v.Select)
+		}
+	case *ast.DropTableStmt:
+		for _, table := range v.Tables {
+			c.table(DropPriv, table, nil, false)
+		}
+	case *ast.AlterTableStmt:
+		c.table(AlterPriv, v.Table, nil, false)
+	case *ast.TruncateTableStmt:
+		c.table(DropPriv, v.Table, nil, false)
+	case *ast.RenameTableStmt:
+		for _, t := range v.TableToTables {
+			c.table(AlterPriv|DropPriv, t.OldTable, nil, false)
+			c.table(CreatePriv|InsertPriv, t.NewTable, nil, false)
+		}
+	case *ast.CreateIndexStmt:
+		c.table(IndexPriv, v.Table, nil, false)
+	case *ast.DropIndexStmt:
+		c.table(IndexPriv, v.Table, nil, false)
+	case *ast.CreateViewStmt:
+		c.table(CreateViewPriv, v.ViewName, nil, false)
+		c.read(nil, v.Select)
+
+	case *ast.ExplainStmt:
+		c.statement(v.Stmt)
+
+	case *ast.PrepareStmt:
+		// statement in user variable is unknown until EXECUTE
+		if v.SQLVar != nil {
+			c.err = myerrors.NotSupportedYet.Build("PREPARE FROM user variable when checking privileges")
+			return
+		}
+		stmtNodes, _, err := parser.New().Parse(v.SQLText, "", "")
+		if err != nil {
+			c.err = myerrors.ParseError.Build(err.Error())
+			return
+		}
+		for _, stmtNode := range stmtNodes {
+			c.statement(stmtNode)
+		}
+	// privileges of prepared statement are checked by PREPARE
+	case *ast.ExecuteStmt, *ast.DeallocateStmt:
+
+	case *ast.ShowStmt:
+		table := v.Table
+		// SHOW COLUMNS FROM t FROM db
+		if table != nil && table.Schema.O == "" && v.DBName != "" {
+			table = &ast.TableName{Schema: model.NewCIStr(v.DBName), Name: table.Name}
+		}
+		switch v.Tp {
+		case ast.ShowColumns, ast.ShowCreateTable, ast.ShowIndex:
+			c.table(SelectPriv, table, nil, true)
+		case ast.ShowTables, ast.ShowTableStatus:
+			c.db(0, v.DBName)
+		}
+
+	case *ast.SetStmt:
+		for _, variable := range v.Variables {
+			if variable.IsSystem && variable.IsGlobal {
+				c.global(SuperPriv)
+				break
+			}
+		}
+		for _, variable := range v.Variables {
+			if variable.Value != nil {
+				c.read(nil, variable.Value)
+			}
+		}
+	case *ast.DoStmt:
+		for _, expr := range v.Exprs {
+			c.read(nil, expr)
+		}
+	case *ast.BeginStmt, *ast.CommitStmt, *ast.RollbackStmt, *ast.UnlockTablesStmt:
+
+	case *ast.GrantStmt:
+		c.grant(v.Privs, v.Level)
+	case *ast.RevokeStmt:
+		c.grant(v.Privs, v.Level)
+
+	case *ast.CreateUserStmt, *ast.DropUserStmt:
+		c.global(CreateUserPriv)
+	case *ast.AlterUserStmt:
+		// ALTER USER USER() changes password of current user
+		if v.CurrentAuth == nil {
+			c.global(CreateUserPriv)
+		}
+	case *ast.SetPwdStmt:
+		if v.User != nil && !v.User.CurrentUser {
+			c.global(CreateUserPriv)
+		}
+	case *ast.FlushStmt:
+		c.global(ReloadPriv)
+	case *ast.ShutdownStmt:
+		c.global(ShutdownPriv)
+
+	default:
+		c.global(SuperPriv)
+	}
+}
+
+// grant requires GRANT OPTION and the granted privileges on the level.
+func (c *privilegeCollector) grant(privs []*ast.PrivElem, level *ast.GrantLevel) {
+	privileges := GrantPriv
+	for _, priv := range privs {
+		if priv.Priv == mysql.AllPriv {
+			privileges |= AllPrivileges
+		} else if p, err := ParsePrivilege(mysql.Priv2Str[priv.Priv]); err == nil {
+			privileges |= p
+		}
+	}
+	switch level.Level {
+	case ast.GrantLevelGlobal:
+		c.global(privileges)
+	case ast.GrantLevelDB:
+		c.db(privileges, level.DBName)
+	case ast.GrantLevelTable:
+		database := level.DBName
+		if database == "" {
+			database = c.database
+		}
+		if database == "" {
+			c.err = myerrors.NoDb.Build()
+			return
+		}
+		c.requests = append(c.requests, &privilegeRequest{privileges: privileges, database: database, table: level.TableName})
+	}
+}
+
+func (c *privilegeCollector) global(privileges Privilege) {
+	c.requests = append(c.requests, &privilegeRequest{privileges: privileges})
+}
+
+func (c *privilegeCollector) db(privileges Privilege, database string) {
+	if database == "" {
+		database = c.database
+	}
+	if database == "" {
+		c.err = myerrors.NoDb.Build()
+		return
+	}
+	c.requests = append(c.requests, &privilegeRequest{privileges: privileges, database: database})
+}
+
+func (c *privilegeCollector) table(privileges Privilege, table *ast.TableName, columns []string, allColumns bool) {
+	if table == nil {
+		return
+	}
+	database := table.Schema.O
+	if database == "" {
+		database = c.database
+	}
+	if database == "" {
+		c.err = myerrors.NoDb.Build()
+		return
+	}
+	c.requests = append(c.requests, &privilegeRequest{
+		privileges: privileges,
+		database:   database,
+		table:      table.Name.O,
+		columns:    columns,
+		allColumns: allColumns,
+	})
+}
+
+// read requires SELECT on tables and columns read by node. Columns without table are
+// columns of every table, because it's unknown which table they belong to without schema.
+// Targets are tables changed by statement, SELECT on them is required only if their columns are read.
+func (c *privilegeCollector) read(targets map[*ast.TableName]bool, nodes ...ast.Node) {
+	r := new(tableCollector)
+	for _, node := range nodes {
+		node.Accept(r)
+	}
+	if r.outfile {
+		c.global(FilePriv)
+	}
+
+	for _, table := range r.tables {
+		var columns []string
+		allColumns := false
+		for _, column := range r.columns {
+			if r.owns(table, column.Table.L) {
+				columns = append(columns, column.Name.O)
+			}
+		}
+		for _, wildcard := range r.wildcards {
+			if r.owns(table, wildcard.Table.L) {
+				allColumns = true
+			}
+		}
+		if targets[table] && len(columns) == 0 && !allColumns {
+			continue
+		}
+		// table without any column read, such as SELECT COUNT(*) FROM t, requires table privilege
+		c.table(SelectPriv, table, columns, allColumns)
+	}
+}
+
+// tableCollector collects tables, columns and wildcards of node.
+type tableCollector struct {
+	tables []*ast.TableName
+	// lower case alias or name of table
+	aliases   map[string]*ast.TableName
+	columns   []*ast.ColumnName
+	wildcards []*ast.WildCardField
+	// outfile is SELECT ... INTO OUTFILE or DUMPFILE, which requires FILE
+	outfile bool
+}
+
+func (c *tableCollector) Enter(n ast.Node) (ast.Node, bool) {
+	if c.aliases == nil {
+		c.aliases = make(map[string]*ast.TableName)
+	}
+	switch v := n.(type) {
+	case *ast.TableSource:
+		if table, ok := v.Source.(*ast.TableName); ok && v.AsName.L != "" {
+			c.aliases[v.AsName.L] = table
+		}
+	case *ast.TableName:
+		c.tables = append(c.tables, v)
+		if _, ok := c.aliases[v.Name.L]; !ok {
+			c.aliases[v.Name.L] = v
+		}
+	case *ast.ColumnNameExpr:
+		c.columns = append(c.columns, v.Name)
+	case *ast.WildCardField:
+		c.wildcards = append(c.wildcards, v)
+	case *ast.SelectStmt:
+		if v.SelectIntoOpt != nil {
+			c.outfile = true
+		}
+	}
+	return n, false
+}
+
+func (c *tableCollector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// owns reports whether column or wildcard qualified by lower case alias belongs to table,
+// column without alias belongs to every table.
+func (c *tableCollector) owns(table *ast.TableName, alias string) bool {
+	return alias == "" || c.aliases[alias] == table
+}
+
+func (c *tableCollector) set() map[*ast.TableName]bool {
+	m := make(map[*ast.TableName]bool, len(c.tables))
+	for _, table := range c.tables {
+		m[table] = true
+	}
+	return m
+}
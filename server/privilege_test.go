@@ -0,0 +1,144 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"testing"
+)
+
+type okHandler struct {
+	DefaultHandler
+}
+
+func (h *okHandler) Query(session *Session, query string) (interface{}, error) {
+	return &mysql.Result{}, nil
+}
+
+func TestParsePrivilege(t *testing.T) {
+	cases := map[string]Privilege{
+		"select":                  SelectPriv,
+		"ALL PRIVILEGES":          AllPrivileges,
+		"usage":                   0,
+		"grant  option":           GrantPriv,
+		"Show Databases":          ShowDBPriv,
+		"CREATE TEMPORARY TABLES": CreateTmpTablePriv,
+	}
+	for name, expected := range cases {
+		p, err := ParsePrivilege(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, p)
+		}
+	}
+	if _, err := ParsePrivilege("FLY"); err == nil {
+		t.Error("expected error of unknown privilege")
+	}
+	if AllPrivileges&GrantPriv != 0 {
+		t.Error("ALL PRIVILEGES must not include GRANT OPTION")
+	}
+}
+
+func TestCheckPrivileges(t *testing.T) {
+	userProvider := newUserProvider(t, &CreateUserRequest{
+		User:   "app",
+		Host:   "%",
+		Method: auth.MySQLNativePassword,
+		Grants: []Grant{
+			{Privileges: ReloadPriv},
+			{Privileges: SelectPriv | InsertPriv | UpdatePriv | DeletePriv | LockTablesPriv, Database: "app"},
+			{Privileges: LockTablesPriv, Database: "locks"},
+			{Privileges: CreatePriv | DropPriv, Database: "tmp\\_%"},
+			{Privileges: SelectPriv, Database: "hr", Table: "staff"},
+			{Privileges: SelectPriv | UpdatePriv, Database: "hr", Table: "salary", Columns: []string{"id", "amount"}},
+		},
+	})
+	srv := newTestServer(userProvider, new(okHandler), WithCheckPrivileges(true))
+
+	conn, err := client.CreateConnection(client.WithDialer(pipeDialer(srv)), client.WithUser("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	check := func(query string, expected code.Err) {
+		t.Helper()
+		_, err := conn.Exec(query)
+		if expected == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", query, err)
+			}
+			return
+		}
+		errPkt, ok := err.(*packet.ERR)
+		if !ok || errPkt.ErrorCode != expected {
+			t.Errorf("%s: expected error %d, got %v", query, expected, err)
+		}
+	}
+
+	check("SELECT 1", 0)
+	check("SELECT * FROM t", code.ErrNoDbError)
+	check("SELECT * FROM app.t", 0)
+	check("FLUSH TABLES", 0)
+	check("SHUTDOWN", code.ErrSpecificAccessDenied)
+	check("CREATE USER u", code.ErrSpecificAccessDenied)
+
+	if err := conn.InitDB("hr"); err != nil {
+		t.Fatal(err)
+	}
+	check("SELECT * FROM staff", 0)
+	check("INSERT INTO staff VALUES (1)", code.ErrTableAccessDenied)
+	check("SELECT * FROM salary", code.ErrTableAccessDenied)
+	check("SELECT id, amount FROM salary WHERE id = 1", 0)
+	check("SELECT s.id, s.bonus FROM salary s", code.ErrColumnAccessDenied)
+	check("UPDATE salary SET amount = amount + 1 WHERE id = 1", 0)
+	check("UPDATE salary SET bonus = 1", code.ErrColumnAccessDenied)
+	check("SELECT * FROM staff JOIN app.t ON staff.id = t.id", 0)
+	check("INSERT INTO app.t SELECT * FROM staff", 0)
+	check("INSERT INTO staff SELECT * FROM app.t", code.ErrTableAccessDenied)
+	check("DELETE FROM app.t WHERE id IN (SELECT id FROM secret.t)", code.ErrTableAccessDenied)
+	check("SELECT 1; DROP TABLE app.t", code.ErrTableAccessDenied)
+	check("SHOW COLUMNS FROM t FROM app", 0)
+	check("SHOW COLUMNS FROM staff FROM secret", code.ErrTableAccessDenied)
+
+	// subqueries of SET and DO
+	check("SET @a = 1, NAMES utf8mb4", 0)
+	check("SET @a = (SELECT k FROM secret.t)", code.ErrTableAccessDenied)
+	check("DO (SELECT k FROM secret.t)", code.ErrTableAccessDenied)
+
+	// statement of PREPARE is checked, EXECUTE requires nothing
+	check("PREPARE s FROM 'SELECT * FROM app.t'", 0)
+	check("PREPARE s FROM 'SELECT * FROM secret.t'", code.ErrTableAccessDenied)
+	check("PREPARE s FROM @query", code.ErrNotSupportedYet)
+	check("EXECUTE s", 0)
+	check("DEALLOCATE PREPARE s", 0)
+
+	check("LOCK TABLES app.t WRITE", 0)
+	check("LOCK TABLES staff READ", code.ErrTableAccessDenied)
+	check("LOCK TABLES locks.t READ", code.ErrTableAccessDenied)
+	check("UNLOCK TABLES", 0)
+	check("SELECT * FROM app.t INTO OUTFILE '/tmp/t'", code.ErrSpecificAccessDenied)
+	check("BEGIN", 0)
+	check("COMMIT", 0)
+	// statements whose privileges are unknown require SUPER
+	check("ANALYZE TABLE app.t", code.ErrSpecificAccessDenied)
+
+	check("CREATE DATABASE tmp_1", 0)
+	check("CREATE DATABASE tmpx", code.ErrDbAccessDenied)
+	check("DROP DATABASE app", code.ErrDbAccessDenied)
+	check("GRANT SELECT ON app.* TO u", code.ErrDbAccessDenied)
+	check("SELEC 1", code.ErrParseError)
+
+	check("USE secret", code.ErrDbAccessDenied)
+	check("USE app; SELECT * FROM t", 0)
+	if err := conn.InitDB("secret"); err == nil {
+		t.Error("expected error of COM_INIT_DB")
+	}
+	if err := conn.InitDB("app"); err != nil {
+		t.Error(err)
+	}
+}
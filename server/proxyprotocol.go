@@ -0,0 +1,243 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// https://www.haproxy.org/download/2.8/doc/proxy-protocol.txt
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errProxyHeader = errors.New("server: invalid PROXY protocol header")
+)
+
+const (
+	// max length of v1 header including CRLF
+	proxyV1MaxLen = 107
+
+	proxyV2CommandLocal = 0x0
+	proxyV2CommandProxy = 0x1
+
+	proxyV2FamilyInet  = 0x1
+	proxyV2FamilyInet6 = 0x2
+)
+
+// proxyNetworks are sources trusted to send PROXY protocol header.
+type proxyNetworks struct {
+	all    bool
+	unix   bool
+	ipNets []*net.IPNet
+}
+
+// parseProxyNetworks parses IP, CIDR, localhost for Unix socket, and * for all sources.
+func parseProxyNetworks(networks []string) (*proxyNetworks, error) {
+	pn := new(proxyNetworks)
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+		switch {
+		case network == "*":
+			pn.all = true
+		case network == "localhost":
+			pn.unix = true
+		case strings.Contains(network, "/"):
+			_, ipNet, err := net.ParseCIDR(network)
+			if err != nil {
+				return nil, fmt.Errorf("invalid PROXY protocol network %s: %v", network, err)
+			}
+			pn.ipNets = append(pn.ipNets, ipNet)
+		default:
+			ip := net.ParseIP(network)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid PROXY protocol network %s", network)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			pn.ipNets = append(pn.ipNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return pn, nil
+}
+
+// trusted reports whether conn comes from trusted proxy.
+func (pn *proxyNetworks) trusted(conn net.Conn) bool {
+	if addr := conn.LocalAddr(); addr != nil && addr.Network() == "unix" {
+		return pn.all || pn.unix
+	}
+	if pn.all {
+		return true
+	}
+	v, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, ipNet := range pn.ipNets {
+		if ipNet.Contains(v.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyConn is connection from proxy, its addresses are addresses of client and server sent by proxy.
+type proxyConn struct {
+	net.Conn
+	r          *bufio.Reader
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// acceptProxy reads PROXY protocol header of connection from trusted proxy, connection from
+// other sources is returned as it is. Trusted proxy must send header before server sends handshake.
+func (s *Server) acceptProxy(conn net.Conn) (net.Conn, error) {
+	if s.proxyNetworks == nil || !s.proxyNetworks.trusted(conn) {
+		return conn, nil
+	}
+
+	conn.SetReadDeadline(time.Now().Add(s.config.ConnectTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	pc := &proxyConn{
+		Conn:       conn,
+		r:          bufio.NewReader(conn),
+		localAddr:  conn.LocalAddr(),
+		remoteAddr: conn.RemoteAddr(),
+	}
+	if err := pc.readHeader(); err != nil {
+		return nil, fmt.Errorf("read PROXY protocol header from %s failed: %v", conn.RemoteAddr(), err)
+	}
+	return pc, nil
+}
+
+func (c *proxyConn) readHeader() error {
+	prefix, err := c.r.Peek(len(proxyV1Prefix))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(prefix, proxyV1Prefix) {
+		return c.readV1Header()
+	}
+	return c.readV2Header()
+}
+
+// readV1Header reads human-readable header, such as "PROXY TCP4 192.168.0.1 192.168.0.11 56324 3306\r\n".
+func (c *proxyConn) readV1Header() error {
+	var line []byte
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyV1MaxLen {
+			return errProxyHeader
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return errProxyHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 {
+		return errProxyHeader
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		// keep addresses of connection, such as health check of proxy
+		return nil
+	case "TCP4", "TCP6":
+	default:
+		return errProxyHeader
+	}
+	if len(fields) != 6 {
+		return errProxyHeader
+	}
+
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, srcErr := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, dstErr := strconv.ParseUint(fields[5], 10, 16)
+	if srcIP == nil || dstIP == nil || srcErr != nil || dstErr != nil {
+		return errProxyHeader
+	}
+	if (fields[1] == "TCP4") != (srcIP.To4() != nil) {
+		return errProxyHeader
+	}
+	c.remoteAddr = &net.TCPAddr{IP: srcIP, Port: int(srcPort)}
+	c.localAddr = &net.TCPAddr{IP: dstIP, Port: int(dstPort)}
+	return nil
+}
+
+// readV2Header reads binary header, TLVs are ignored.
+func (c *proxyConn) readV2Header() error {
+	header := make([]byte, len(proxyV2Signature)+4)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return err
+	}
+	if !bytes.Equal(header[:len(proxyV2Signature)], proxyV2Signature) {
+		return errProxyHeader
+	}
+	verCmd, family := header[12], header[13]
+	if verCmd>>4 != 2 {
+		return errProxyHeader
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return err
+	}
+
+	switch verCmd & 0x0f {
+	case proxyV2CommandLocal:
+		// keep addresses of connection, such as health check of proxy
+		return nil
+	case proxyV2CommandProxy:
+	default:
+		return errProxyHeader
+	}
+
+	var ipLen int
+	switch family >> 4 {
+	case proxyV2FamilyInet:
+		ipLen = net.IPv4len
+	case proxyV2FamilyInet6:
+		ipLen = net.IPv6len
+	default:
+		// AF_UNSPEC and AF_UNIX, addresses of connection are kept
+		return nil
+	}
+	if len(body) < 2*ipLen+4 {
+		return errProxyHeader
+	}
+	srcIP := net.IP(append([]byte(nil), body[:ipLen]...))
+	dstIP := net.IP(append([]byte(nil), body[ipLen:2*ipLen]...))
+	srcPort := binary.BigEndian.Uint16(body[2*ipLen:])
+	dstPort := binary.BigEndian.Uint16(body[2*ipLen+2:])
+	c.remoteAddr = &net.TCPAddr{IP: srcIP, Port: int(srcPort)}
+	c.localAddr = &net.TCPAddr{IP: dstIP, Port: int(dstPort)}
+	return nil
+}
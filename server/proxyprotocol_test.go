@@ -0,0 +1,129 @@
+package server
+
+import (
+	"encoding/binary"
+	"github.com/vczyh/mysql-protocol/client"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func proxyV2Header(command byte, src, dst *net.TCPAddr) []byte {
+	header := append([]byte(nil), proxyV2Signature...)
+	header = append(header, 0x20|command)
+	srcIP, dstIP, family := src.IP.To4(), dst.IP.To4(), byte(0x11)
+	if srcIP == nil {
+		srcIP, dstIP, family = src.IP.To16(), dst.IP.To16(), 0x21
+	}
+	body := append(append([]byte(nil), srcIP...), dstIP...)
+	body = append(body, byte(src.Port>>8), byte(src.Port), byte(dst.Port>>8), byte(dst.Port))
+	// TLV is ignored
+	body = append(body, 0x04, 0x00, 0x01, 0xff)
+
+	header = append(header, family, 0, 0)
+	binary.BigEndian.PutUint16(header[len(header)-2:], uint16(len(body)))
+	return append(header, body...)
+}
+
+func TestProxyProtocol(t *testing.T) {
+	userProvider := newUserProvider(t,
+		&CreateUserRequest{User: "app", Host: "10.1.2.3"},
+		&CreateUserRequest{User: "app6", Host: "2001:db8::1"},
+		&CreateUserRequest{User: "local", Host: "127.0.0.1"})
+
+	serve := func(networks ...string) (string, chan *Session) {
+		h := &sessionHandler{sessions: make(chan *Session, 1)}
+		srv := newTestServer(userProvider, h, WithProxyProtocol(networks...), WithConnectTimeout(time.Second))
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go srv.Serve(l)
+		t.Cleanup(func() { l.Close() })
+		return l.Addr().String(), h.sessions
+	}
+	connect := func(addr, user string, header []byte) (*client.Conn, error) {
+		return client.CreateConnection(client.WithUser(user),
+			client.WithDialer(func(network, address string) (net.Conn, error) {
+				conn, err := net.Dial("tcp", addr)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := conn.Write(header); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				return conn, nil
+			}))
+	}
+
+	addr, sessions := serve("10.0.0.0/8", "127.0.0.1")
+	src := &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 50000}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 3306}
+	tests := []struct {
+		name   string
+		user   string
+		header []byte
+		remote string
+	}{
+		{"v1", "app", []byte("PROXY TCP4 10.1.2.3 10.0.0.1 50000 3306\r\n"), "10.1.2.3:50000"},
+		{"v1 unknown", "local", []byte("PROXY UNKNOWN\r\n"), ""},
+		{"v2", "app", proxyV2Header(proxyV2CommandProxy, src, dst), "10.1.2.3:50000"},
+		{"v2 ipv6", "app6", proxyV2Header(proxyV2CommandProxy,
+			&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 50000},
+			&net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 3306}), "[2001:db8::1]:50000"},
+		{"v2 local", "local", proxyV2Header(proxyV2CommandLocal, src, dst), ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, err := connect(addr, test.user, test.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if _, err := conn.Exec("SET NAMES utf8mb4"); err != nil {
+				t.Fatal(err)
+			}
+			session := <-sessions
+			if test.remote != "" && session.RemoteAddr().String() != test.remote {
+				t.Fatalf("expected remote address %s, got %s", test.remote, session.RemoteAddr())
+			}
+		})
+	}
+
+	t.Run("invalid header", func(t *testing.T) {
+		for _, header := range [][]byte{
+			// client waits for handshake until connect timeout
+			nil,
+			[]byte("PROXY TCP4 10.1.2.3 10.0.0.1 50000\r\n"),
+			[]byte("PROXY TCP6 10.1.2.3 10.0.0.1 50000 3306\r\n"),
+		} {
+			if conn, err := connect(addr, "app", header); err == nil {
+				conn.Close()
+				t.Fatalf("header %q is accepted", header)
+			}
+		}
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		addr, _ := serve("10.0.0.0/8")
+		if conn, err := connect(addr, "app", []byte("PROXY TCP4 10.1.2.3 10.0.0.1 50000 3306\r\n")); err == nil {
+			conn.Close()
+			t.Fatal("header from untrusted source is accepted")
+		}
+		conn, err := connect(addr, "local", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+
+	t.Run("invalid network", func(t *testing.T) {
+		srv := NewServer(userProvider, NewDefaultHandler(), WithLogger(NewDefaultLogger(ErrorLevel, ioutil.Discard)),
+			WithProxyProtocol("10.0.0.0/33"))
+		if err := srv.ServeConn(nil); err == nil {
+			t.Fatal("invalid network is accepted")
+		}
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/vczyh/mysql-protocol/flag"
 	"github.com/vczyh/mysql-protocol/mysql"
 	"github.com/vczyh/mysql-protocol/packet"
+	"io"
 	"time"
 )
 
@@ -37,7 +38,7 @@ func NewSimpleResultSet(columnNames []string, rowValues [][]interface{}) (*Resul
 		columns[i] = mysql.Column{Name: name}
 	}
 
-	for _, rowValue := range rowValues {
+	for r, rowValue := range rowValues {
 		if len(columnNames) != len(rowValue) {
 			return nil, ErrColumnRowMismatch
 		}
@@ -49,7 +50,7 @@ func NewSimpleResultSet(columnNames []string, rowValues [][]interface{}) (*Resul
 			}
 
 			now := column.Type
-			if i > 0 && now != bef {
+			if r > 0 && now != bef {
 				if now == flag.MySQLTypeNull {
 					column.Type = bef
 				} else if bef != flag.MySQLTypeNull && now != flag.MySQLTypeNull {
@@ -80,53 +81,24 @@ func (rs *ResultSet) Rows() []mysql.Row {
 }
 
 func (rs *ResultSet) WriteText(conn mysql.Conn) error {
-	columnDefs := rs.columnDefinitionPackets()
-
-	// column count packet
-	columnCountPkt := packet.NewColumnCount(len(columnDefs))
-	if err := conn.WritePacket(columnCountPkt); err != nil {
-		return err
-	}
-
-	// columnCount * ColumnDefinition packet
-	for _, column := range columnDefs {
-		if err := conn.WritePacket(column); err != nil {
-			return err
-		}
-	}
-
-	// EOF
-	// TODO  CLIENT_DEPRECATE_EOF
-	if err := conn.WritePacket(packet.NewEOF(0, 0)); err != nil {
-		return err
-	}
-
-	// columnCount * TextResultSetRow packet
-	for _, row := range rs.textRowPackets() {
-		if err := conn.WritePacket(row); err != nil {
-			return err
-		}
-	}
-
-	// EOF
-	// TODO  CLIENT_DEPRECATE_EOF
-	if err := conn.WritePacket(packet.NewEOF(0, 0)); err != nil {
-		return err
-	}
+	return rs.writeText(conn, 0)
+}
 
-	return nil
+// writeText writes text result set with status flags in EOF packets.
+func (rs *ResultSet) writeText(conn mysql.Conn, status flag.Status) error {
+	return writeRows(conn, rs.iterator(), status, false)
 }
 
-func (rs *ResultSet) columnDefinitionPackets() []*packet.ColumnDefinition {
-	columnDefs := make([]*packet.ColumnDefinition, len(rs.columns))
-	for i, c := range rs.columns {
+func columnDefinitionPackets(columns []mysql.Column) []*packet.ColumnDefinition {
+	columnDefs := make([]*packet.ColumnDefinition, len(columns))
+	for i, c := range columns {
 		columnDefs[i] = &packet.ColumnDefinition{
 			Schema:       c.Database,
 			Table:        c.Table,
 			OrgTable:     c.OrgTable,
 			Name:         c.Name,
 			OrgName:      c.OrgName,
-			CharacterSet: c.CharSet,
+			CharacterSet: defaultColumnCollation(&c),
 			ColumnLength: c.Length,
 			ColumnType:   c.Type,
 			Flags:        c.Flags,
@@ -136,18 +108,25 @@ func (rs *ResultSet) columnDefinitionPackets() []*packet.ColumnDefinition {
 	return columnDefs
 }
 
-func (rs *ResultSet) textRowPackets() []*packet.TextResultSetRow {
-	textRows := make([]*packet.TextResultSetRow, len(rs.rows))
-	for i, row := range rs.rows {
-		dRow := make([]packet.ColumnValue, len(row))
-		for j, cv := range row {
-			dRow[j] = packet.ColumnValue{Value: cv.Value()}
-		}
-		textRows[i] = &packet.TextResultSetRow{
-			Row: dRow,
+// WriteBinary writes result set of COM_STMT_EXECUTE.
+// https://dev.mysql.com/doc/internals/en/binary-protocol-resultset.html
+func (rs *ResultSet) WriteBinary(conn mysql.Conn) error {
+	return rs.writeBinary(conn, 0)
+}
+
+func (rs *ResultSet) writeBinary(conn mysql.Conn, status flag.Status) error {
+	return writeRows(conn, rs.iterator(), status, true)
+}
+
+func (rs *ResultSet) iterator() RowIterator {
+	i := 0
+	return NewRowIterator(rs.columns, func() (mysql.Row, error) {
+		if i >= len(rs.rows) {
+			return nil, io.EOF
 		}
-	}
-	return textRows
+		i++
+		return rs.rows[i-1], nil
+	})
 }
 
 func fillColumnDefinition(val interface{}, column *mysql.Column) (err error) {
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"io"
+)
+
+// RowIterator is a result set whose rows are produced one by one, Handler returns it
+// instead of *ResultSet to send large result without holding all rows in memory.
+// Every row is written to client as soon as Next returns it.
+type RowIterator interface {
+	// Columns return columns, it's called once before Next.
+	Columns() []mysql.Column
+
+	// Next return next row, and io.EOF if there are no more rows.
+	// Other error is sent to client as ERR packet and stops the iteration.
+	Next() (mysql.Row, error)
+
+	// Close is called once when iteration stops, including all rows are sent, Next fails,
+	// or client disconnects.
+	Close() error
+}
+
+type funcRowIterator struct {
+	columns []mysql.Column
+	next    func() (mysql.Row, error)
+}
+
+// NewRowIterator return RowIterator that calls next for every row.
+func NewRowIterator(columns []mysql.Column, next func() (mysql.Row, error)) RowIterator {
+	return &funcRowIterator{
+		columns: columns,
+		next:    next,
+	}
+}
+
+func (it *funcRowIterator) Columns() []mysql.Column {
+	return it.columns
+}
+
+func (it *funcRowIterator) Next() (mysql.Row, error) {
+	return it.next()
+}
+
+func (it *funcRowIterator) Close() error {
+	return nil
+}
+
+// writeRows writes text or binary result set of rows, it stops when writing fails,
+// such as client disconnects.
+func writeRows(conn mysql.Conn, rows RowIterator, status flag.Status, binary bool) error {
+	defer rows.Close()
+
+	columns := rows.Columns()
+	if err := conn.WritePacket(packet.NewColumnCount(len(columns))); err != nil {
+		return err
+	}
+	if err := writeColumnDefinitions(conn, columns, status); err != nil {
+		return err
+	}
+
+	columnTypes := make([]flag.TableColumnType, len(columns))
+	for i, column := range columns {
+		columnTypes[i] = column.Type
+	}
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && len(row) != len(columns) {
+			err = ErrColumnRowMismatch
+		}
+		if err != nil {
+			// ERR packet terminates result set
+			return writeError(conn, err)
+		}
+
+		values := make([]packet.ColumnValue, len(row))
+		for i, cv := range row {
+			values[i] = packet.ColumnValue{Value: cv.Value()}
+		}
+		var rowPkt packet.Packet = &packet.TextResultSetRow{Row: values}
+		if binary {
+			rowPkt = &packet.BinaryResultSetRow{Row: values, ColumnTypes: columnTypes}
+		}
+		if err := conn.WritePacket(rowPkt); err != nil {
+			return err
+		}
+	}
+
+	// TODO  CLIENT_DEPRECATE_EOF
+	return conn.WritePacket(packet.NewEOF(0, status))
+}
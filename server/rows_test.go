@@ -0,0 +1,140 @@
+package server
+
+import (
+	"errors"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type streamHandler struct {
+	DefaultHandler
+	closed chan int
+}
+
+// Query streams rows 0, 1, 2 ..., the number of rows depends on query.
+func (h *streamHandler) Query(session *Session, query string) (interface{}, error) {
+	limit := -1
+	switch query {
+	case "SELECT 10":
+		limit = 10
+	case "SELECT ERROR":
+		limit = 3
+	}
+
+	n := 0
+	columns := []mysql.Column{{Name: "n", Type: flag.MySQLTypeLongLong}}
+	return &countingIterator{
+		RowIterator: NewRowIterator(columns, func() (mysql.Row, error) {
+			if n == limit {
+				if query == "SELECT ERROR" {
+					return nil, errors.New("storage unavailable")
+				}
+				return nil, io.EOF
+			}
+			n++
+			return mysql.Row{mysql.NewColumnValue(int64(n - 1))}, nil
+		}),
+		n:      &n,
+		closed: h.closed,
+	}, nil
+}
+
+type countingIterator struct {
+	RowIterator
+	n      *int
+	closed chan int
+}
+
+func (it *countingIterator) Close() error {
+	it.closed <- *it.n
+	return it.RowIterator.Close()
+}
+
+func TestRowIterator(t *testing.T) {
+	h := &streamHandler{closed: make(chan int, 1)}
+	srv := newTestServer(newUserProvider(t), h)
+
+	var netConn net.Conn
+	conn, err := client.CreateConnection(
+		client.WithUser("root"),
+		client.WithDialer(func(network, address string) (net.Conn, error) {
+			var err error
+			netConn, err = pipeDialer(srv)(network, address)
+			return netConn, err
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Run("All", func(t *testing.T) {
+		rows, err := conn.Query("SELECT 10")
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for {
+			row, err := rows.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := row[0].String(); v != strconv.Itoa(count) {
+				t.Fatalf("expected row %d, got %s", count, v)
+			}
+			count++
+		}
+		if count != 10 {
+			t.Fatalf("expected 10 rows, got %d", count)
+		}
+		if n := <-h.closed; n != 10 {
+			t.Fatalf("expected iterator closed after 10 rows, got %d", n)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		rows, err := conn.Query("SELECT ERROR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			if _, err = rows.Next(); err != nil {
+				break
+			}
+		}
+		if err == io.EOF {
+			t.Fatal("expected error at the end of rows")
+		}
+		<-h.closed
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+		rows, err := conn.Query("SELECT INFINITE")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := rows.Next(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		netConn.Close()
+
+		select {
+		case n := <-h.closed:
+			if n < 5 {
+				t.Fatalf("expected at least 5 rows, got %d", n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("iteration doesn't stop after client disconnects")
+		}
+	})
+}
@@ -10,9 +10,15 @@ import (
 	"github.com/vczyh/mysql-protocol/myerrors"
 	"github.com/vczyh/mysql-protocol/mysql"
 	"github.com/vczyh/mysql-protocol/packet"
+	"io"
 	"math/big"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Server struct {
@@ -32,7 +38,22 @@ type Server struct {
 	serverCert tls.Certificate
 	clientCert tls.Certificate
 
-	l net.Listener
+	// sources trusted to send PROXY protocol header, nil if PROXY protocol is disabled
+	proxyNetworks *proxyNetworks
+
+	buildOnce sync.Once
+	buildErr  error
+	startTime time.Time
+	// number of statements sent by clients
+	questions uint64
+
+	mu         sync.Mutex
+	inShutdown int32
+	listeners  map[net.Listener]struct{}
+	// live connections, key is connection id
+	conns map[uint32]*trackedConn
+	// number of connections of account, key is UserProvider key
+	userConns map[string]int
 }
 
 func NewServer(userProvider UserProvider, handler Handler, opts ...Option) *Server {
@@ -49,36 +70,138 @@ func NewServer(userProvider UserProvider, handler Handler, opts ...Option) *Serv
 	return s
 }
 
+// Start listens on the configured host and port, and serves connections.
 func (s *Server) Start() error {
-	if err := s.build(); err != nil {
-		s.config.Logger.Error(err)
+	return s.ListenAndServe(net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port)))
+}
+
+// ListenAndServe listens on addr and serves connections. addr is a Unix socket path
+// if it contains "/", such as "/tmp/mysql.sock", otherwise it's a TCP address,
+// such as "127.0.0.1:3306" or ":3306".
+func (s *Server) ListenAndServe(addr string) error {
+	if err := s.init(); err != nil {
 		return err
 	}
+	if s.shuttingDown() {
+		return ErrServerClosed
+	}
 
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.config.Port))
+	network := "tcp"
+	if strings.Contains(addr, "/") {
+		network = "unix"
+	}
+	l, err := net.Listen(network, addr)
 	if err != nil {
 		return err
 	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until l is closed or the server shuts down, and
+// always closes l. Serve can be called with multiple listeners at the same time.
+//
+// Connections accepted on Unix socket have host localhost and are treated as secure transport.
+func (s *Server) Serve(l net.Listener) error {
 	defer l.Close()
-	s.l = l
 
+	if err := s.init(); err != nil {
+		return err
+	}
+	if !s.trackListener(l, true) {
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+
+	var tempDelay time.Duration
 	for {
-		conn, err := s.l.Accept()
+		conn, err := l.Accept()
 		if err != nil {
-			s.config.Logger.Error(fmt.Errorf("tcp accept failed: %v", err))
-			continue
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if max := 1 * time.Second; tempDelay > max {
+					tempDelay = max
+				}
+				s.config.Logger.Error(fmt.Errorf("accept failed: %v, retrying in %v", err, tempDelay))
+				time.Sleep(tempDelay)
+				continue
+			}
+			return err
 		}
+		tempDelay = 0
 
 		connId, err := s.applyForConnectionId()
 		if err != nil {
 			s.config.Logger.Error(fmt.Errorf("apply for connection id failed: %v", err))
+			conn.Close()
 			continue
 		}
-		go s.handleConnection(mysql.NewServerConnection(conn, connId, s.defaultCapabilities()))
+		go s.serveConn(conn, connId)
 	}
 }
 
+// ServeConn serves one accepted connection and return when the connection is closed.
+func (s *Server) ServeConn(conn net.Conn) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+
+	connId, err := s.applyForConnectionId()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	s.serveConn(conn, connId)
+	return nil
+}
+
+// serveConn reads PROXY protocol header if conn comes from trusted proxy, and handles conn.
+func (s *Server) serveConn(conn net.Conn, connId uint32) {
+	pc, err := s.acceptProxy(conn)
+	if err != nil {
+		s.config.Logger.Warn(err)
+		conn.Close()
+		return
+	}
+	s.handleConnection(mysql.NewServerConnection(pc, connId, s.defaultCapabilities()))
+}
+
+// init builds server only once.
+func (s *Server) init() error {
+	s.buildOnce.Do(func() {
+		if s.buildErr = s.build(); s.buildErr != nil {
+			s.config.Logger.Error(s.buildErr)
+		}
+	})
+	return s.buildErr
+}
+
 func (s *Server) build() error {
+	s.startTime = time.Now()
+
+	if s.config.Logger == nil {
+		s.config.Logger = NewDefaultLogger(SystemLevel, os.Stdout)
+	}
+
+	if s.config.ConnectTimeout == 0 {
+		s.config.ConnectTimeout = defaultConnectTimeout
+	}
+
+	if s.config.MaxAllowedPacket == 0 {
+		s.config.MaxAllowedPacket = defaultMaxAllowedPacket
+	}
+
+	if s.config.Variables == nil {
+		s.config.Variables = NewSystemVariables()
+	}
+	s.syncSystemVariables()
+
 	if s.config.SHA2Cache == nil {
 		s.config.SHA2Cache = NewDefaultSHA2Cache()
 	}
@@ -91,8 +214,12 @@ func (s *Server) build() error {
 		return fmt.Errorf("require Handler not nil")
 	}
 
-	if s.config.Logger == nil {
-		s.config.Logger = NewDefaultLogger(SystemLevel, os.Stdout)
+	if len(s.config.ProxyProtocolNetworks) > 0 {
+		proxyNetworks, err := parseProxyNetworks(s.config.ProxyProtocolNetworks)
+		if err != nil {
+			return err
+		}
+		s.proxyNetworks = proxyNetworks
 	}
 
 	if err := s.generateReadKeyPair(); err != nil {
@@ -121,8 +248,26 @@ func (s *Server) build() error {
 func (s *Server) handleConnection(conn mysql.Conn) {
 	defer s.closeConnection(conn)
 
-	if err := s.auth(conn); err != nil {
-		if !myerrors.Is(err) {
+	tc, err := s.trackConn(conn)
+	if err != nil {
+		conn.WriteError(err)
+		return
+	}
+	defer s.untrackConn(tc)
+
+	conn.SetMaxAllowedPacket(s.config.MaxAllowedPacket)
+	deadline := time.Now().Add(s.config.ConnectTimeout)
+	conn.SetReadDeadline(deadline)
+	conn.SetWriteDeadline(deadline)
+
+	session, err := s.auth(conn)
+	if err == nil {
+		err = s.acquireUserConnection(session.Key(), session.User())
+	}
+	if err != nil {
+		if rerr := readError(err); rerr != nil {
+			err = rerr
+		} else if !myerrors.Is(err) {
 			s.config.Logger.Error(fmt.Errorf("auth error: %v", err))
 		}
 		if err := conn.WriteError(err); err != nil {
@@ -130,61 +275,170 @@ func (s *Server) handleConnection(conn mysql.Conn) {
 		}
 		return
 	}
+	defer func() {
+		s.releaseUserConnection(session.Key())
+	}()
+	tc.setSession(session)
+	defer s.closeStmts(session)
+
+	conn.SetReadDeadline(time.Time{})
+	conn.SetWriteDeadline(time.Time{})
 
-	if err := conn.WriteEmptyOK(); err != nil {
+	if err := session.writeOK(&mysql.Result{}); err != nil {
 		s.config.Logger.Error(fmt.Errorf("write empty ok packet failed: %v", err))
 		return
 	}
+	s.config.Handler.OnConnect(conn.ConnectionId())
+	defer s.config.Handler.OnClose(conn.ConnectionId())
 
+	timeout := s.idleTimeout(conn)
 	for {
-		if conn.Closed() {
+		// set before idle, so that it doesn't override deadline set by Shutdown
+		if timeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(timeout))
+		}
+		if conn.Closed() || !tc.setIdle(true) {
+			return
+		}
+		data, err := conn.ReadPacket()
+		// interrupted by Shutdown or closed by Close
+		if !tc.setIdle(false) {
+			conn.WriteError(myerrors.ServerShutdown.Build())
+			return
+		}
+		if rerr := readError(err); rerr != nil {
+			conn.WriteError(rerr)
 			return
 		}
-		if err := s.handleCommand(conn); err != nil {
-			s.config.Logger.Error(fmt.Errorf("can't handle command error: %v, so close the connection", err))
+		if err == nil && len(data) == 0 {
+			err = packet.ErrPacketData
+		}
+		if err == nil {
+			err = s.handleCommand(session, data)
+		}
+		if err != nil {
+			if err != io.EOF {
+				s.config.Logger.Error(fmt.Errorf("can't handle command error: %v, so close the connection", err))
+			}
 			return
 		}
 	}
 }
 
-func (s *Server) handleCommand(conn mysql.Conn) error {
-	data, err := conn.ReadPacket()
-	if err != nil {
-		return err
+func (s *Server) handleCommand(session *Session, data []byte) error {
+	conn := session.conn
+
+	if session.PasswordExpired() && !sandboxCommand(data) {
+		return session.writeError(myerrors.MustChangePassword.Build())
 	}
 
+	var err error
 	switch {
 	case packet.IsPing(data):
-		err := s.config.Handler.Ping()
-		if err == nil {
-			err = conn.WriteEmptyOK()
+		if pingErr := s.config.Handler.Ping(session); pingErr != nil {
+			err = session.writeError(pingErr)
 		} else {
-			err = conn.WriteError(err)
+			err = session.writeOK(&mysql.Result{})
 		}
 
 	case packet.IsQuery(data):
-		rs, err := s.config.Handler.Query(string(data[1:]))
-		if err != nil {
-			err = conn.WriteError(err)
+		atomic.AddUint64(&s.questions, 1)
+		if session.PasswordExpired() {
+			var handled bool
+			if handled, err = s.handleSandboxQuery(session, string(data[1:])); handled {
+				break
+			}
+		}
+		if privErr := s.checkQueryPrivileges(session, string(data[1:])); privErr != nil {
+			err = session.writeError(privErr)
+			break
+		}
+		rs, queryErr := s.config.Handler.Query(session, string(data[1:]))
+		if queryErr != nil {
+			err = session.writeError(queryErr)
 			break
 		}
 		switch v := rs.(type) {
 		case *mysql.Result:
-			err = v.Write(conn)
+			err = session.writeOK(v)
 		case *ResultSet:
-			err = v.WriteText(conn)
+			err = v.writeText(conn, session.Status())
+		case RowIterator:
+			err = writeRows(conn, v, session.Status(), false)
+		case Response:
+			err = v.WriteResponse(session)
+		case nil:
+			err = session.writeOK(&mysql.Result{})
+		default:
+			err = session.writeError(myerrors.InternalError.Build(fmt.Sprintf("unexpected query result %T", v)))
 		}
 
 	case packet.IsQuit(data):
 		s.closeConnection(conn)
 
+	case s.isStmtCommand(data):
+		if packet.Command(data[0]) == packet.ComStmtExecute {
+			atomic.AddUint64(&s.questions, 1)
+		}
+		err = s.handleStmtCommand(session, data)
+
+	case data[0] == packet.ComInitDB.Byte():
+		err = s.handleInitDB(session, data)
+
+	case data[0] == packet.ComFieldList.Byte():
+		err = s.handleFieldList(session, data)
+
+	case data[0] == packet.ComChangeUser.Byte():
+		err = s.handleChangeUser(session, data)
+
+	case data[0] == packet.ComResetConnection.Byte():
+		err = s.handleResetConnection(session)
+
+	case data[0] == packet.ComSetOption.Byte():
+		err = s.handleSetOption(session, data)
+
+	case data[0] == packet.ComStatistics.Byte():
+		err = s.handleStatistics(session)
+
+	case data[0] == packet.ComProcessKill.Byte():
+		err = s.handleKill(session, data)
+
 	default:
-		s.config.Handler.Other(data[1:], conn)
+		s.config.Handler.Other(session, data[1:])
 	}
 
 	return err
 }
 
+func (s *Server) isStmtCommand(data []byte) bool {
+	if _, ok := s.config.Handler.(StmtCommand); !ok {
+		return false
+	}
+	switch packet.Command(data[0]) {
+	case packet.ComStmtPrepare, packet.ComStmtExecute, packet.ComStmtSendLongData,
+		packet.ComStmtReset, packet.ComStmtClose:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Server) handleStmtCommand(session *Session, data []byte) error {
+	h := s.config.Handler.(StmtCommand)
+	switch packet.Command(data[0]) {
+	case packet.ComStmtPrepare:
+		return s.handleStmtPrepare(session, h, string(data[1:]))
+	case packet.ComStmtExecute:
+		return s.handleStmtExecute(session, h, data)
+	case packet.ComStmtSendLongData:
+		return s.handleStmtSendLongData(session, data)
+	case packet.ComStmtReset:
+		return s.handleStmtReset(session, data)
+	default:
+		return s.handleStmtClose(session, h, data)
+	}
+}
+
 func (s *Server) defaultCapabilities() flag.Capability {
 	capabilities := flag.ClientLongPassword |
 		flag.ClientFoundRows |
@@ -218,19 +472,29 @@ func (s *Server) defaultCapabilities() flag.Capability {
 }
 
 func (s *Server) applyForConnectionId() (uint32, error) {
-	bigN, err := rand.Int(rand.Reader, big.NewInt(2<<32))
-	if err != nil {
-		return 0, err
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		bigN, err := rand.Int(rand.Reader, big.NewInt(1<<32))
+		if err != nil {
+			return 0, err
+		}
+		connId := uint32(bigN.Uint64())
+		if _, ok := s.conns[connId]; connId != 0 && !ok {
+			return connId, nil
+		}
 	}
-	return uint32(bigN.Uint64()), nil
 }
 
 func (s *Server) closeConnection(conn mysql.Conn) {
-	if conn.Closed() {
-		return
-	}
 	conn.Close()
-	s.config.Handler.OnClose(conn.ConnectionId())
+}
+
+// WithHost sets the interface Start binds to, default is all interfaces.
+func WithHost(host string) Option {
+	return optionFun(func(s *Server) {
+		s.config.Host = host
+	})
 }
 
 func WithPort(port int) Option {
@@ -239,6 +503,80 @@ func WithPort(port int) Option {
 	})
 }
 
+// WithMaxConnections sets max number of connections, client gets error 1040 if it's exceeded.
+func WithMaxConnections(n int) Option {
+	return optionFun(func(s *Server) {
+		s.config.MaxConnections = n
+	})
+}
+
+// WithMaxUserConnections sets max number of connections of each account, client gets
+// error 1203 if it's exceeded. UserProvider can limit account by implementing UserConnectionLimiter.
+func WithMaxUserConnections(n int) Option {
+	return optionFun(func(s *Server) {
+		s.config.MaxUserConnections = n
+	})
+}
+
+// WithWaitTimeout sets how long non-interactive session can be idle before it's closed.
+func WithWaitTimeout(timeout time.Duration) Option {
+	return optionFun(func(s *Server) {
+		s.config.WaitTimeout = timeout
+	})
+}
+
+// WithInteractiveTimeout sets how long session of client with CLIENT_INTERACTIVE
+// can be idle before it's closed.
+func WithInteractiveTimeout(timeout time.Duration) Option {
+	return optionFun(func(s *Server) {
+		s.config.InteractiveTimeout = timeout
+	})
+}
+
+// WithConnectTimeout sets how long connection phase can take, default is 10 seconds.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return optionFun(func(s *Server) {
+		s.config.ConnectTimeout = timeout
+	})
+}
+
+// WithMaxAllowedPacket sets max payload size of packet sent by client, default is 64MB.
+// Client gets error 1153 if it's exceeded.
+func WithMaxAllowedPacket(n int) Option {
+	return optionFun(func(s *Server) {
+		s.config.MaxAllowedPacket = n
+	})
+}
+
+// WithCheckPrivileges enables checking privileges of statements before Handler performs them,
+// it works if UserProvider implements PrivilegeProvider. Client gets error 1044 1142 1143 or 1227
+// if privileges are missing, like MySQL. Statements whose privileges are unknown require SUPER,
+// and PREPARE from user variable is rejected.
+func WithCheckPrivileges(check bool) Option {
+	return optionFun(func(s *Server) {
+		s.config.CheckPrivileges = check
+	})
+}
+
+// WithProxyProtocol accepts PROXY protocol v1 and v2 headers sent by proxies such as HAProxy,
+// so that client address sent by proxy is used to match accounts and is returned by Session.RemoteAddr.
+// Networks are sources trusted to send header, which are IP, CIDR, localhost for Unix socket,
+// or * for all sources. Connection from trusted source must send header, other connections
+// are served as they are.
+func WithProxyProtocol(networks ...string) Option {
+	return optionFun(func(s *Server) {
+		s.config.ProxyProtocolNetworks = networks
+	})
+}
+
+// WithSystemVariables sets registry of system variables, it can be shared by servers.
+// Default registry has common variables queried by connectors.
+func WithSystemVariables(variables *SystemVariables) Option {
+	return optionFun(func(s *Server) {
+		s.config.Variables = variables
+	})
+}
+
 func WithVersion(version string) Option {
 	return optionFun(func(s *Server) {
 		s.config.Version = version
@@ -263,6 +601,12 @@ func WithSHA2Cache(cache SHA2Cache) Option {
 	})
 }
 
+func WithPasswordVerifier(verifier PasswordVerifier) Option {
+	return optionFun(func(s *Server) {
+		s.config.PasswordVerifier = verifier
+	})
+}
+
 func WithLogger(logger Logger) Option {
 	return optionFun(func(s *Server) {
 		s.config.Logger = logger
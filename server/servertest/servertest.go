@@ -0,0 +1,35 @@
+// Package servertest provides in-process server for tests, like net/http/httptest.
+package servertest
+
+import (
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/server"
+	"io/ioutil"
+	"net"
+)
+
+// NewServer creates server serving handler with users, which are root@% without password
+// if users are not given. Logs are discarded unless opts contains server.WithLogger.
+// It panics if users can't be created, because it's only used by tests.
+func NewServer(handler server.Handler, users []*server.CreateUserRequest, opts ...server.Option) *server.Server {
+	if len(users) == 0 {
+		users = []*server.CreateUserRequest{{User: "root", Host: "%", Method: auth.MySQLNativePassword}}
+	}
+	userProvider := server.NewMemoryUserProvider()
+	for _, r := range users {
+		if err := userProvider.Create(r); err != nil {
+			panic(err)
+		}
+	}
+	opts = append([]server.Option{server.WithLogger(server.NewDefaultLogger(server.ErrorLevel, ioutil.Discard))}, opts...)
+	return server.NewServer(userProvider, handler, opts...)
+}
+
+// Dialer returns dial function for client.WithDialer, every connection is served by srv through net.Pipe.
+func Dialer(srv *server.Server) func(network, address string) (net.Conn, error) {
+	return func(network, address string) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		go srv.ServeConn(serverConn)
+		return clientConn, nil
+	}
+}
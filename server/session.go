@@ -0,0 +1,206 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/charset"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"net"
+	"sync"
+)
+
+// Session is state of an authenticated connection, it's passed to Handler for every command.
+type Session struct {
+	conn mysql.Conn
+	// salt sent in handshake, it's used by COM_CHANGE_USER
+	salt []byte
+
+	mu sync.RWMutex
+	// key of UserProvider
+	key       string
+	user      string
+	host      string
+	collation *charset.Collation
+	attrs     map[string]string
+	database  string
+	status    flag.Status
+	values    map[interface{}]interface{}
+	// session values of system variables
+	variables map[string]string
+	sysVars   *SystemVariables
+
+	// password is expired, session is in sandbox mode
+	passwordExpired bool
+
+	// prepared statements
+	stmts      map[uint32]*Stmt
+	lastStmtId uint32
+}
+
+func newSession(conn mysql.Conn, sysVars *SystemVariables) *Session {
+	return &Session{
+		conn:      conn,
+		attrs:     make(map[string]string),
+		status:    flag.ServerStatusAutocommit,
+		values:    make(map[interface{}]interface{}),
+		variables: make(map[string]string),
+		sysVars:   sysVars,
+	}
+}
+
+func (s *Session) ConnectionId() uint32 {
+	return s.conn.ConnectionId()
+}
+
+// Key return key of UserProvider matched by user and host.
+func (s *Session) Key() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.key
+}
+
+// User return user name sent by client.
+func (s *Session) User() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.user
+}
+
+// Host return host of client.
+func (s *Session) Host() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.host
+}
+
+// PasswordExpired reports whether password of account is expired, session can only change password
+// until it's changed, like MySQL sandbox mode.
+func (s *Session) PasswordExpired() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.passwordExpired
+}
+
+func (s *Session) setPasswordExpired(expired bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.passwordExpired = expired
+}
+
+func (s *Session) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+func (s *Session) TLSed() bool {
+	return s.conn.TLSed()
+}
+
+// Capabilities return capabilities negotiated with client.
+func (s *Session) Capabilities() flag.Capability {
+	return s.conn.Capabilities()
+}
+
+// Collation return character set sent by client in handshake, or changed by SET NAMES.
+func (s *Session) Collation() *charset.Collation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.collation
+}
+
+// Attributes return connection attributes sent by client, don't modify it.
+func (s *Session) Attributes() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.attrs
+}
+
+// Database return current database.
+func (s *Session) Database() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.database
+}
+
+// SetDatabase changes current database, such as handling USE statement.
+func (s *Session) SetDatabase(database string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.database = database
+}
+
+// Status return status flags sent to client in OK and EOF packet.
+func (s *Session) Status() flag.Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+func (s *Session) SetStatus(status flag.Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// Value return value stored by Set, nil if not found.
+func (s *Session) Value(key interface{}) interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[key]
+}
+
+// Set stores value in session, it's dropped when the connection is closed.
+func (s *Session) Set(key, val interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = val
+}
+
+func (s *Session) Delete(key interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+// reset clears session state like COM_RESET_CONNECTION, prepared statements
+// should be closed before.
+func (s *Session) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = flag.ServerStatusAutocommit
+	s.values = make(map[interface{}]interface{})
+	s.variables = make(map[string]string)
+}
+
+// Conn return underlying connection.
+func (s *Session) Conn() mysql.Conn {
+	return s.conn
+}
+
+func (s *Session) writeOK(r *mysql.Result) error {
+	result := *r
+	if result.Status == 0 {
+		result.Status = s.Status()
+	} else {
+		s.SetStatus(result.Status)
+	}
+	return result.Write(s.conn)
+}
+
+// writeError sends err to client, error not created by myerrors is sent as its message,
+// so that client never waits for the response. *packet.ERR, such as error returned by
+// client of another server, is sent as it is.
+func (s *Session) writeError(err error) error {
+	return writeError(s.conn, err)
+}
+
+func writeError(conn mysql.Conn, err error) error {
+	if errPkt, ok := err.(*packet.ERR); ok {
+		return conn.WritePacket(errPkt)
+	}
+	if !myerrors.Is(err) {
+		err = myerrors.NewServer(code.ErrSendToClient, err.Error())
+	}
+	return conn.WriteError(err)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/client"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+type sessionHandler struct {
+	DefaultHandler
+	sessions chan *Session
+}
+
+func (h *sessionHandler) Query(session *Session, query string) (interface{}, error) {
+	session.Set("last", query)
+	h.sessions <- session
+	return h.DefaultHandler.Query(session, query)
+}
+
+func TestSession(t *testing.T) {
+	userProvider := newUserProvider(t, &CreateUserRequest{User: "root", Host: "%", Password: "123456"})
+	h := &sessionHandler{sessions: make(chan *Session, 1)}
+	srv := newTestServer(userProvider, h)
+
+	conn, err := client.CreateConnection(
+		client.WithDialer(pipeDialer(srv)),
+		client.WithUser("root"),
+		client.WithPassword("123456"),
+		client.WithAttribute("program_name", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Exec("USE db1"); err != nil {
+		t.Fatal(err)
+	}
+	session := <-h.sessions
+	if session.User() != "root" || session.ConnectionId() != conn.ConnectionId() {
+		t.Fatalf("unexpected session: user=%s id=%d", session.User(), session.ConnectionId())
+	}
+	if session.Attributes()["program_name"] != "test" {
+		t.Fatalf("unexpected attributes: %v", session.Attributes())
+	}
+
+	if _, err := conn.Exec("CREATE DATABASE db2"); err != nil {
+		t.Fatal(err)
+	}
+	session = <-h.sessions
+	if session.Database() != "db1" {
+		t.Fatalf("expected current database db1, got %q", session.Database())
+	}
+	if v := session.Value("last"); v != "CREATE DATABASE db2" {
+		t.Fatalf("unexpected session value: %v", v)
+	}
+}
+
+// newUserProvider creates users, or root@% without password if users are not given.
+func newUserProvider(t *testing.T, users ...*CreateUserRequest) *memoryUserProvider {
+	t.Helper()
+	if len(users) == 0 {
+		users = []*CreateUserRequest{{User: "root", Host: "%", Method: auth.MySQLNativePassword}}
+	}
+	userProvider := NewMemoryUserProvider()
+	for _, r := range users {
+		if err := userProvider.Create(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return userProvider
+}
+
+// newTestServer discards logs unless opts contains WithLogger.
+func newTestServer(userProvider UserProvider, h Handler, opts ...Option) *Server {
+	opts = append([]Option{WithLogger(NewDefaultLogger(ErrorLevel, ioutil.Discard))}, opts...)
+	return NewServer(userProvider, h, opts...)
+}
+
+func pipeDialer(srv *Server) func(network, address string) (net.Conn, error) {
+	return func(network, address string) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		go srv.ServeConn(serverConn)
+		return clientConn, nil
+	}
+}
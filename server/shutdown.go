@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrServerClosed = errors.New("server: Server closed")
+)
+
+const (
+	shutdownPollInterval = 50 * time.Millisecond
+)
+
+// trackedConn is a live connection in registry of Server.
+type trackedConn struct {
+	conn mysql.Conn
+
+	mu      sync.Mutex
+	session *Session // nil before authentication finished
+	idle    bool     // waiting for next command
+	closing bool     // closed by Shutdown or Close
+}
+
+// setIdle changes state of connection, it returns false if the connection is closing.
+func (tc *trackedConn) setIdle(idle bool) bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.closing {
+		return false
+	}
+	tc.idle = idle
+	return true
+}
+
+func (tc *trackedConn) setSession(session *Session) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.session = session
+}
+
+// closeIfIdle interrupts reading of idle connection, then the connection
+// sends shutdown error to client and closes itself.
+func (tc *trackedConn) closeIfIdle() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if !tc.idle || tc.closing {
+		return
+	}
+	tc.closing = true
+	tc.conn.SetReadDeadline(time.Unix(1, 0))
+}
+
+func (tc *trackedConn) close() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.closing = true
+	tc.conn.Close()
+}
+
+// Shutdown gracefully shuts down the server. It closes all listeners, then waits for
+// in-flight commands to complete, sends ERR 1053 to idle sessions and closes them.
+//
+// If ctx expires before all connections are closed, Shutdown returns ctx.Err(),
+// call Close to close remaining connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.inShutdown, 1)
+
+	s.mu.Lock()
+	err := s.closeListenersLocked()
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.closeIdleConns() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close immediately closes all listeners and connections.
+func (s *Server) Close() error {
+	atomic.StoreInt32(&s.inShutdown, 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.closeListenersLocked()
+	for _, tc := range s.conns {
+		tc.close()
+	}
+	return err
+}
+
+// Sessions return authenticated sessions of live connections.
+func (s *Server) Sessions() []*Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]*Session, 0, len(s.conns))
+	for _, tc := range s.conns {
+		tc.mu.Lock()
+		if tc.session != nil {
+			sessions = append(sessions, tc.session)
+		}
+		tc.mu.Unlock()
+	}
+	return sessions
+}
+
+func (s *Server) shuttingDown() bool {
+	return atomic.LoadInt32(&s.inShutdown) != 0
+}
+
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.shuttingDown() {
+			return false
+		}
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+		s.listeners[l] = struct{}{}
+	} else {
+		delete(s.listeners, l)
+	}
+	return true
+}
+
+func (s *Server) closeListenersLocked() error {
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	s.listeners = nil
+	return err
+}
+
+// trackConn adds connection to registry, it returns error sent to client if server is
+// shutting down or has max connections.
+func (s *Server) trackConn(conn mysql.Conn) (*trackedConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown() {
+		return nil, myerrors.ServerShutdown.Build()
+	}
+	if max := s.config.MaxConnections; max > 0 && len(s.conns) >= max {
+		return nil, myerrors.ConCount.Build()
+	}
+	if s.conns == nil {
+		s.conns = make(map[uint32]*trackedConn)
+	}
+	tc := &trackedConn{conn: conn}
+	s.conns[conn.ConnectionId()] = tc
+	return tc, nil
+}
+
+func (s *Server) untrackConn(tc *trackedConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, tc.conn.ConnectionId())
+}
+
+// closeIdleConns return true if there is no live connection.
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tc := range s.conns {
+		tc.closeIfIdle()
+	}
+	return len(s.conns) == 0
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"net"
+	"testing"
+	"time"
+)
+
+type blockingHandler struct {
+	DefaultHandler
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHandler) Query(session *Session, query string) (interface{}, error) {
+	h.started <- struct{}{}
+	<-h.release
+	return &mysql.Result{AffectedRows: 1}, nil
+}
+
+func TestShutdown(t *testing.T) {
+	h := &blockingHandler{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	srv := newTestServer(newUserProvider(t), h)
+
+	// use TCP rather than net.Pipe, because shutdown error is written without client reading it
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(l)
+	}()
+
+	connect := func() (*client.Conn, error) {
+		return client.CreateConnection(
+			client.WithHost("127.0.0.1"),
+			client.WithPort(l.Addr().(*net.TCPAddr).Port),
+			client.WithUser("root"))
+	}
+	busy, err := connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	idle, err := connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+
+	execErr := make(chan error, 1)
+	go func() {
+		_, err := busy.Exec("UPDATE t SET a = 1")
+		execErr <- err
+	}()
+	<-h.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- srv.Shutdown(ctx)
+	}()
+
+	// idle connection is closed, in-flight command is still running
+	if err := idle.Ping(); err == nil {
+		t.Fatal("expected error on idle connection")
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned before in-flight command finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := len(srv.Sessions()); n != 1 {
+		t.Fatalf("expected 1 session, got %d", n)
+	}
+
+	close(h.release)
+	if err := <-execErr; err != nil {
+		t.Fatalf("in-flight command failed: %v", err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Sessions()); n != 0 {
+		t.Fatalf("expected no session, got %d", n)
+	}
+	if err := <-serveErr; err != ErrServerClosed {
+		t.Fatalf("expected ErrServerClosed, got %v", err)
+	}
+
+	if _, err := connect(); err == nil {
+		t.Fatal("expected error connecting to shut down server")
+	}
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/vczyh/mysql-protocol/charset"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+	"time"
+)
+
+// StmtCommand is optionally implemented by Handler to support prepared statements,
+// otherwise COM_STMT_* commands are passed to Handler.Other.
+// https://dev.mysql.com/doc/internals/en/prepared-statements.html
+type StmtCommand interface {
+	// Prepare return parameter count and result set columns of query,
+	// columns is empty if the statement doesn't return result set.
+	Prepare(session *Session, query string) (paramCount int, columns []mysql.Column, err error)
+
+	// Execute performs prepared statement with decoded args and should return
+	// *mysql.Result, *ResultSet, RowIterator or Response like Query.
+	//
+	// Parameters sent by COM_STMT_SEND_LONG_DATA are []byte.
+	Execute(session *Session, stmt *Stmt, args []interface{}) (interface{}, error)
+
+	// CloseStmt is called when the statement is closed by client or connection is closed.
+	CloseStmt(session *Session, stmt *Stmt)
+}
+
+// Stmt is a prepared statement of session.
+type Stmt struct {
+	Id         uint32
+	Query      string
+	ParamCount int
+	Columns    []mysql.Column
+
+	// param types bound by the last COM_STMT_EXECUTE
+	paramTypes []byte
+	// data sent by COM_STMT_SEND_LONG_DATA, key is param id
+	longData map[int][]byte
+}
+
+func (s *Server) handleStmtPrepare(session *Session, h StmtCommand, query string) error {
+	if err := s.checkQueryPrivileges(session, query); err != nil {
+		return session.writeError(err)
+	}
+	paramCount, columns, err := h.Prepare(session, query)
+	if err != nil {
+		return session.writeError(err)
+	}
+
+	stmt := session.addStmt(query, paramCount, columns)
+	conn := session.conn
+	if err := conn.WritePacket(&packet.StmtPrepareOKFirst{
+		StmtId:      stmt.Id,
+		ColumnCount: uint16(len(columns)),
+		ParamCount:  uint16(paramCount),
+	}); err != nil {
+		return err
+	}
+
+	if paramCount > 0 {
+		params := make([]mysql.Column, paramCount)
+		for i := range params {
+			params[i] = mysql.Column{Name: "?", Type: flag.MySQLTypeVarString}
+		}
+		if err := writeColumnDefinitions(conn, params, session.Status()); err != nil {
+			return err
+		}
+	}
+	if len(columns) > 0 {
+		if err := writeColumnDefinitions(conn, columns, session.Status()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleStmtExecute(session *Session, h StmtCommand, data []byte) error {
+	if len(data) < 5 {
+		return session.writeError(myerrors.MalformedPacket.Build())
+	}
+	stmtId := uint32(packet.FixedLengthInteger.Get(data[1:5]))
+	stmt := session.Stmt(stmtId)
+	if stmt == nil {
+		return session.writeError(myerrors.UnknownStmtHandler.Build(stmtId, "mysqld_stmt_execute"))
+	}
+
+	args, err := stmt.decodeArgs(data)
+	if err != nil {
+		return session.writeError(err)
+	}
+	// long data is used by one execution
+	stmt.longData = nil
+
+	rs, err := h.Execute(session, stmt, args)
+	if err != nil {
+		return session.writeError(err)
+	}
+	switch v := rs.(type) {
+	case *mysql.Result:
+		return session.writeOK(v)
+	case *ResultSet:
+		return v.writeBinary(session.conn, session.Status())
+	case RowIterator:
+		return writeRows(session.conn, v, session.Status(), true)
+	case Response:
+		return v.WriteResponse(session)
+	case nil:
+		return session.writeOK(&mysql.Result{})
+	default:
+		return session.writeError(myerrors.InternalError.Build(fmt.Sprintf("unexpected execute result %T", v)))
+	}
+}
+
+func (s *Server) handleStmtSendLongData(session *Session, data []byte) error {
+	// no response even if error occurs
+	pkt, err := packet.ParseStmtSendLongData(data)
+	if err != nil {
+		return nil
+	}
+	stmt := session.Stmt(pkt.StmtId)
+	if stmt == nil || int(pkt.ParamId) >= stmt.ParamCount {
+		return nil
+	}
+
+	if stmt.longData == nil {
+		stmt.longData = make(map[int][]byte)
+	}
+	stmt.longData[int(pkt.ParamId)] = append(stmt.longData[int(pkt.ParamId)], pkt.Data...)
+	return nil
+}
+
+func (s *Server) handleStmtReset(session *Session, data []byte) error {
+	if len(data) < 5 {
+		return session.writeError(myerrors.MalformedPacket.Build())
+	}
+	stmtId := uint32(packet.FixedLengthInteger.Get(data[1:5]))
+	stmt := session.Stmt(stmtId)
+	if stmt == nil {
+		return session.writeError(myerrors.UnknownStmtHandler.Build(stmtId, "mysqld_stmt_reset"))
+	}
+	stmt.longData = nil
+	return session.writeOK(&mysql.Result{})
+}
+
+func (s *Server) handleStmtClose(session *Session, h StmtCommand, data []byte) error {
+	// no response
+	if len(data) < 5 {
+		return nil
+	}
+	stmtId := uint32(packet.FixedLengthInteger.Get(data[1:5]))
+	if stmt := session.removeStmt(stmtId); stmt != nil {
+		h.CloseStmt(session, stmt)
+	}
+	return nil
+}
+
+func (stmt *Stmt) decodeArgs(data []byte) ([]interface{}, error) {
+	pkt, err := packet.ParseStmtExecute(data, stmt.ParamCount)
+	if err != nil {
+		return nil, myerrors.MalformedPacket.Build()
+	}
+	if stmt.ParamCount == 0 {
+		return nil, nil
+	}
+
+	if pkt.NewParamsBoundFlag == 1 {
+		stmt.paramTypes = append(stmt.paramTypes[:0], pkt.ParamType...)
+	}
+	if len(stmt.paramTypes) != stmt.ParamCount*2 {
+		return nil, myerrors.WrongArguments.Build("mysqld_stmt_execute")
+	}
+
+	args := make([]interface{}, stmt.ParamCount)
+	buf := bytes.NewBuffer(pkt.ParamValue)
+	for i := range args {
+		if long, ok := stmt.longData[i]; ok {
+			args[i] = long
+			continue
+		}
+		if pkt.NullBitMapGet(i) {
+			continue
+		}
+
+		columnType := flag.TableColumnType(stmt.paramTypes[i*2])
+		unsigned := stmt.paramTypes[i*2+1]&0x80 != 0
+		if args[i], err = packet.ParseBinaryValue(buf, columnType, unsigned, time.Local); err != nil {
+			return nil, myerrors.MalformedPacket.Build()
+		}
+	}
+	return args, nil
+}
+
+func (s *Session) addStmt(query string, paramCount int, columns []mysql.Column) *Stmt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastStmtId++
+	stmt := &Stmt{
+		Id:         s.lastStmtId,
+		Query:      query,
+		ParamCount: paramCount,
+		Columns:    columns,
+	}
+	if s.stmts == nil {
+		s.stmts = make(map[uint32]*Stmt)
+	}
+	s.stmts[stmt.Id] = stmt
+	return stmt
+}
+
+// Stmt return prepared statement by id, nil if not found.
+func (s *Session) Stmt(id uint32) *Stmt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stmts[id]
+}
+
+func (s *Session) removeStmt(id uint32) *Stmt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stmt := s.stmts[id]
+	delete(s.stmts, id)
+	return stmt
+}
+
+// closeStmts closes all prepared statements when the connection is closed.
+func (s *Server) closeStmts(session *Session) {
+	h, ok := s.config.Handler.(StmtCommand)
+	if !ok {
+		return
+	}
+
+	session.mu.Lock()
+	stmts := session.stmts
+	session.stmts = nil
+	session.mu.Unlock()
+
+	for _, stmt := range stmts {
+		h.CloseStmt(session, stmt)
+	}
+}
+
+func writeColumnDefinitions(conn mysql.Conn, columns []mysql.Column, status flag.Status) error {
+	for _, column := range columnDefinitionPackets(columns) {
+		if err := conn.WritePacket(column); err != nil {
+			return err
+		}
+	}
+	// TODO  CLIENT_DEPRECATE_EOF
+	return conn.WritePacket(packet.NewEOF(0, status))
+}
+
+// defaultColumnCollation return collation of column, or default one if it's not set.
+func defaultColumnCollation(column *mysql.Column) *charset.Collation {
+	if column.CharSet != nil {
+		return column.CharSet
+	}
+
+	name := charset.Binary
+	switch column.Type {
+	case flag.MySQLTypeVarchar, flag.MySQLTypeVarString, flag.MySQLTypeString,
+		flag.MySQLTypeEnum, flag.MySQLTypeSet, flag.MySQLTypeJson:
+		name = charset.UTF8MB40900AiCi
+	}
+	collation, _ := charset.GetCollationByName(name)
+	return collation
+}
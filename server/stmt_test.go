@@ -0,0 +1,108 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type echoHandler struct {
+	DefaultHandler
+	closed chan uint32
+}
+
+func (h *echoHandler) Execute(session *Session, stmt *Stmt, args []interface{}) (interface{}, error) {
+	names := make([]string, len(args))
+	for i := range args {
+		names[i] = "?"
+	}
+	return NewSimpleResultSet(names, [][]interface{}{args})
+}
+
+func (h *echoHandler) CloseStmt(session *Session, stmt *Stmt) {
+	h.closed <- stmt.Id
+}
+
+func TestPreparedStatement(t *testing.T) {
+	h := &echoHandler{closed: make(chan uint32, 1)}
+	srv := newTestServer(newUserProvider(t), h)
+
+	conn, err := client.CreateConnection(client.WithDialer(pipeDialer(srv)), client.WithUser("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stmt, err := conn.Prepare("SELECT ?, ?, ?, ?, ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt.ParamCount() != 5 || len(stmt.Columns()) != 1 {
+		t.Fatalf("unexpected statement: params=%d columns=%d", stmt.ParamCount(), len(stmt.Columns()))
+	}
+
+	dt := time.Date(2021, 1, 2, 3, 4, 5, 6000, time.Local)
+	rows, err := stmt.Query(int64(-1), "hello", 1.5, nil, dt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := rows.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{int64(-1), []byte("hello"), 1.5, nil, dt}
+	for i := range want {
+		if got := row[i].Value(); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("column %d: got %#v, want %#v", i, got, want[i])
+		}
+	}
+	if _, err := rows.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	if err := stmt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if id := <-h.closed; id != stmt.Id() {
+		t.Fatalf("expected statement %d closed, got %d", stmt.Id(), id)
+	}
+	if _, err := stmt.Exec(1, 2, 3, 4, 5); err == nil {
+		t.Fatal("expected unknown statement error")
+	}
+}
+
+func TestDefaultHandlerExecute(t *testing.T) {
+	srv := newTestServer(newUserProvider(t), NewDefaultHandler())
+	conn, err := client.CreateConnection(client.WithDialer(pipeDialer(srv)), client.WithUser("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stmt, err := conn.Prepare("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := stmt.Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rows.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rows.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	// parameters aren't substituted, so statement with parameters is rejected
+	stmt, err = conn.Prepare("SELECT ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.Query(1); errorCode(err) != code.ErrNotSupportedYet {
+		t.Fatalf("expected error %d, got %v", code.ErrNotSupportedYet, err)
+	}
+}
@@ -1,16 +1,20 @@
 package server
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"github.com/vczyh/mysql-protocol/auth"
+	"net"
+	"sort"
 	"sync"
+	"time"
 )
 
 var (
 	ErrAccessDenied                      = errors.New("server: matching user not found")
 	ErrUserExisted                       = errors.New("server: user existed")
-	ErrInvalidAuthenticationStringFormat = errors.New("server: invalid authentication string format")
+	ErrInvalidAuthenticationStringFormat = auth.ErrInvalidAuthenticationStringFormat
 )
 
 // UserProvider performs Authentication and Authorization.
@@ -48,31 +52,116 @@ type AuthorizationRequest struct {
 	// expand more params
 }
 
+// memoryUserProvider matches accounts like MySQL, see https://dev.mysql.com/doc/refman/8.0/en/connection-access.html.
+//
+// Host of account can be host name, IP, IP/netmask, CIDR, or pattern containing % and _ wildcards,
+// empty host is the same as %. Empty user is anonymous user matching any user name.
+// Accounts are sorted by host from the most specific to the least specific, anonymous user is after
+// other users of the same host, and the first account matching client is used.
 type memoryUserProvider struct {
-	users sync.Map
+	users    sync.Map
+	resolver HostResolver
+
+	// protects sorted accounts, authentication strings and status of accounts
+	mu sync.RWMutex
+	// sorted accounts
+	sorted []*user
+
+	now func() time.Time
 }
 
 type user struct {
 	Name                 string
 	Host                 string
+	host                 *hostPattern
 	AuthenticationString []byte
 	method               auth.Method
 	TLSRequired          bool
+	MaxUserConnections   int
+	Grants               []Grant
+
+	accountLocked       bool
+	passwordExpired     bool
+	passwordLifetime    time.Duration
+	passwordLastChanged time.Time
+	failedLoginAttempts int
+	passwordLockTime    time.Duration
+	// consecutive failed logins, and when account is blocked by them
+	failedLogins int
+	blockedAt    time.Time
+
+	// source identifies password account is created with, passwordChanged reports
+	// whether password is changed by ChangePassword after creating
+	source          [sha256.Size]byte
+	passwordChanged bool
+}
+
+// AccountState is state of account changed by clients, which are consecutive failed logins
+// and password changed by ChangePassword. It's restored to account recreated with the same
+// user and host, so that reloading accounts doesn't unblock accounts or revert passwords.
+type AccountState struct {
+	failedLoginAttempts int
+	passwordLockTime    time.Duration
+	failedLogins        int
+	blockedAt           time.Time
+
+	source [sha256.Size]byte
+	// nil if password isn't changed
+	authenticationString []byte
+	passwordLastChanged  time.Time
 }
 
 type CreateUserRequest struct {
-	User        string
-	Host        string
-	Password    string
-	Method      auth.Method
+	User     string
+	Host     string
+	Password string
+	Method   auth.Method
+
+	// AuthenticationString is used instead of Password if it's not nil,
+	// such as authentication_string column of mysql.user table.
+	AuthenticationString []byte
+
 	TLSRequired bool
+
+	// MaxUserConnections limits connections of the account, 0 means global limit is used.
+	MaxUserConnections int
+
+	// Grants are privileges of the account, they are checked if WithCheckPrivileges is true.
+	Grants []Grant
+
+	// AccountLocked locks the account like ACCOUNT LOCK.
+	AccountLocked bool
+
+	// PasswordExpired expires password like PASSWORD EXPIRE, client can only change password.
+	PasswordExpired bool
+
+	// PasswordLifetime expires password when it's changed for the lifetime, 0 means password never expires.
+	PasswordLifetime time.Duration
+
+	// PasswordLastChanged is when password was changed, default is now.
+	PasswordLastChanged time.Time
+
+	// FailedLoginAttempts blocks the account for PasswordLockTime after consecutive failed logins,
+	// 0 means failed logins are not tracked.
+	FailedLoginAttempts int
+
+	// PasswordLockTime is how long the account is blocked, negative means it's blocked until it's unlocked
+	// like PASSWORD_LOCK_TIME UNBOUNDED, 0 means the account is never blocked.
+	PasswordLockTime time.Duration
 }
 
-func NewMemoryUserProvider() *memoryUserProvider {
-	return &memoryUserProvider{}
+func NewMemoryUserProvider(opts ...UserProviderOption) *memoryUserProvider {
+	mp := &memoryUserProvider{resolver: net.DefaultResolver, now: time.Now}
+	for _, opt := range opts {
+		opt.apply(mp)
+	}
+	return mp
 }
 
 func (mp *memoryUserProvider) Create(r *CreateUserRequest) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
 	key := mp.userKey(r.User, r.Host)
 	if _, ok := mp.users.Load(key); ok {
 		return ErrUserExisted
@@ -81,22 +170,73 @@ func (mp *memoryUserProvider) Create(r *CreateUserRequest) error {
 	user := &user{
 		Name:        r.User,
 		Host:        r.Host,
+		host:        parseHostPattern(r.Host),
 		method:      r.Method,
 		TLSRequired: r.TLSRequired,
+
+		MaxUserConnections: r.MaxUserConnections,
+		Grants:             r.Grants,
+
+		accountLocked:       r.AccountLocked,
+		passwordExpired:     r.PasswordExpired,
+		passwordLifetime:    r.PasswordLifetime,
+		passwordLastChanged: r.PasswordLastChanged,
+		failedLoginAttempts: r.FailedLoginAttempts,
+		passwordLockTime:    r.PasswordLockTime,
+
+		source: passwordSource(r),
+	}
+	if user.passwordLastChanged.IsZero() {
+		user.passwordLastChanged = mp.now()
 	}
 
-	authenticationString, err := user.method.GenerateAuthenticationStringWithoutSalt([]byte(r.Password))
-	if err != nil {
-		return err
+	user.AuthenticationString = r.AuthenticationString
+	if user.AuthenticationString == nil {
+		authenticationString, err := user.method.GenerateAuthenticationStringWithoutSalt([]byte(r.Password))
+		if err != nil {
+			return err
+		}
+		user.AuthenticationString = authenticationString
 	}
-	user.AuthenticationString = authenticationString
 
 	mp.users.Store(key, user)
+	mp.sort(user)
 	return nil
 }
 
+// passwordSource return digest of password or authentication string and plugin of account,
+// plaintext password isn't kept after creating account.
+func passwordSource(r *CreateUserRequest) [sha256.Size]byte {
+	if r.AuthenticationString != nil {
+		return sha256.Sum256([]byte(r.Method.String() + ":as:" + string(r.AuthenticationString)))
+	}
+	return sha256.Sum256([]byte(r.Method.String() + ":password:" + r.Password))
+}
+
+// sort inserts u into sorted accounts after accounts as specific as u.
+func (mp *memoryUserProvider) sort(u *user) {
+	i := sort.Search(len(mp.sorted), func(i int) bool {
+		return moreSpecificUser(u, mp.sorted[i])
+	})
+	mp.sorted = append(mp.sorted, nil)
+	copy(mp.sorted[i+1:], mp.sorted[i:])
+	mp.sorted[i] = u
+}
+
+func moreSpecificUser(u, other *user) bool {
+	if u.host.moreSpecific(other.host) {
+		return true
+	}
+	if other.host.moreSpecific(u.host) {
+		return false
+	}
+	return u.Name != "" && other.Name == ""
+}
+
+// Key return key of the first sorted account matching user and host,
+// host is client IP, or host name such as localhost of Unix socket.
 func (mp *memoryUserProvider) Key(user, host string) (string, error) {
-	u := mp.simpleBestMatch(user, host)
+	u := mp.bestMatch(user, host)
 	if u == nil {
 		return "", ErrAccessDenied
 	}
@@ -108,6 +248,8 @@ func (mp *memoryUserProvider) AuthenticationString(key string) ([]byte, error) {
 	if u == nil {
 		return nil, ErrAccessDenied
 	}
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
 	return u.AuthenticationString, nil
 }
 
@@ -131,6 +273,186 @@ func (mp *memoryUserProvider) Authorization(key string, r *AuthorizationRequest)
 	return nil
 }
 
+func (mp *memoryUserProvider) MaxUserConnections(key string) (int, error) {
+	u := mp.getUser(key)
+	if u == nil {
+		return 0, ErrAccessDenied
+	}
+	return u.MaxUserConnections, nil
+}
+
+func (mp *memoryUserProvider) Grants(key string) ([]Grant, error) {
+	u := mp.getUser(key)
+	if u == nil {
+		return nil, ErrAccessDenied
+	}
+	return u.Grants, nil
+}
+
+func (mp *memoryUserProvider) AccountStatus(key string) (*AccountStatus, error) {
+	u := mp.getUser(key)
+	if u == nil {
+		return nil, ErrAccessDenied
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.status(u), nil
+}
+
+func (mp *memoryUserProvider) LoginFailed(key string) (*AccountStatus, error) {
+	u := mp.getUser(key)
+	if u == nil {
+		return nil, ErrAccessDenied
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	status := mp.status(u)
+	if status.Blocked || u.failedLoginAttempts <= 0 || u.passwordLockTime == 0 {
+		return status, nil
+	}
+	u.failedLogins++
+	if u.failedLogins >= u.failedLoginAttempts {
+		u.blockedAt = mp.now()
+		status = mp.status(u)
+	}
+	return status, nil
+}
+
+func (mp *memoryUserProvider) LoginSucceeded(key string) error {
+	u := mp.getUser(key)
+	if u == nil {
+		return ErrAccessDenied
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	u.failedLogins = 0
+	return nil
+}
+
+func (mp *memoryUserProvider) ChangePassword(key string, password []byte) error {
+	u := mp.getUser(key)
+	if u == nil {
+		return ErrAccessDenied
+	}
+	authenticationString, err := u.method.GenerateAuthenticationStringWithoutSalt(password)
+	if err != nil {
+		return err
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	u.AuthenticationString = authenticationString
+	u.passwordExpired = false
+	u.passwordLastChanged = mp.now()
+	u.passwordChanged = true
+	return nil
+}
+
+// AccountState return state of account changed by clients.
+func (mp *memoryUserProvider) AccountState(key string) (*AccountState, error) {
+	u := mp.getUser(key)
+	if u == nil {
+		return nil, ErrAccessDenied
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	state := &AccountState{
+		failedLoginAttempts: u.failedLoginAttempts,
+		passwordLockTime:    u.passwordLockTime,
+		failedLogins:        u.failedLogins,
+		blockedAt:           u.blockedAt,
+		source:              u.source,
+	}
+	if u.passwordChanged {
+		state.authenticationString = u.AuthenticationString
+		state.passwordLastChanged = u.passwordLastChanged
+	}
+	return state, nil
+}
+
+// RestoreAccountState restores state of account returned by AccountState, such as state of account
+// before reloading. Like ALTER USER, failed logins are reset if FailedLoginAttempts or PasswordLockTime
+// is changed, and changed password is not restored if account is created with another password.
+func (mp *memoryUserProvider) RestoreAccountState(key string, state *AccountState) error {
+	u := mp.getUser(key)
+	if u == nil {
+		return ErrAccessDenied
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if state.failedLoginAttempts == u.failedLoginAttempts && state.passwordLockTime == u.passwordLockTime {
+		u.failedLogins, u.blockedAt = state.failedLogins, state.blockedAt
+	}
+	if state.authenticationString != nil && state.source == u.source {
+		u.AuthenticationString = state.authenticationString
+		u.passwordExpired = false
+		u.passwordLastChanged = state.passwordLastChanged
+		u.passwordChanged = true
+	}
+	return nil
+}
+
+// Lock locks account like ALTER USER ... ACCOUNT LOCK.
+func (mp *memoryUserProvider) Lock(name, host string) error {
+	return mp.update(name, host, func(u *user) {
+		u.accountLocked = true
+	})
+}
+
+// Unlock unlocks account like ALTER USER ... ACCOUNT UNLOCK,
+// it also unblocks account blocked by consecutive failed logins.
+func (mp *memoryUserProvider) Unlock(name, host string) error {
+	return mp.update(name, host, func(u *user) {
+		u.accountLocked = false
+		u.failedLogins = 0
+		u.blockedAt = time.Time{}
+	})
+}
+
+// ExpirePassword expires password of account like ALTER USER ... PASSWORD EXPIRE.
+func (mp *memoryUserProvider) ExpirePassword(name, host string) error {
+	return mp.update(name, host, func(u *user) {
+		u.passwordExpired = true
+	})
+}
+
+func (mp *memoryUserProvider) update(name, host string, f func(u *user)) error {
+	u := mp.getUser(mp.userKey(name, host))
+	if u == nil {
+		return ErrAccessDenied
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	f(u)
+	return nil
+}
+
+// status return status of account, mu must be held.
+func (mp *memoryUserProvider) status(u *user) *AccountStatus {
+	now := mp.now()
+	status := &AccountStatus{
+		Locked:              u.accountLocked,
+		PasswordExpired:     u.passwordExpired,
+		FailedLoginAttempts: u.failedLoginAttempts,
+		PasswordLockTime:    u.passwordLockTime,
+	}
+	if u.passwordLifetime > 0 && now.Sub(u.passwordLastChanged) >= u.passwordLifetime {
+		status.PasswordExpired = true
+	}
+
+	if !u.blockedAt.IsZero() {
+		if u.passwordLockTime < 0 {
+			status.Blocked = true
+		} else if remaining := u.blockedAt.Add(u.passwordLockTime).Sub(now); remaining > 0 {
+			status.Blocked, status.Remaining = true, remaining
+		} else {
+			u.failedLogins = 0
+			u.blockedAt = time.Time{}
+		}
+	}
+	return status
+}
+
 func (mp *memoryUserProvider) userKey(user, host string) string {
 	return fmt.Sprintf("%s@%s", user, host)
 }
@@ -143,13 +465,51 @@ func (mp *memoryUserProvider) getUser(key string) *user {
 	return val.(*user)
 }
 
-func (mp *memoryUserProvider) simpleBestMatch(user, host string) *user {
-	if u := mp.getUser(mp.userKey(user, host)); u != nil {
-		return u
+func (mp *memoryUserProvider) bestMatch(userName, host string) *user {
+	ip := net.ParseIP(host)
+	var names []string
+	resolved := false
+	hostNames := func() []string {
+		if !resolved {
+			resolved = true
+			if mp.resolver != nil {
+				names = resolveHostNames(mp.resolver, ip)
+			}
+		}
+		return names
 	}
 
-	if u := mp.getUser(mp.userKey(user, "%")); u != nil {
-		return u
+	// host names are resolved lazily without holding mu, names and hosts of accounts are immutable
+	mp.mu.RLock()
+	sorted := make([]*user, len(mp.sorted))
+	copy(sorted, mp.sorted)
+	mp.mu.RUnlock()
+	for _, u := range sorted {
+		if u.Name != "" && u.Name != userName {
+			continue
+		}
+		if u.host.match(host, ip, hostNames) {
+			return u
+		}
 	}
 	return nil
 }
+
+// WithHostResolver sets resolver of client host names, default is net.DefaultResolver.
+// nil disables resolving, so that only accounts whose host is IP or pattern matching IP are matched,
+// like skip_name_resolve.
+func WithHostResolver(resolver HostResolver) UserProviderOption {
+	return userProviderOptionFun(func(mp *memoryUserProvider) {
+		mp.resolver = resolver
+	})
+}
+
+type UserProviderOption interface {
+	apply(*memoryUserProvider)
+}
+
+type userProviderOptionFun func(*memoryUserProvider)
+
+func (f userProviderOptionFun) apply(mp *memoryUserProvider) {
+	f(mp)
+}
@@ -0,0 +1,232 @@
+// Package userprovider provides UserProvider implements loading accounts from file or mysql.user
+// table of another server, instead of creating them in code.
+package userprovider
+
+import (
+	"fmt"
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/server"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Account is an account like row of mysql.user table.
+type Account struct {
+	User string `json:"user" yaml:"user"`
+	Host string `json:"host" yaml:"host"`
+
+	// Plugin is authentication plugin, default is mysql_native_password.
+	Plugin string `json:"plugin" yaml:"plugin"`
+
+	// AuthenticationString is hashing string generated by plugin, such as *6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9.
+	AuthenticationString string `json:"authentication_string" yaml:"authentication_string"`
+
+	// Password generates authentication string if AuthenticationString is empty.
+	Password string `json:"password" yaml:"password"`
+
+	// SSLType is ANY X509 or SPECIFIED if account requires TLS, client certificate isn't verified.
+	SSLType string `json:"ssl_type" yaml:"ssl_type"`
+
+	MaxUserConnections int `json:"max_user_connections" yaml:"max_user_connections"`
+
+	// Grants are privileges of account, they are checked if server.WithCheckPrivileges is true.
+	Grants []Grant `json:"grants" yaml:"grants"`
+
+	AccountLocked bool `json:"account_locked" yaml:"account_locked"`
+
+	// PasswordExpired expires password, client can only change password, which is kept after accounts
+	// are reloaded unless password of account is changed.
+	PasswordExpired bool `json:"password_expired" yaml:"password_expired"`
+
+	// PasswordLifetime is days password expires after PasswordLastChanged, 0 means password never expires.
+	PasswordLifetime int `json:"password_lifetime" yaml:"password_lifetime"`
+
+	// PasswordLastChanged is when password was changed, default is when accounts are loaded.
+	PasswordLastChanged time.Time `json:"password_last_changed" yaml:"password_last_changed"`
+
+	// FailedLoginAttempts blocks account for PasswordLockTime days after consecutive failed logins,
+	// -1 PasswordLockTime means account is blocked until they are changed. Blocked accounts are still
+	// blocked after accounts are reloaded, unless FailedLoginAttempts or PasswordLockTime is changed.
+	FailedLoginAttempts int `json:"failed_login_attempts" yaml:"failed_login_attempts"`
+	PasswordLockTime    int `json:"password_lock_time" yaml:"password_lock_time"`
+}
+
+// Grant is privileges on an object like GRANT statement, such as
+// GRANT SELECT, INSERT ON db.* which is {privileges: [SELECT, INSERT], database: db}.
+type Grant struct {
+	// Privileges are names of privileges, such as SELECT, ALL PRIVILEGES and GRANT OPTION.
+	Privileges []string `json:"privileges" yaml:"privileges"`
+
+	// Database is empty or * for global privileges, it can contain % and _ wildcards.
+	Database string `json:"database" yaml:"database"`
+
+	// Table is empty or * for database privileges.
+	Table string `json:"table" yaml:"table"`
+
+	Columns []string `json:"columns" yaml:"columns"`
+}
+
+func (g *Grant) grant() (server.Grant, error) {
+	sg := server.Grant{Database: g.Database, Table: g.Table, Columns: g.Columns}
+	for _, name := range g.Privileges {
+		p, err := server.ParsePrivilege(name)
+		if err != nil {
+			return sg, err
+		}
+		sg.Privileges |= p
+	}
+	return sg, nil
+}
+
+type memoryUserProvider interface {
+	server.UserProvider
+	server.UserConnectionLimiter
+	server.PrivilegeProvider
+	server.AccountManager
+	AccountState(key string) (*server.AccountState, error)
+	RestoreAccountState(key string, state *server.AccountState) error
+}
+
+func newMemoryUserProvider(accounts []Account, opts []server.UserProviderOption) (memoryUserProvider, error) {
+	mp := server.NewMemoryUserProvider(opts...)
+	for _, a := range accounts {
+		method := auth.MySQLNativePassword
+		if a.Plugin != "" {
+			var err error
+			if method, err = auth.ParseAuthenticationPlugin(a.Plugin); err != nil {
+				return nil, fmt.Errorf("account '%s'@'%s': %v", a.User, a.Host, err)
+			}
+		}
+
+		var authenticationString []byte
+		if a.AuthenticationString != "" {
+			authenticationString = []byte(a.AuthenticationString)
+		}
+
+		var grants []server.Grant
+		for i := range a.Grants {
+			g, err := a.Grants[i].grant()
+			if err != nil {
+				return nil, fmt.Errorf("account '%s'@'%s': %v", a.User, a.Host, err)
+			}
+			grants = append(grants, g)
+		}
+
+		err := mp.Create(&server.CreateUserRequest{
+			User:                 a.User,
+			Host:                 a.Host,
+			Password:             a.Password,
+			Method:               method,
+			AuthenticationString: authenticationString,
+			TLSRequired:          strings.TrimSpace(a.SSLType) != "",
+			MaxUserConnections:   a.MaxUserConnections,
+			Grants:               grants,
+			AccountLocked:        a.AccountLocked,
+			PasswordExpired:      a.PasswordExpired,
+			PasswordLifetime:     time.Duration(a.PasswordLifetime) * 24 * time.Hour,
+			PasswordLastChanged:  a.PasswordLastChanged,
+			FailedLoginAttempts:  a.FailedLoginAttempts,
+			PasswordLockTime:     time.Duration(a.PasswordLockTime) * 24 * time.Hour,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("account '%s'@'%s': %v", a.User, a.Host, err)
+		}
+	}
+	return mp, nil
+}
+
+// accounts delegates to memory UserProvider which is replaced when accounts are reloaded,
+// keys of accounts are the same after reloading, so that state of accounts is restored by keys.
+type accounts struct {
+	// mu is held for writing when accounts are replaced or state of account is changed
+	mu sync.RWMutex
+	mp memoryUserProvider
+	// keys of accounts whose state is changed by clients
+	changed map[string]bool
+}
+
+func (a *accounts) load() memoryUserProvider {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.mp
+}
+
+// store replaces accounts by mp, and restores failed logins and changed passwords of accounts.
+func (a *accounts) store(mp memoryUserProvider) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key := range a.changed {
+		state, err := a.mp.AccountState(key)
+		if err == nil {
+			err = mp.RestoreAccountState(key, state)
+		}
+		// account is removed
+		if err != nil {
+			delete(a.changed, key)
+		}
+	}
+	a.mp = mp
+}
+
+// change changes state of account of key by f.
+func (a *accounts) change(key string, f func(mp memoryUserProvider) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := f(a.mp); err != nil {
+		return err
+	}
+	if a.changed == nil {
+		a.changed = make(map[string]bool)
+	}
+	a.changed[key] = true
+	return nil
+}
+
+func (a *accounts) Key(user, host string) (string, error) {
+	return a.load().Key(user, host)
+}
+
+func (a *accounts) AuthenticationMethod(key string) (auth.Method, error) {
+	return a.load().AuthenticationMethod(key)
+}
+
+func (a *accounts) AuthenticationString(key string) ([]byte, error) {
+	return a.load().AuthenticationString(key)
+}
+
+func (a *accounts) Authorization(key string, r *server.AuthorizationRequest) error {
+	return a.load().Authorization(key, r)
+}
+
+func (a *accounts) MaxUserConnections(key string) (int, error) {
+	return a.load().MaxUserConnections(key)
+}
+
+func (a *accounts) Grants(key string) ([]server.Grant, error) {
+	return a.load().Grants(key)
+}
+
+func (a *accounts) AccountStatus(key string) (*server.AccountStatus, error) {
+	return a.load().AccountStatus(key)
+}
+
+func (a *accounts) LoginFailed(key string) (status *server.AccountStatus, err error) {
+	err = a.change(key, func(mp memoryUserProvider) error {
+		status, err = mp.LoginFailed(key)
+		return err
+	})
+	return status, err
+}
+
+func (a *accounts) LoginSucceeded(key string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.mp.LoginSucceeded(key)
+}
+
+func (a *accounts) ChangePassword(key string, password []byte) error {
+	return a.change(key, func(mp memoryUserProvider) error {
+		return mp.ChangePassword(key, password)
+	})
+}
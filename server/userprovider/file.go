@@ -0,0 +1,156 @@
+package userprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/vczyh/mysql-protocol/server"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileUserProvider loads accounts from JSON or YAML file, which is a list of Account,
+// and reloads them when the file is changed. File with .yaml or .yml extension is YAML.
+//
+//   - user: app
+//     host: "%"
+//     plugin: caching_sha2_password
+//     authentication_string: "$A$005$..."
+//   - user: root
+//     host: localhost
+//     password: "123456"
+//     ssl_type: ANY
+//
+// Accounts are kept if the changed file is invalid.
+type FileUserProvider struct {
+	accounts
+
+	path     string
+	interval time.Duration
+	logger   server.Logger
+
+	userProviderOpts []server.UserProviderOption
+
+	// protects reloading
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewFileUserProvider loads accounts from file at path, it returns error if the file is invalid.
+// Options WithReloadInterval and WithLogger are used.
+func NewFileUserProvider(path string, opts ...Option) (*FileUserProvider, error) {
+	o := newOptions(opts)
+	p := &FileUserProvider{
+		path:     path,
+		interval: o.reloadInterval,
+		logger:   o.logger,
+		done:     make(chan struct{}),
+
+		userProviderOpts: o.userProviderOpts,
+	}
+
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	if p.interval > 0 {
+		go p.watch()
+	}
+	return p, nil
+}
+
+// Reload loads accounts from file immediately.
+func (p *FileUserProvider) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reload()
+}
+
+func (p *FileUserProvider) reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	accounts, err := readAccounts(p.path)
+	if err != nil {
+		return err
+	}
+	mp, err := newMemoryUserProvider(accounts, p.userProviderOpts)
+	if err != nil {
+		return fmt.Errorf("%s: %v", p.path, err)
+	}
+	p.store(mp)
+	p.modTime, p.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// Close stops watching the file.
+func (p *FileUserProvider) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
+
+func (p *FileUserProvider) watch() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(p.path)
+		if err != nil {
+			p.logger.Warn(fmt.Errorf("stat accounts file failed: %v", err))
+			continue
+		}
+		if reloaded, err := p.reloadChanged(info); err != nil {
+			p.logger.Error(fmt.Errorf("reload accounts failed, accounts are not changed: %v", err))
+		} else if reloaded {
+			p.logger.System(fmt.Errorf("accounts are reloaded from %s", p.path))
+		}
+	}
+}
+
+func (p *FileUserProvider) reloadChanged(info os.FileInfo) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return false, nil
+	}
+	if err := p.reload(); err != nil {
+		// don't retry until the file is changed again
+		p.modTime, p.size = info.ModTime(), info.Size()
+		return false, err
+	}
+	return true, nil
+}
+
+func readAccounts(path string) ([]Account, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []Account
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &accounts)
+	default:
+		err = json.Unmarshal(data, &accounts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return accounts, nil
+}
@@ -0,0 +1,187 @@
+package userprovider
+
+import (
+	"fmt"
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/packet"
+	"github.com/vczyh/mysql-protocol/server"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var discard = server.NewDefaultLogger(server.ErrorLevel, ioutil.Discard)
+
+func connect(provider server.UserProvider, user, password string) error {
+	srv := server.NewServer(provider, server.NewDefaultHandler(), server.WithLogger(discard))
+	conn, err := client.CreateConnection(client.WithUser(user), client.WithPassword(password),
+		client.WithDialer(servertest.Dialer(srv)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func errorCode(err error) code.Err {
+	if errPkt, ok := err.(*packet.ERR); ok {
+		return errPkt.ErrorCode
+	}
+	return 0
+}
+
+func nativePassword(t *testing.T, password string) string {
+	t.Helper()
+	as, err := auth.MySQLNativePassword.GenerateAuthenticationStringWithoutSalt([]byte(password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(as)
+}
+
+func TestFileUserProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "accounts.yaml")
+	yamlData := fmt.Sprintf(`
+- user: app
+  host: "%%"
+  authentication_string: "%s"
+  grants:
+  - privileges: [select, insert]
+    database: app
+  - privileges: [select]
+    database: hr
+    table: staff
+    columns: [id]
+- user: sha2
+  host: "%%"
+  plugin: caching_sha2_password
+  password: "654321"
+`, nativePassword(t, "123456"))
+	if err := ioutil.WriteFile(yamlPath, []byte(yamlData), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewFileUserProvider(yamlPath, WithReloadInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := connect(p, "app", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if err := connect(p, "sha2", "654321"); err != nil {
+		t.Fatal(err)
+	}
+	key, err := p.Key("app", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants, err := p.Grants(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 2 || grants[0].Privileges != server.SelectPriv|server.InsertPriv ||
+		grants[1].Table != "staff" || len(grants[1].Columns) != 1 {
+		t.Fatalf("unexpected grants: %+v", grants)
+	}
+
+	jsonPath := filepath.Join(dir, "accounts.json")
+	write := func(password string) {
+		data := fmt.Sprintf(`[{"user": "app", "host": "%%", "authentication_string": "%s"}]`, nativePassword(t, password))
+		if err := ioutil.WriteFile(jsonPath, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("123456")
+	p, err = NewFileUserProvider(jsonPath, WithReloadInterval(10*time.Millisecond), WithLogger(discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := connect(p, "app", "123456"); err != nil {
+		t.Fatal(err)
+	}
+
+	// invalid file is ignored
+	if err := ioutil.WriteFile(jsonPath, []byte("[{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := connect(p, "app", "123456"); err != nil {
+		t.Fatal(err)
+	}
+
+	write("new password")
+	deadline := time.Now().Add(5 * time.Second)
+	for connect(p, "app", "new password") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("accounts are not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := connect(p, "app", "123456"); err == nil {
+		t.Fatal("old password is accepted after reloading")
+	}
+
+	if _, err := NewFileUserProvider(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("expected error of missing file")
+	}
+}
+
+func TestReloadAccountState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	write := func(password string, attempts int) {
+		data := fmt.Sprintf(`[
+{"user": "app", "host": "%%", "authentication_string": "%s"},
+{"user": "guarded", "host": "%%", "password": "123456", "failed_login_attempts": %d, "password_lock_time": -1}
+]`, nativePassword(t, password), attempts)
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("123456", 2)
+	p, err := NewFileUserProvider(path, WithReloadInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := connect(p, "guarded", "wrong"); errorCode(err) != code.ErrAccessDeniedError {
+		t.Fatalf("expected error %d, got %v", code.ErrAccessDeniedError, err)
+	}
+	if err := connect(p, "guarded", "wrong"); errorCode(err) != code.ErrUserAccountBlocked {
+		t.Fatalf("expected error %d, got %v", code.ErrUserAccountBlocked, err)
+	}
+	key, err := p.Key("app", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ChangePassword(key, []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+
+	// accounts are reloaded during lockout
+	if err := p.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := connect(p, "guarded", "123456"); errorCode(err) != code.ErrUserAccountBlocked {
+		t.Fatalf("expected error %d, got %v", code.ErrUserAccountBlocked, err)
+	}
+	if err := connect(p, "app", "changed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// changing FAILED_LOGIN_ATTEMPTS unblocks account, and password changed in file replaces changed password
+	write("new password", 3)
+	if err := p.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := connect(p, "guarded", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if err := connect(p, "app", "new password"); err != nil {
+		t.Fatal(err)
+	}
+}
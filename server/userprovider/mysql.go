@@ -0,0 +1,255 @@
+package userprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/server"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	accountsQuery = "SELECT user, host, plugin, authentication_string, ssl_type, max_user_connections, account_locked, " +
+		"password_expired, password_last_changed, password_lifetime, user_attributes FROM mysql.user"
+
+	// timestamps of mysql.user are read in UTC
+	timeZoneQuery   = "SET time_zone = '+00:00'"
+	timestampLayout = "2006-01-02 15:04:05"
+)
+
+// userAttributes is user_attributes column of mysql.user, which has FAILED_LOGIN_ATTEMPTS and PASSWORD_LOCK_TIME.
+type userAttributes struct {
+	PasswordLocking struct {
+		FailedLoginAttempts  int `json:"failed_login_attempts"`
+		PasswordLockTimeDays int `json:"password_lock_time_days"`
+	} `json:"Password_locking"`
+}
+
+// delay of retrying after the first failed reload, it's doubled by every failure until maxRetryInterval
+const (
+	minRetryInterval = time.Second
+	maxRetryInterval = time.Minute
+)
+
+// Connector opens connection to the server whose mysql.user is read.
+type Connector func() (*client.Conn, error)
+
+// Dial returns Connector creating connection by opts.
+func Dial(opts ...client.Option) Connector {
+	return func() (*client.Conn, error) {
+		return client.CreateConnection(opts...)
+	}
+}
+
+// MySQLUserProvider reads accounts from mysql.user table of another server, and caches them for TTL.
+// Accounts are read again when an account is looked up after TTL, cached accounts are kept if reading
+// fails, and failed reads are retried with backoff. Grants aren't read, so accounts have no privilege
+// if server.WithCheckPrivileges is true. mysql.user of MySQL 8.0.19 or later is required.
+type MySQLUserProvider struct {
+	accounts
+
+	connect Connector
+	ttl     time.Duration
+	logger  server.Logger
+
+	userProviderOpts []server.UserProviderOption
+
+	// protects the following fields, but not reading accounts
+	mu        sync.Mutex
+	loadedAt  time.Time
+	retryAt   time.Time
+	failures  int
+	reloading bool
+
+	// protects conn, which is opened again by connect after it fails
+	connMu sync.Mutex
+	conn   *client.Conn
+}
+
+// NewMySQLUserProvider reads accounts by connection opened by connect, which is used only by provider.
+// Options WithTTL and WithLogger are used.
+func NewMySQLUserProvider(connect Connector, opts ...Option) (*MySQLUserProvider, error) {
+	o := newOptions(opts)
+	p := &MySQLUserProvider{
+		connect: connect,
+		ttl:     o.ttl,
+		logger:  o.logger,
+
+		userProviderOpts: o.userProviderOpts,
+	}
+	if err := p.Reload(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Key reads accounts again if cached accounts are expired. Only one lookup reads accounts,
+// the others use cached accounts meanwhile.
+func (p *MySQLUserProvider) Key(user, host string) (string, error) {
+	now := time.Now()
+	p.mu.Lock()
+	reload := !p.reloading && now.Sub(p.loadedAt) >= p.ttl && !now.Before(p.retryAt)
+	if reload {
+		p.reloading = true
+	}
+	p.mu.Unlock()
+
+	if reload {
+		if err := p.reload(); err != nil {
+			p.logger.Error(fmt.Errorf("read accounts from mysql.user failed, cached accounts are used: %v", err))
+		}
+	}
+	return p.accounts.Key(user, host)
+}
+
+// Reload reads accounts immediately.
+func (p *MySQLUserProvider) Reload() error {
+	p.mu.Lock()
+	p.reloading = true
+	p.mu.Unlock()
+	return p.reload()
+}
+
+// reload reads accounts, reloading must be set by caller.
+func (p *MySQLUserProvider) reload() error {
+	accounts, err := p.readAccounts()
+	var mp memoryUserProvider
+	if err == nil {
+		mp, err = newMemoryUserProvider(accounts, p.userProviderOpts)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reloading = false
+	if err != nil {
+		delay := minRetryInterval << p.failures
+		if delay >= maxRetryInterval {
+			delay = maxRetryInterval
+		} else {
+			p.failures++
+		}
+		p.retryAt = time.Now().Add(delay)
+		return err
+	}
+	p.store(mp)
+	p.loadedAt = time.Now()
+	p.failures = 0
+	p.retryAt = time.Time{}
+	return nil
+}
+
+// query runs accountsQuery, connection is opened again if it fails. connMu must be held by caller.
+func (p *MySQLUserProvider) query() (*client.Rows, error) {
+	// connection opened before may be closed by server, it's retried by new connection
+	for retried := p.conn == nil; ; retried = true {
+		if p.conn == nil {
+			conn, err := p.connect()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := conn.Exec(timeZoneQuery); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			p.conn = conn
+		}
+
+		rows, err := p.conn.Query(accountsQuery)
+		if err == nil || myerrors.Is(err) {
+			return rows, err
+		}
+		p.closeConn()
+		if retried {
+			return nil, err
+		}
+	}
+}
+
+func (p *MySQLUserProvider) readAccounts() ([]Account, error) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	rows, err := p.query()
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := scanAccounts(rows)
+	if err != nil {
+		// rows may be left unread
+		p.closeConn()
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func scanAccounts(rows *client.Rows) ([]Account, error) {
+	var accounts []Account
+	for {
+		values, err := rows.NextRaw()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != 11 {
+			return nil, fmt.Errorf("mysql.user returns %d columns, expected 11", len(values))
+		}
+
+		a := Account{
+			User:                 string(values[0].Data),
+			Host:                 string(values[1].Data),
+			Plugin:               string(values[2].Data),
+			AuthenticationString: string(values[3].Data),
+			SSLType:              string(values[4].Data),
+			AccountLocked:        string(values[6].Data) == "Y",
+			PasswordExpired:      string(values[7].Data) == "Y",
+		}
+		if !values[5].Null {
+			if a.MaxUserConnections, err = strconv.Atoi(string(values[5].Data)); err != nil {
+				return nil, fmt.Errorf("invalid max_user_connections of '%s'@'%s': %v", a.User, a.Host, err)
+			}
+		}
+		if !values[8].Null {
+			if a.PasswordLastChanged, err = time.Parse(timestampLayout, string(values[8].Data)); err != nil {
+				return nil, fmt.Errorf("invalid password_last_changed of '%s'@'%s': %v", a.User, a.Host, err)
+			}
+		}
+		// NULL is default_password_lifetime, which is 0 by default
+		if !values[9].Null {
+			if a.PasswordLifetime, err = strconv.Atoi(string(values[9].Data)); err != nil {
+				return nil, fmt.Errorf("invalid password_lifetime of '%s'@'%s': %v", a.User, a.Host, err)
+			}
+		}
+		if !values[10].Null && len(values[10].Data) > 0 {
+			var attributes userAttributes
+			if err := json.Unmarshal(values[10].Data, &attributes); err != nil {
+				return nil, fmt.Errorf("invalid user_attributes of '%s'@'%s': %v", a.User, a.Host, err)
+			}
+			a.FailedLoginAttempts = attributes.PasswordLocking.FailedLoginAttempts
+			a.PasswordLockTime = attributes.PasswordLocking.PasswordLockTimeDays
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, nil
+}
+
+// Close closes connection of provider.
+func (p *MySQLUserProvider) Close() error {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	return p.closeConn()
+}
+
+func (p *MySQLUserProvider) closeConn() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
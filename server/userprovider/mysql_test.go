@@ -0,0 +1,143 @@
+package userprovider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/server"
+	"github.com/vczyh/mysql-protocol/server/memengine"
+	"github.com/vczyh/mysql-protocol/server/servertest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMySQLUserProvider(t *testing.T) {
+	upstream := servertest.NewServer(memengine.NewHandler(), nil)
+	dial := func() *client.Conn {
+		conn, err := client.CreateConnection(client.WithUser("root"), client.WithDialer(servertest.Dialer(upstream)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	changed := func(days int) string {
+		return time.Now().UTC().AddDate(0, 0, -days).Format("2006-01-02 15:04:05")
+	}
+
+	admin := dial()
+	defer admin.Close()
+	for _, query := range []string{
+		"CREATE DATABASE mysql",
+		"CREATE TABLE mysql.user (host VARCHAR(255), user VARCHAR(32), plugin VARCHAR(64), " +
+			"authentication_string TEXT, ssl_type VARCHAR(16), max_user_connections INT, account_locked CHAR(1), " +
+			"password_expired CHAR(1), password_last_changed DATETIME, password_lifetime INT, user_attributes TEXT)",
+		fmt.Sprintf("INSERT INTO mysql.user VALUES ('%%', 'app', 'mysql_native_password', '%s', '', 0, 'N', 'N', NULL, NULL, NULL)",
+			nativePassword(t, "123456")),
+		"INSERT INTO mysql.user VALUES ('%', 'locked', 'mysql_native_password', '', '', 0, 'Y', 'N', NULL, NULL, NULL)",
+		"INSERT INTO mysql.user VALUES ('%', 'expired', 'mysql_native_password', '', '', 0, 'N', 'Y', NULL, NULL, NULL)",
+		fmt.Sprintf("INSERT INTO mysql.user VALUES ('%%', 'old', 'mysql_native_password', '', '', 0, 'N', 'N', '%s', 30, NULL)",
+			changed(31)),
+		fmt.Sprintf("INSERT INTO mysql.user VALUES ('%%', 'recent', 'mysql_native_password', '', '', 0, 'N', 'N', '%s', 30, NULL)",
+			changed(29)),
+		fmt.Sprintf("INSERT INTO mysql.user VALUES ('%%', 'guarded', 'mysql_native_password', '%s', '', 0, 'N', 'N', NULL, NULL, "+
+			`'{"Password_locking": {"failed_login_attempts": 1, "password_lock_time_days": -1}}')`, nativePassword(t, "123456")),
+	} {
+		if _, err := admin.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		conns    []*client.Conn
+		attempts int
+		dialErr  error
+	)
+	connector := func() (*client.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		conn := dial()
+		conns = append(conns, conn)
+		return conn, nil
+	}
+	p, err := NewMySQLUserProvider(connector, WithTTL(50*time.Millisecond), WithLogger(discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := connect(p, "app", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if err := connect(p, "other", ""); err == nil {
+		t.Fatal("unknown account is accepted")
+	}
+	for user, expected := range map[string]code.Err{
+		"locked":  code.ErrAccountHasBeenLocked,
+		"expired": code.ErrExpiredPasswordLogin,
+		"old":     code.ErrExpiredPasswordLogin,
+		"recent":  0,
+	} {
+		if err := connect(p, user, ""); errorCode(err) != expected {
+			t.Fatalf("%s: expected error %d, got %v", user, expected, err)
+		}
+	}
+	// the failed login reaching FAILED_LOGIN_ATTEMPTS is blocked
+	if err := connect(p, "guarded", "wrong"); errorCode(err) != code.ErrUserAccountBlocked {
+		t.Fatalf("expected error %d, got %v", code.ErrUserAccountBlocked, err)
+	}
+	if err := connect(p, "guarded", "123456"); errorCode(err) != code.ErrUserAccountBlocked {
+		t.Fatalf("expected error %d, got %v", code.ErrUserAccountBlocked, err)
+	}
+
+	if _, err := admin.Exec("INSERT INTO mysql.user VALUES ('%', 'other', 'mysql_native_password', '', '', 0, 'N', 'N', NULL, NULL, NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := connect(p, "other", ""); err != nil {
+		t.Fatal(err)
+	}
+	// account is still blocked after reading accounts again
+	if err := connect(p, "guarded", "123456"); errorCode(err) != code.ErrUserAccountBlocked {
+		t.Fatalf("expected error %d, got %v", code.ErrUserAccountBlocked, err)
+	}
+
+	// connection closed is opened again
+	conns[0].Close()
+	if _, err := admin.Exec("INSERT INTO mysql.user VALUES ('%', 'another', 'mysql_native_password', '', '', 0, 'N', 'N', NULL, NULL, NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := connect(p, "another", ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(conns))
+	}
+
+	// failed reading isn't retried by every lookup
+	mu.Lock()
+	conns[1].Close()
+	dialErr = errors.New("dial failed")
+	attempts = 0
+	mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	srv := server.NewServer(p, server.NewDefaultHandler(), server.WithLogger(discard))
+	for i := 0; i < 5; i++ {
+		conn, err := client.CreateConnection(client.WithUser("another"), client.WithDialer(servertest.Dialer(srv)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 1 {
+		t.Fatalf("expected 1 connecting attempt, got %d", attempts)
+	}
+}
@@ -0,0 +1,74 @@
+package userprovider
+
+import (
+	"github.com/vczyh/mysql-protocol/server"
+	"os"
+	"time"
+)
+
+const (
+	defaultReloadInterval = time.Second
+	defaultTTL            = time.Minute
+)
+
+type options struct {
+	reloadInterval time.Duration
+	ttl            time.Duration
+	logger         server.Logger
+
+	// options of memory UserProvider matching accounts
+	userProviderOpts []server.UserProviderOption
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		reloadInterval: defaultReloadInterval,
+		ttl:            defaultTTL,
+	}
+	for _, opt := range opts {
+		opt.apply(o)
+	}
+	if o.logger == nil {
+		o.logger = server.NewDefaultLogger(server.SystemLevel, os.Stdout)
+	}
+	return o
+}
+
+// WithReloadInterval sets how often FileUserProvider checks whether the file is changed,
+// 0 disables reloading, default is 1s.
+func WithReloadInterval(interval time.Duration) Option {
+	return optionFun(func(o *options) {
+		o.reloadInterval = interval
+	})
+}
+
+// WithTTL sets how long MySQLUserProvider caches accounts, default is 1m.
+func WithTTL(ttl time.Duration) Option {
+	return optionFun(func(o *options) {
+		o.ttl = ttl
+	})
+}
+
+// WithLogger sets logger of reloading accounts.
+func WithLogger(logger server.Logger) Option {
+	return optionFun(func(o *options) {
+		o.logger = logger
+	})
+}
+
+// WithHostResolver sets resolver of client host names, see server.WithHostResolver.
+func WithHostResolver(resolver server.HostResolver) Option {
+	return optionFun(func(o *options) {
+		o.userProviderOpts = append(o.userProviderOpts, server.WithHostResolver(resolver))
+	})
+}
+
+type Option interface {
+	apply(*options)
+}
+
+type optionFun func(*options)
+
+func (f optionFun) apply(o *options) {
+	f(o)
+}
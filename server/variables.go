@@ -0,0 +1,273 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/charset"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// VariableScope is where system variable can be set.
+type VariableScope uint8
+
+const (
+	ScopeGlobal VariableScope = 1 << iota
+	ScopeSession
+
+	ScopeBoth = ScopeGlobal | ScopeSession
+)
+
+// SystemVariable describes a server system variable.
+// https://dev.mysql.com/doc/refman/8.0/en/server-system-variables.html
+type SystemVariable struct {
+	Name    string
+	Scope   VariableScope
+	Default string
+	// ReadOnly variable can't be set by SET statement.
+	ReadOnly bool
+	// Boolean variable is stored as ON or OFF, and selected as 1 or 0.
+	Boolean bool
+}
+
+// variable aliases, such as tx_isolation is deprecated name of transaction_isolation.
+var variableAliases = map[string]string{
+	"tx_isolation": "transaction_isolation",
+	"tx_read_only": "transaction_read_only",
+}
+
+var defaultSystemVariables = []SystemVariable{
+	{Name: "auto_increment_increment", Scope: ScopeBoth, Default: "1"},
+	{Name: "auto_increment_offset", Scope: ScopeBoth, Default: "1"},
+	{Name: "autocommit", Scope: ScopeBoth, Default: "ON", Boolean: true},
+	{Name: "character_set_client", Scope: ScopeBoth, Default: charset.UTF8MB4},
+	{Name: "character_set_connection", Scope: ScopeBoth, Default: charset.UTF8MB4},
+	{Name: "character_set_database", Scope: ScopeBoth, Default: charset.UTF8MB4},
+	{Name: "character_set_results", Scope: ScopeBoth, Default: charset.UTF8MB4},
+	{Name: "character_set_server", Scope: ScopeBoth, Default: charset.UTF8MB4},
+	{Name: "collation_connection", Scope: ScopeBoth, Default: charset.UTF8MB40900AiCi},
+	{Name: "collation_database", Scope: ScopeBoth, Default: charset.UTF8MB40900AiCi},
+	{Name: "collation_server", Scope: ScopeBoth, Default: charset.UTF8MB40900AiCi},
+	{Name: "init_connect", Scope: ScopeGlobal, Default: ""},
+	{Name: "interactive_timeout", Scope: ScopeBoth, Default: "28800"},
+	{Name: "license", Scope: ScopeGlobal, Default: "GPL", ReadOnly: true},
+	{Name: "lower_case_table_names", Scope: ScopeGlobal, Default: "0", ReadOnly: true},
+	{Name: "max_allowed_packet", Scope: ScopeBoth, Default: strconv.Itoa(defaultMaxAllowedPacket)},
+	{Name: "net_buffer_length", Scope: ScopeBoth, Default: "16384"},
+	{Name: "net_write_timeout", Scope: ScopeBoth, Default: "60"},
+	{Name: "performance_schema", Scope: ScopeGlobal, Default: "OFF", ReadOnly: true, Boolean: true},
+	{Name: "query_cache_size", Scope: ScopeGlobal, Default: "0"},
+	{Name: "query_cache_type", Scope: ScopeBoth, Default: "OFF"},
+	{Name: "sql_mode", Scope: ScopeBoth, Default: "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION"},
+	{Name: "sql_select_limit", Scope: ScopeBoth, Default: "18446744073709551615"},
+	{Name: "system_time_zone", Scope: ScopeGlobal, Default: "UTC", ReadOnly: true},
+	{Name: "time_zone", Scope: ScopeBoth, Default: "SYSTEM"},
+	{Name: "transaction_isolation", Scope: ScopeBoth, Default: "REPEATABLE-READ"},
+	{Name: "transaction_read_only", Scope: ScopeBoth, Default: "OFF", Boolean: true},
+	{Name: "version", Scope: ScopeGlobal, Default: "", ReadOnly: true},
+	{Name: "version_comment", Scope: ScopeGlobal, Default: "mysql-protocol", ReadOnly: true},
+	{Name: "wait_timeout", Scope: ScopeBoth, Default: "28800"},
+}
+
+// SystemVariables is registry of system variables and their global values,
+// it's shared by all sessions and safe for concurrent use.
+type SystemVariables struct {
+	mu     sync.RWMutex
+	vars   map[string]SystemVariable
+	global map[string]string
+}
+
+// NewSystemVariables return registry with common variables queried by connectors.
+func NewSystemVariables() *SystemVariables {
+	v := &SystemVariables{
+		vars:   make(map[string]SystemVariable),
+		global: make(map[string]string),
+	}
+	for _, variable := range defaultSystemVariables {
+		v.Register(variable)
+	}
+	return v
+}
+
+// Register adds or replaces variable, its global value is set to default.
+func (v *SystemVariables) Register(variable SystemVariable) {
+	variable.Name = strings.ToLower(variable.Name)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.vars[variable.Name] = variable
+	v.global[variable.Name] = variable.Default
+}
+
+// Lookup return variable by name case-insensitively, aliases are resolved.
+func (v *SystemVariables) Lookup(name string) (SystemVariable, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	variable, ok := v.vars[canonicalVariableName(name)]
+	return variable, ok
+}
+
+// Global return global value of variable.
+func (v *SystemVariables) Global(name string) (string, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	value, ok := v.global[canonicalVariableName(name)]
+	return value, ok
+}
+
+// SetGlobal changes global value like SET GLOBAL, it returns error 1193 for unknown variable,
+// error 1238 for read-only variable and error 1228 for session-only variable.
+func (v *SystemVariables) SetGlobal(name, value string) error {
+	variable, value, err := v.check(name, value)
+	if err != nil {
+		return err
+	}
+	if variable.Scope&ScopeGlobal == 0 {
+		return myerrors.LocalVariable.Build(variable.Name)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.global[variable.Name] = value
+	return nil
+}
+
+// Names return sorted names of registered variables.
+func (v *SystemVariables) Names() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	names := make([]string, 0, len(v.vars))
+	for name := range v.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// set sets global value without any check, it's used to sync variables with server config.
+func (v *SystemVariables) set(name, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.vars[name]; ok {
+		v.global[name] = value
+	}
+}
+
+// syncSystemVariables sets global values of variables from server config.
+func (s *Server) syncSystemVariables() {
+	v := s.config.Variables
+	if s.config.Version != "" {
+		v.set("version", s.config.Version)
+	}
+	v.set("max_allowed_packet", strconv.Itoa(s.config.MaxAllowedPacket))
+	if s.config.WaitTimeout > 0 {
+		v.set("wait_timeout", strconv.Itoa(int(s.config.WaitTimeout.Seconds())))
+	}
+	if s.config.InteractiveTimeout > 0 {
+		v.set("interactive_timeout", strconv.Itoa(int(s.config.InteractiveTimeout.Seconds())))
+	}
+}
+
+// check validates variable can be set to value, and return normalized value.
+func (v *SystemVariables) check(name, value string) (SystemVariable, string, error) {
+	variable, ok := v.Lookup(name)
+	if !ok {
+		return variable, "", myerrors.UnknownSystemVariable.Build(name)
+	}
+	if variable.ReadOnly {
+		return variable, "", myerrors.IncorrectGlobalLocalVar.Build(variable.Name, "read only")
+	}
+	if variable.Boolean {
+		switch strings.ToUpper(value) {
+		case "1", "ON", "TRUE":
+			value = "ON"
+		case "0", "OFF", "FALSE":
+			value = "OFF"
+		default:
+			return variable, "", myerrors.WrongValueForVar.Build(variable.Name, value)
+		}
+	}
+	return variable, value, nil
+}
+
+func canonicalVariableName(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := variableAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// SystemVariable return session value of variable, it's global value if not set in session.
+func (s *Session) SystemVariable(name string) (string, bool) {
+	name = canonicalVariableName(name)
+	if _, ok := s.sysVars.Lookup(name); !ok {
+		return "", false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if name == "autocommit" {
+		if s.status&flag.ServerStatusAutocommit != 0 {
+			return "ON", true
+		}
+		return "OFF", true
+	}
+	if value, ok := s.variables[name]; ok {
+		return value, true
+	}
+	if s.collation != nil {
+		switch name {
+		case "character_set_client", "character_set_connection", "character_set_results":
+			return s.collation.Charset().Name(), true
+		case "collation_connection":
+			return s.collation.Name(), true
+		}
+	}
+	return s.sysVars.Global(name)
+}
+
+// SetSystemVariable changes session value of variable like SET SESSION, it returns error 1193
+// for unknown variable, error 1238 for read-only variable and error 1229 for global-only variable.
+// Setting autocommit changes SERVER_STATUS_AUTOCOMMIT flag of session status.
+func (s *Session) SetSystemVariable(name, value string) error {
+	variable, value, err := s.sysVars.check(name, value)
+	if err != nil {
+		return err
+	}
+	if variable.Scope&ScopeSession == 0 {
+		return myerrors.GlobalVariable.Build(variable.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if variable.Name == "autocommit" {
+		if value == "ON" {
+			s.status |= flag.ServerStatusAutocommit
+		} else {
+			s.status &^= flag.ServerStatusAutocommit
+		}
+		return nil
+	}
+	s.variables[variable.Name] = value
+	return nil
+}
+
+// setCharset handles SET NAMES, it changes charset of client, connection and results.
+func (s *Session) setCharset(collation *charset.Collation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collation = collation
+	for _, name := range []string{"character_set_client", "character_set_connection", "character_set_results", "collation_connection"} {
+		delete(s.variables, name)
+	}
+}
+
+// resetSystemVariable restores session value of variable to global value, such as SET x = DEFAULT.
+func (s *Session) resetSystemVariable(name string) error {
+	value, ok := s.sysVars.Global(name)
+	if !ok {
+		return myerrors.UnknownSystemVariable.Build(name)
+	}
+	return s.SetSystemVariable(name, value)
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/packet"
+	"io"
+	"testing"
+)
+
+type systemHandler struct {
+	DefaultHandler
+}
+
+func (h *systemHandler) Query(session *Session, query string) (interface{}, error) {
+	if rs, handled, err := SystemQuery(session, query); handled {
+		return rs, err
+	}
+	return NewSimpleResultSet([]string{"handler"}, [][]interface{}{{query}})
+}
+
+func TestSystemQuery(t *testing.T) {
+	variables := NewSystemVariables()
+	variables.Register(SystemVariable{Name: "custom_var", Scope: ScopeSession, Default: "x"})
+	srv := newTestServer(newUserProvider(t), &systemHandler{},
+		WithVersion("8.0.99-test"),
+		WithSystemVariables(variables))
+
+	conn, err := client.CreateConnection(client.WithDialer(pipeDialer(srv)), client.WithUser("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	query := func(query string) [][]string {
+		t.Helper()
+		rows, err := conn.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var res [][]string
+		for {
+			row, err := rows.Next()
+			if err == io.EOF {
+				return res
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values []string
+			for _, v := range row {
+				values = append(values, v.String())
+			}
+			res = append(res, values)
+		}
+	}
+	exec := func(query string) {
+		t.Helper()
+		if _, err := conn.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	expectErr := func(query string, c code.Err) {
+		t.Helper()
+		_, err := conn.Exec(query)
+		errPkt, ok := err.(*packet.ERR)
+		if !ok || errPkt.ErrorCode != c {
+			t.Fatalf("%s: expected error %d, got %v", query, c, err)
+		}
+	}
+	session := func() *Session {
+		return srv.Sessions()[0]
+	}
+
+	t.Run("Select", func(t *testing.T) {
+		if res := query("SELECT @@version_comment LIMIT 1"); len(res) != 1 || res[0][0] != "mysql-protocol" {
+			t.Fatalf("unexpected version_comment: %v", res)
+		}
+		if res := query("SELECT @@version_comment LIMIT 0"); len(res) != 0 {
+			t.Fatalf("expected no rows, got %v", res)
+		}
+		res := query("SELECT @@session.transaction_isolation AS iso, DATABASE(), @@autocommit, VERSION(), @@max_allowed_packet")
+		if len(res) != 1 || res[0][0] != "REPEATABLE-READ" || res[0][1] != "NULL" || res[0][2] != "1" ||
+			res[0][3] != "8.0.99-test" || res[0][4] != "67108864" {
+			t.Fatalf("unexpected result: %v", res)
+		}
+		expectErr("SELECT @@unknown_var", code.ErrUnknownSystemVariable)
+		expectErr("SELECT @@global.custom_var", code.ErrIncorrectGlobalLocal)
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		exec("SET NAMES utf8 COLLATE utf8_bin")
+		if c := session().Collation(); c.Name() != "utf8_bin" {
+			t.Fatalf("expected collation utf8_bin, got %s", c.Name())
+		}
+		if res := query("SELECT @@character_set_client, @@collation_connection"); res[0][0] != "utf8" || res[0][1] != "utf8_bin" {
+			t.Fatalf("unexpected charset: %v", res)
+		}
+
+		exec("SET autocommit=0")
+		if session().Status()&flag.ServerStatusAutocommit != 0 {
+			t.Fatal("expected autocommit off")
+		}
+		exec("SET autocommit=ON, sql_mode='ANSI'")
+		exec("SET SESSION TRANSACTION ISOLATION LEVEL READ COMMITTED")
+		if session().Status()&flag.ServerStatusAutocommit == 0 {
+			t.Fatal("expected autocommit on")
+		}
+		if res := query("SELECT @@tx_isolation, @@sql_mode"); res[0][0] != "READ-COMMITTED" || res[0][1] != "ANSI" {
+			t.Fatalf("unexpected result: %v", res)
+		}
+		exec("SET @@session.sql_mode=DEFAULT")
+		if v, _ := session().SystemVariable("sql_mode"); v != variables.vars["sql_mode"].Default {
+			t.Fatalf("expected default sql_mode, got %s", v)
+		}
+
+		expectErr("SET version='1'", code.ErrIncorrectGlobalLocal)
+		expectErr("SET init_connect=''", code.ErrGlobalVariable)
+		expectErr("SET GLOBAL custom_var='y'", code.ErrLocalVariable)
+		expectErr("SET autocommit='maybe'", code.ErrWrongValueForVar)
+
+		exec("SET GLOBAL wait_timeout=60")
+		if v, _ := variables.Global("wait_timeout"); v != "60" {
+			t.Fatalf("expected global wait_timeout 60, got %s", v)
+		}
+	})
+
+	t.Run("Show", func(t *testing.T) {
+		res := query("SHOW VARIABLES LIKE 'character\\_set\\_c%'")
+		if len(res) != 2 || res[0][0] != "character_set_client" || res[1][0] != "character_set_connection" {
+			t.Fatalf("unexpected variables: %v", res)
+		}
+		res = query("SHOW SESSION VARIABLES WHERE Variable_name IN ('autocommit', 'custom_var') OR Value = 'ANSI'")
+		if len(res) != 2 || res[0][1] != "ON" || res[1][1] != "x" {
+			t.Fatalf("unexpected variables: %v", res)
+		}
+		if res = query("SHOW GLOBAL VARIABLES LIKE 'custom_var'"); len(res) != 0 {
+			t.Fatalf("expected no session variable, got %v", res)
+		}
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		if res := query("SELECT name FROM t"); res[0][0] != "SELECT name FROM t" {
+			t.Fatalf("expected query performed by handler, got %v", res)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		if err := conn.WriteCommandPacket(packet.NewCmd(packet.ComResetConnection, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.ReadPacket(); err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := session().SystemVariable("transaction_isolation"); v != "REPEATABLE-READ" {
+			t.Fatalf("expected session variables reset, got %s", v)
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package server
+
+// PasswordVerifier verifies cleartext password sent by mysql_clear_password plugin
+// with external identity service, such as LDAP or PAM, instead of comparing
+// authentication_string. Implement should keep concurrent safely.
+type PasswordVerifier interface {
+	// Verify should return ErrAccessDenied if password does not match.
+	Verify(r *PasswordVerifyRequest) error
+}
+
+type PasswordVerifyRequest struct {
+	// Key is returned by UserProvider.Key.
+	Key      string
+	User     string
+	Host     string
+	Password []byte
+	TLSed    bool
+}
+
+// PasswordVerifierFunc adapts function to PasswordVerifier.
+type PasswordVerifierFunc func(r *PasswordVerifyRequest) error
+
+func (f PasswordVerifierFunc) Verify(r *PasswordVerifyRequest) error {
+	return f(r)
+}
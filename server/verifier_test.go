@@ -0,0 +1,75 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/auth"
+	"github.com/vczyh/mysql-protocol/client"
+	"github.com/vczyh/mysql-protocol/code"
+	"sync"
+	"testing"
+)
+
+func TestPasswordVerifier(t *testing.T) {
+	userProvider := newUserProvider(t, &CreateUserRequest{User: "ldap", Host: "%", Method: auth.ClearPassword})
+	var (
+		mu       sync.Mutex
+		requests []*PasswordVerifyRequest
+	)
+	verifier := PasswordVerifierFunc(func(r *PasswordVerifyRequest) error {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r)
+		if string(r.Password) != "secret" {
+			return ErrAccessDenied
+		}
+		return nil
+	})
+	connect := func(srv *Server, password string, opts ...client.Option) error {
+		opts = append(opts, client.WithDialer(pipeDialer(srv)), client.WithUser("ldap"), client.WithPassword(password))
+		conn, err := client.CreateConnection(opts...)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	srv := newTestServer(userProvider, NewDefaultHandler(), WithPasswordVerifier(verifier))
+
+	t.Run("Accept", func(t *testing.T) {
+		if err := connect(srv, "secret", client.WithAllowCleartextPasswords(true)); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		r := requests[len(requests)-1]
+		if r.Key != "ldap@%" || r.User != "ldap" || string(r.Password) != "secret" || r.TLSed {
+			t.Fatalf("unexpected request: %+v", r)
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		err := connect(srv, "wrong", client.WithAllowCleartextPasswords(true))
+		if errorCode(err) != code.ErrAccessDeniedError {
+			t.Fatalf("expected access denied, got %v", err)
+		}
+	})
+
+	t.Run("NoVerifier", func(t *testing.T) {
+		err := connect(newTestServer(userProvider, NewDefaultHandler()), "secret", client.WithAllowCleartextPasswords(true))
+		if errorCode(err) != code.ErrAccessDeniedError {
+			t.Fatalf("expected access denied, got %v", err)
+		}
+	})
+
+	t.Run("WithoutTLS", func(t *testing.T) {
+		mu.Lock()
+		n := len(requests)
+		mu.Unlock()
+		if err := connect(srv, "secret"); err != auth.ErrCleartextPasswordNotAllowed {
+			t.Fatalf("expected %v, got %v", auth.ErrCleartextPasswordNotAllowed, err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(requests) != n {
+			t.Fatal("password is sent without TLS")
+		}
+	})
+}
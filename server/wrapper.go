@@ -0,0 +1,111 @@
+package server
+
+import (
+	"github.com/vczyh/mysql-protocol/flag"
+	"github.com/vczyh/mysql-protocol/myerrors"
+	"github.com/vczyh/mysql-protocol/mysql"
+	"github.com/vczyh/mysql-protocol/packet"
+)
+
+// Wrapper forwards commands to Next, including optional commands such as StmtCommand.
+// Handler middleware embeds Wrapper and overrides commands it intercepts.
+//
+// Optional commands not implemented by Next behave as if Handler doesn't implement them,
+// except that prepared statements are rejected with ERR.
+type Wrapper struct {
+	Next Handler
+}
+
+func (w *Wrapper) Ping(session *Session) error {
+	return w.Next.Ping(session)
+}
+
+func (w *Wrapper) Query(session *Session, query string) (interface{}, error) {
+	return w.Next.Query(session, query)
+}
+
+func (w *Wrapper) Other(session *Session, data []byte) {
+	w.Next.Other(session, data)
+}
+
+func (w *Wrapper) OnConnect(connId uint32) {
+	w.Next.OnConnect(connId)
+}
+
+func (w *Wrapper) OnClose(connId uint32) {
+	w.Next.OnClose(connId)
+}
+
+func (w *Wrapper) Prepare(session *Session, query string) (int, []mysql.Column, error) {
+	h, ok := w.Next.(StmtCommand)
+	if !ok {
+		return 0, nil, myerrors.UnknownCom.Build()
+	}
+	return h.Prepare(session, query)
+}
+
+func (w *Wrapper) Execute(session *Session, stmt *Stmt, args []interface{}) (interface{}, error) {
+	h, ok := w.Next.(StmtCommand)
+	if !ok {
+		return nil, myerrors.UnknownCom.Build()
+	}
+	return h.Execute(session, stmt, args)
+}
+
+func (w *Wrapper) CloseStmt(session *Session, stmt *Stmt) {
+	if h, ok := w.Next.(StmtCommand); ok {
+		h.CloseStmt(session, stmt)
+	}
+}
+
+func (w *Wrapper) InitDB(session *Session, database string) error {
+	if h, ok := w.Next.(InitDBCommand); ok {
+		return h.InitDB(session, database)
+	}
+	return nil
+}
+
+func (w *Wrapper) FieldList(session *Session, table, wildcard string) ([]mysql.Column, error) {
+	if h, ok := w.Next.(FieldListCommand); ok {
+		return h.FieldList(session, table, wildcard)
+	}
+	return nil, nil
+}
+
+func (w *Wrapper) ChangeUser(session *Session) error {
+	if h, ok := w.Next.(ChangeUserCommand); ok {
+		return h.ChangeUser(session)
+	}
+	return nil
+}
+
+func (w *Wrapper) ResetConnection(session *Session) error {
+	if h, ok := w.Next.(ResetConnectionCommand); ok {
+		return h.ResetConnection(session)
+	}
+	return nil
+}
+
+func (w *Wrapper) SetOption(session *Session, option flag.SetOption) error {
+	if h, ok := w.Next.(SetOptionCommand); ok {
+		return h.SetOption(session, option)
+	}
+	return nil
+}
+
+func (w *Wrapper) Statistics(session *Session) (*packet.Statistics, error) {
+	if h, ok := w.Next.(StatisticsCommand); ok {
+		return h.Statistics(session)
+	}
+	return nil, nil
+}
+
+func (w *Wrapper) Kill(session *Session, target *Session) error {
+	if h, ok := w.Next.(KillCommand); ok {
+		return h.Kill(session, target)
+	}
+	if target.User() != session.User() {
+		return myerrors.KillDenied.Build(target.ConnectionId())
+	}
+	return nil
+}